@@ -0,0 +1,128 @@
+// Command tomoctl performs operator tasks that are deliberately not exposed
+// through the public API, such as granting roles.
+//
+// Usage:
+//
+//	tomoctl role get <email>
+//	tomoctl role set <email> <user|moderator|admin>
+//	tomoctl role list <moderator|admin>
+//	tomoctl tags normalize
+//
+// "tags normalize" is a one-off cleanup for tags created before tag names were
+// normalized: it renames each tag to its normalized form and merges a user's
+// tags that end up with the same name.
+//
+// It connects to the database with the same environment as the API server.
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+
+	"tomo/backend/config"
+	"tomo/backend/models"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage:
+  tomoctl role get <email>
+  tomoctl role set <email> <user|moderator|admin>
+  tomoctl role list <moderator|admin>
+  tomoctl tags normalize`)
+	os.Exit(2)
+}
+
+func validRole(role string) bool {
+	return role == "user" || role == "moderator" || role == "admin"
+}
+
+func main() {
+	args := os.Args[1:]
+	if len(args) < 2 || (args[0] != "role" && args[0] != "tags") {
+		usage()
+	}
+
+	config.ConnectDB()
+	defer config.DB.Close()
+
+	var err error
+	switch {
+	case args[0] == "tags" && args[1] == "normalize" && len(args) == 2:
+		err = normalizeTags(config.DB)
+	case args[0] == "tags":
+		usage()
+	case args[1] == "get" && len(args) == 3:
+		err = getRole(config.DB, args[2])
+	case args[1] == "set" && len(args) == 4:
+		err = setRole(config.DB, args[2], args[3])
+	case args[1] == "list" && len(args) == 3:
+		err = listRole(config.DB, args[2])
+	default:
+		usage()
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "tomoctl:", err)
+		os.Exit(1)
+	}
+}
+
+func getRole(db *sql.DB, email string) error {
+	user, err := models.GetUserByEmail(db, email)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no user with email %s", email)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s (id %d): %s\n", user.Email, user.ID, user.Role)
+	return nil
+}
+
+func setRole(db *sql.DB, email, role string) error {
+	if !validRole(role) {
+		return fmt.Errorf("invalid role %q (want user, moderator or admin)", role)
+	}
+
+	user, err := models.GetUserByEmail(db, email)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("no user with email %s", email)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := models.SetUserRole(db, user.ID, role); err != nil {
+		return err
+	}
+	fmt.Printf("%s (id %d): %s -> %s (takes effect immediately)\n", user.Email, user.ID, user.Role, role)
+	return nil
+}
+
+func listRole(db *sql.DB, role string) error {
+	if !validRole(role) {
+		return fmt.Errorf("invalid role %q (want user, moderator or admin)", role)
+	}
+
+	users, err := models.GetUsersByRole(db, role)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		fmt.Printf("%d\t%s\t%s\n", u.ID, u.Email, u.Username)
+	}
+	return nil
+}
+
+func normalizeTags(db *sql.DB) error {
+	result, err := models.NormalizeExistingTags(db)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("renamed %d tags, merged %d duplicates\n", result.Renamed, result.Merged)
+	for _, name := range result.Invalid {
+		fmt.Printf("skipped %q: cannot be normalized; rename or delete it by hand\n", name)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"tomo/backend/storage"
+)
+
+// Storage is the global media storage backend
+var Storage storage.Storage
+
+// ConnectStorage sets up the backend selected by the environment (see storage.FromEnv)
+func ConnectStorage() {
+	LoadEnv()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var err error
+	Storage, err = storage.FromEnv(ctx)
+	if err != nil {
+		log.Fatalf("Failed to set up storage: %v", err)
+	}
+
+	log.Printf("Media storage ready at %s", Storage.BaseURL())
+}
@@ -3,15 +3,55 @@
 -- These types ensure data consistency and validity in the tables below.
 --
 
--- Defines the visibility level of a reflection post.
-CREATE TYPE post_visibility AS ENUM ('private', 'public');
+-- Defines the visibility level of a reflection post. 'unlisted' posts can be
+-- opened by anyone with the link but are left out of feeds, profiles and search.
+CREATE TYPE post_visibility AS ENUM ('private', 'unlisted', 'public');
 
--- Differentiates between reflection types (Session vs. future Daily/General posts).
-CREATE TYPE post_type AS ENUM ('session', 'general'); 
+-- Differentiates between reflection types: session reflections, general posts and daily journal entries.
+CREATE TYPE post_type AS ENUM ('session', 'general', 'daily'); 
+
+-- Drafts and scheduled posts are only visible to their author and left out of feeds, search and stats.
+CREATE TYPE post_status AS ENUM ('draft', 'scheduled', 'published');
+
+-- How a reflection template question is answered (free text, 1-5 scale, yes/no).
+CREATE TYPE question_type AS ENUM ('text', 'scale', 'yes_no');
 
 -- Defines the type of media attached to a post.
 CREATE TYPE media_type AS ENUM ('image', 'video');
 
+-- Direct uploads start 'pending' until the client confirms the file landed in storage.
+CREATE TYPE media_status AS ENUM ('pending', 'active');
+
+-- Account roles. Moderators work the report queue; admins can also manage users.
+CREATE TYPE user_role AS ENUM ('user', 'moderator', 'admin');
+
+-- Controls who may comment on a post.
+CREATE TYPE comment_permission AS ENUM ('off', 'followers', 'everyone');
+
+-- Roles within a study group.
+CREATE TYPE group_role AS ENUM ('owner', 'admin', 'member');
+
+-- Calendar periods used by group goals and leaderboards.
+CREATE TYPE goal_period AS ENUM ('day', 'week', 'month');
+
+-- What a focus challenge ranks participants by.
+CREATE TYPE challenge_metric AS ENUM ('total_minutes', 'days_over_threshold', 'longest_streak');
+
+-- What a user report is about.
+CREATE TYPE report_target AS ENUM ('post', 'comment', 'media', 'user');
+
+-- Why a user reported something.
+CREATE TYPE report_reason AS ENUM ('spam', 'harassment', 'hate', 'self_harm', 'sexual', 'violence', 'other');
+
+-- Lifecycle of a report in the moderation queue.
+CREATE TYPE report_status AS ENUM ('open', 'resolved', 'dismissed');
+
+-- Whether moderators have hidden a piece of content.
+CREATE TYPE moderation_state AS ENUM ('visible', 'hidden');
+
+-- Defines what a notification is about.
+CREATE TYPE notification_type AS ENUM ('follow', 'reaction', 'comment', 'reply', 'goal_completed', 'mention');
+
 
 --
 -- Table 1: users (User Authentication and Profile)
@@ -25,6 +65,10 @@ CREATE TABLE IF NOT EXISTS users (
     google_id TEXT UNIQUE NOT NULL,     -- ID from Google OAuth provider
     display_name TEXT,                  -- User's preferred name for display
     picture_url TEXT,                   -- URL to the user's avatar/profile picture
+    role user_role NOT NULL DEFAULT 'user', -- Managed with the tomoctl CLI, never through the API
+    suspended_at TIMESTAMPTZ,           -- Set while suspended by an admin; blocks sign-in and hides content
+    search_language REGCONFIG NOT NULL DEFAULT 'english', -- Text search configuration for the user's posts
+    timezone TEXT NOT NULL DEFAULT 'UTC', -- IANA name; decides which calendar day journal entries and sessions fall on
     created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -69,22 +113,60 @@ CREATE TABLE IF NOT EXISTS posts (
     -- Links to a focus_sessions record. NULL if this is a 'general' reflection.
     session_id INT REFERENCES focus_sessions(id) ON DELETE CASCADE,
     
-    post_type post_type NOT NULL,       -- 'session' or 'general' (for daily reflection, etc.)
-    content TEXT,                       -- The main reflection text (e.g., "What went well?")
+    post_type post_type NOT NULL,       -- 'session', 'general' or 'daily' (one journal entry per calendar day)
+    share_slug TEXT UNIQUE NOT NULL,    -- Random public identifier used in share links (/p/{slug}), so IDs stay private
+    journal_date DATE,                  -- The day a 'daily' entry is for, in the user's timezone; NULL otherwise
+    content TEXT,                       -- The main reflection text, in Markdown (e.g., "What went well?")
+    content_html TEXT,                  -- content rendered to sanitized HTML, cached
+    content_html_version SMALLINT NOT NULL DEFAULT 0, -- Renderer version content_html was made with; stale versions are re-rendered by a background job
     title TEXT,                         -- Optional title
     
     -- Reflection-specific fields
     mood_rating SMALLINT CHECK (mood_rating >= 1 AND mood_rating <= 5), -- 1 (low) to 5 (high)
     visibility post_visibility NOT NULL DEFAULT 'private',             -- Controls who can see the post
+    comment_permission comment_permission NOT NULL DEFAULT 'everyone', -- Controls who can comment
+    moderation_state moderation_state NOT NULL DEFAULT 'visible',      -- 'hidden' posts are only visible to the owner
+    status post_status NOT NULL DEFAULT 'published',                   -- 'draft' while being written (autosaved)
+    published_at TIMESTAMPTZ,                                          -- Set when the post is published; feeds sort by it
+    publish_at TIMESTAMPTZ,                                            -- When a 'scheduled' post gets published; NULL otherwise
+    pinned_at TIMESTAMPTZ,                                             -- Set while pinned to the top of the author's profile
     
-    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
-    -- Note: updated_at column is optional, removed the trigger logic as requested.
+    -- Full-text search. search_language is copied from the author's users.search_language;
+    -- title words weigh more than content words when ranking.
+    search_language REGCONFIG NOT NULL DEFAULT 'english',
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector(search_language, coalesce(title, '')), 'A') ||
+        setweight(to_tsvector(search_language, coalesce(content, '')), 'B')
+    ) STORED,
+    
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ,             -- Last change to title, content or mood; NULL if never edited
+    
+    CHECK ((post_type = 'daily') = (journal_date IS NOT NULL)),
+    CHECK ((status = 'scheduled') = (publish_at IS NOT NULL)),
+    CHECK ((status = 'published') = (published_at IS NOT NULL))
 );
 
 -- Index for querying all posts by a user
 CREATE INDEX idx_posts_user_id ON posts(user_id);
+-- Index for paging through a user's posts newest first (cursor is created_at, id)
+CREATE INDEX idx_posts_user_id_created_at ON posts(user_id, created_at DESC, id DESC);
 -- Index for quickly finding a reflection attached to a specific session
 CREATE INDEX idx_posts_session_id ON posts(session_id);
+-- Index for full-text search over title and content
+CREATE INDEX idx_posts_search_vector ON posts USING GIN(search_vector);
+-- Index for listing a user's pinned posts
+CREATE INDEX idx_posts_user_id_pinned_at ON posts(user_id, pinned_at DESC) WHERE pinned_at IS NOT NULL;
+-- Index for the refresh job to find HTML rendered by an older renderer
+CREATE INDEX idx_posts_content_html_version ON posts(content_html_version);
+-- Index for the scheduler to find posts that are due
+CREATE INDEX idx_posts_publish_at ON posts(publish_at) WHERE status = 'scheduled';
+-- One journal entry per user per day, and fast lookup by date
+CREATE UNIQUE INDEX idx_posts_user_journal_date ON posts(user_id, journal_date) WHERE journal_date IS NOT NULL;
+
+-- Posts written before drafts existed have no published_at; treat them as
+-- published when they were created so feeds sort them correctly
+UPDATE posts SET published_at = created_at WHERE status = 'published' AND published_at IS NULL;
 
 
 ---
@@ -107,6 +189,8 @@ CREATE TABLE IF NOT EXISTS tags (
 
 -- Index for quickly retrieving all tags belonging to a user
 CREATE INDEX idx_tags_user_id ON tags(user_id);
+-- Index for browsing a hashtag across users
+CREATE INDEX idx_tags_name ON tags(name);
 
 
 ---
@@ -148,9 +232,588 @@ CREATE TABLE IF NOT EXISTS post_media (
     position SMALLINT NOT NULL DEFAULT 0, 
 
     original_filename TEXT,             -- The file name provided by the user
+    content_type TEXT,                  -- MIME type the upload was signed for (direct uploads only)
+    size_bytes BIGINT,                  -- Exact size the upload was signed for (direct uploads only)
+
+    moderation_state moderation_state NOT NULL DEFAULT 'visible', -- 'hidden' media is not served to anyone
+
+    -- 'pending' rows hold a presigned upload slot and are deleted (with any uploaded file) once expires_at passes
+    status media_status NOT NULL DEFAULT 'active',
+    expires_at TIMESTAMPTZ,
     
-    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CHECK ((status = 'pending') = (expires_at IS NOT NULL))
 );
 
 -- Index to retrieve all media attachments for a single post
-CREATE INDEX idx_post_media_post_id ON post_media(post_id);
\ No newline at end of file
+CREATE INDEX idx_post_media_post_id ON post_media(post_id);
+
+-- Index for the job that expires abandoned uploads
+CREATE INDEX idx_post_media_pending_expires ON post_media(expires_at) WHERE status = 'pending';
+
+
+---
+
+--
+-- Table 7: comments (Threaded Comments on Posts)
+-- Comments support one level of replies: a reply's parent must be a top-level comment
+-- on the same post (enforced by the application).
+--
+CREATE TABLE IF NOT EXISTS comments (
+    id SERIAL PRIMARY KEY,
+
+    post_id INT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    -- NULL for top-level comments; deleting a comment removes its replies.
+    parent_id INT REFERENCES comments(id) ON DELETE CASCADE,
+
+    content TEXT NOT NULL,
+
+    moderation_state moderation_state NOT NULL DEFAULT 'visible', -- 'hidden' comments are not shown to anyone
+
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ              -- Set when the author edits the comment
+);
+
+-- Index to retrieve all comments for a post in order
+CREATE INDEX idx_comments_post_id ON comments(post_id, created_at);
+-- Index for quickly finding the replies to a comment
+CREATE INDEX idx_comments_parent_id ON comments(parent_id);
+
+
+---
+
+--
+-- Table 8: notifications (In-App Notifications)
+-- One row per delivered notification. Rows sharing a group_key (e.g. 'post:42')
+-- are collapsed into a single entry when listed ("3 people commented on your post").
+--
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+
+    -- The recipient.
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- The user who caused the notification. NULL for system notifications.
+    actor_id INT REFERENCES users(id) ON DELETE CASCADE,
+
+    type notification_type NOT NULL,
+
+    post_id INT REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id INT REFERENCES comments(id) ON DELETE CASCADE,
+
+    group_key TEXT NOT NULL,
+    read_at TIMESTAMPTZ,                -- NULL while unread
+
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Index for listing a user's notifications newest first
+CREATE INDEX idx_notifications_user_id_time ON notifications(user_id, created_at DESC);
+-- Partial index for unread counts
+CREATE INDEX idx_notifications_unread ON notifications(user_id) WHERE read_at IS NULL;
+
+
+---
+
+--
+-- Table 9: notification_preferences (Per-Type Opt-Outs)
+-- A missing row means the notification type is enabled.
+--
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type notification_type NOT NULL,
+    enabled BOOLEAN NOT NULL,
+
+    PRIMARY KEY (user_id, type)
+);
+
+
+---
+
+--
+-- Table 10: focus_rooms (Co-Focus Rooms)
+-- Body-doubling rooms with a shared timer. Live presence and chat are kept in
+-- memory by the API; only the room, its members and the timer are stored.
+--
+CREATE TABLE IF NOT EXISTS focus_rooms (
+    id SERIAL PRIMARY KEY,
+
+    owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    invite_code TEXT UNIQUE NOT NULL,   -- Shared with members to let others join; can be regenerated
+
+    -- The shared timer. Both are NULL when no timer is running.
+    timer_started_at TIMESTAMPTZ,
+    timer_duration_minutes INT CHECK (timer_duration_minutes > 0),
+
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+
+---
+
+--
+-- Table 11: focus_room_members (Room Membership)
+--
+CREATE TABLE IF NOT EXISTS focus_room_members (
+    room_id INT NOT NULL REFERENCES focus_rooms(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    joined_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (room_id, user_id)
+);
+
+-- Index for listing the rooms a user has joined
+CREATE INDEX idx_focus_room_members_user_id ON focus_room_members(user_id);
+
+-- Sessions completed in a room are tagged with it. Added here because
+-- focus_sessions is created before focus_rooms.
+ALTER TABLE focus_sessions ADD COLUMN IF NOT EXISTS room_id INT REFERENCES focus_rooms(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_sessions_room_id ON focus_sessions(room_id);
+
+
+---
+
+--
+-- Table 12: study_groups (Study Groups)
+-- Members share aggregated focus minutes and can work toward an optional group goal
+-- (e.g. 6000 minutes per week). Periods are calendar periods in UTC.
+--
+CREATE TABLE IF NOT EXISTS study_groups (
+    id SERIAL PRIMARY KEY,
+
+    owner_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT,
+
+    invite_code TEXT UNIQUE NOT NULL,   -- Shared to let others join; can be regenerated
+
+    -- Optional goal. Both are NULL when the group has no goal.
+    goal_minutes INT CHECK (goal_minutes > 0),
+    goal_period goal_period,
+
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+
+---
+
+--
+-- Table 13: group_members (Study Group Membership)
+--
+CREATE TABLE IF NOT EXISTS group_members (
+    group_id INT NOT NULL REFERENCES study_groups(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    role group_role NOT NULL DEFAULT 'member',
+
+    -- When false, the member shares only totals (leaderboard), not individual sessions.
+    share_sessions BOOLEAN NOT NULL DEFAULT TRUE,
+
+    joined_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (group_id, user_id)
+);
+
+-- Index for listing the groups a user belongs to
+CREATE INDEX idx_group_members_user_id ON group_members(user_id);
+
+
+---
+
+--
+-- Table 14: group_goal_completions (Goal Completion Log)
+-- Ensures a group's goal completion is announced once per period.
+--
+CREATE TABLE IF NOT EXISTS group_goal_completions (
+    group_id INT NOT NULL REFERENCES study_groups(id) ON DELETE CASCADE,
+    period_start TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (group_id, period_start)
+);
+
+
+---
+
+--
+-- Table 15: challenges (Focus Challenges)
+-- A competition over a fixed period. Standings are computed from focus_sessions
+-- that start within [starts_at, ends_at); days are calendar days in UTC.
+--
+CREATE TABLE IF NOT EXISTS challenges (
+    id SERIAL PRIMARY KEY,
+
+    creator_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    description TEXT,
+
+    metric challenge_metric NOT NULL,
+    -- Minutes of focus needed for a day to count ('days_over_threshold', 'longest_streak')
+    threshold_minutes INT NOT NULL DEFAULT 1 CHECK (threshold_minutes > 0),
+
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    finalized_at TIMESTAMPTZ,           -- Set once final ranks have been stored
+
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    CHECK (ends_at > starts_at)
+);
+
+-- Index for finding challenges that still need to be finalized
+CREATE INDEX idx_challenges_unfinalized ON challenges(ends_at) WHERE finalized_at IS NULL;
+
+
+---
+
+--
+-- Table 16: challenge_participants (Challenge Participation and Results)
+--
+CREATE TABLE IF NOT EXISTS challenge_participants (
+    challenge_id INT NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    joined_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    -- Filled in when the challenge is finalized
+    final_rank INT,
+    final_score INT,
+
+    PRIMARY KEY (challenge_id, user_id)
+);
+
+-- Index for listing a user's challenges
+CREATE INDEX idx_challenge_participants_user_id ON challenge_participants(user_id);
+
+
+---
+
+--
+-- Table 17: user_blocks (Blocked Users)
+-- A block hides both users from each other everywhere and stops interaction
+-- (comments, replies, notifications) in both directions.
+--
+CREATE TABLE IF NOT EXISTS user_blocks (
+    blocker_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id)
+);
+
+-- Index for checking blocks from the blocked user's side
+CREATE INDEX idx_user_blocks_blocked_id ON user_blocks(blocked_id);
+
+
+---
+
+--
+-- Table 18: user_mutes (Muted Users)
+-- A mute only hides the muted user's posts from the muter's feed; it is one-way
+-- and invisible to the muted user.
+--
+CREATE TABLE IF NOT EXISTS user_mutes (
+    muter_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    muted_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (muter_id, muted_id),
+    CHECK (muter_id <> muted_id)
+);
+
+
+---
+
+--
+-- Table 19: reports (User Reports / Moderation Queue)
+-- target_id points at posts, comments, post_media or users depending on target_type,
+-- so it cannot carry a foreign key. Reports outlive the content they point at.
+--
+CREATE TABLE IF NOT EXISTS reports (
+    id SERIAL PRIMARY KEY,
+
+    reporter_id INT REFERENCES users(id) ON DELETE SET NULL,
+    target_type report_target NOT NULL,
+    target_id INT NOT NULL,
+
+    reason report_reason NOT NULL,
+    details TEXT,                       -- Optional free text from the reporter
+
+    status report_status NOT NULL DEFAULT 'open',
+    resolved_by INT REFERENCES users(id) ON DELETE SET NULL,
+    resolution_note TEXT,
+    resolved_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A user can have only one open report per target
+CREATE UNIQUE INDEX idx_reports_open_unique ON reports(reporter_id, target_type, target_id) WHERE status = 'open';
+-- Index for the moderation queue (oldest open reports first)
+CREATE INDEX idx_reports_status_created_at ON reports(status, created_at);
+
+
+---
+
+--
+-- Table 20: post_revisions (Post Edit History)
+-- Each row is a previous version of a published post, saved when its title,
+-- content or mood is edited. Drafts are not versioned.
+--
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id SERIAL PRIMARY KEY,
+
+    post_id INT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+
+    title TEXT,
+    content TEXT,
+    mood_rating SMALLINT,
+
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP -- When this version was replaced
+);
+
+-- Index for listing a post's history, newest first
+CREATE INDEX idx_post_revisions_post_id_created_at ON post_revisions(post_id, created_at DESC);
+
+
+---
+
+--
+-- Table 21: reflection_templates (Structured Reflection Prompts)
+-- Built-in templates have no owner (user_id NULL) and are seeded below.
+--
+CREATE TABLE IF NOT EXISTS reflection_templates (
+    id SERIAL PRIMARY KEY,
+
+    user_id INT REFERENCES users(id) ON DELETE CASCADE, -- NULL for built-in templates
+    name TEXT NOT NULL,
+    description TEXT,
+
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Index for listing a user's own templates
+CREATE INDEX idx_reflection_templates_user_id ON reflection_templates(user_id);
+
+-- Posts written from a template. Declared here because posts is created first.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS template_id INT REFERENCES reflection_templates(id) ON DELETE SET NULL;
+
+
+---
+
+--
+-- Table 22: template_questions (Questions in a Reflection Template)
+--
+CREATE TABLE IF NOT EXISTS template_questions (
+    id SERIAL PRIMARY KEY,
+
+    template_id INT NOT NULL REFERENCES reflection_templates(id) ON DELETE CASCADE,
+    position INT NOT NULL,              -- Display order within the template, from 0
+    prompt TEXT NOT NULL,               -- e.g. 'What distracted you?'
+    question_type question_type NOT NULL,
+
+    UNIQUE(template_id, position)
+);
+
+
+---
+
+--
+-- Table 23: post_answers (Structured Answers to Template Questions)
+-- The prompt and type are copied from the question so answers still read
+-- correctly after the template is deleted (question_id becomes NULL).
+-- Exactly one answer column is set, matching question_type.
+--
+CREATE TABLE IF NOT EXISTS post_answers (
+    id SERIAL PRIMARY KEY,
+
+    post_id INT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    question_id INT REFERENCES template_questions(id) ON DELETE SET NULL,
+    prompt TEXT NOT NULL,
+    question_type question_type NOT NULL,
+
+    text_answer TEXT,
+    scale_answer SMALLINT CHECK (scale_answer >= 1 AND scale_answer <= 5),
+    yes_no_answer BOOLEAN,
+
+    CHECK (
+        (question_type = 'text' AND text_answer IS NOT NULL AND scale_answer IS NULL AND yes_no_answer IS NULL) OR
+        (question_type = 'scale' AND scale_answer IS NOT NULL AND text_answer IS NULL AND yes_no_answer IS NULL) OR
+        (question_type = 'yes_no' AND yes_no_answer IS NOT NULL AND text_answer IS NULL AND scale_answer IS NULL)
+    ),
+    UNIQUE(post_id, question_id)
+);
+
+-- Index for aggregating answers to a question (insights)
+CREATE INDEX idx_post_answers_question_id ON post_answers(question_id);
+
+-- Built-in templates are named uniquely, so re-running this file skips the seeds
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reflection_templates_builtin_name ON reflection_templates(name) WHERE user_id IS NULL;
+
+-- Built-in templates
+WITH t AS (
+    INSERT INTO reflection_templates (name, description)
+    SELECT 'Session debrief', 'A quick look back after a focus session.'
+    WHERE NOT EXISTS (SELECT 1 FROM reflection_templates WHERE user_id IS NULL AND name = 'Session debrief')
+    RETURNING id
+)
+INSERT INTO template_questions (template_id, position, prompt, question_type)
+SELECT t.id, q.position, q.prompt, q.question_type::question_type
+FROM t, (VALUES
+    (0, 'What went well?', 'text'),
+    (1, 'What distracted you?', 'text'),
+    (2, 'How focused did you feel?', 'scale'),
+    (3, 'Did you finish what you set out to do?', 'yes_no')
+) AS q(position, prompt, question_type);
+
+WITH t AS (
+    INSERT INTO reflection_templates (name, description)
+    SELECT 'Daily check-in', 'How the day went, at a glance.'
+    WHERE NOT EXISTS (SELECT 1 FROM reflection_templates WHERE user_id IS NULL AND name = 'Daily check-in')
+    RETURNING id
+)
+INSERT INTO template_questions (template_id, position, prompt, question_type)
+SELECT t.id, q.position, q.prompt, q.question_type::question_type
+FROM t, (VALUES
+    (0, 'How was your energy today?', 'scale'),
+    (1, 'Did you take real breaks?', 'yes_no'),
+    (2, 'What is one thing you learned?', 'text'),
+    (3, 'What will you do differently tomorrow?', 'text')
+) AS q(position, prompt, question_type);
+
+
+---
+
+--
+-- Table 24: post_mentions (@mentions in Post Content)
+-- Parsed from the content on every write. notified_at is set once the mentioned
+-- user has been notified, which only happens once they can see the post.
+--
+CREATE TABLE IF NOT EXISTS post_mentions (
+    post_id INT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    notified_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (post_id, user_id)
+);
+
+-- Index for finding posts that mention a user
+CREATE INDEX idx_post_mentions_user_id ON post_mentions(user_id);
+
+
+---
+
+--
+-- Table 25: collections (Curated Lists of a User's Posts)
+-- A collection's visibility is independent of its posts': in a public
+-- collection, other users still only see the posts they could see anyway.
+--
+CREATE TABLE IF NOT EXISTS collections (
+    id SERIAL PRIMARY KEY,
+
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT,
+    visibility post_visibility NOT NULL DEFAULT 'private',
+
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP, -- Last change to the collection or its posts
+
+    UNIQUE(user_id, name)
+);
+
+
+---
+
+--
+-- Table 26: collection_posts (Posts in a Collection, in Order)
+--
+CREATE TABLE IF NOT EXISTS collection_posts (
+    collection_id INT NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    post_id INT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    position INT NOT NULL,              -- Display order within the collection, from 0
+
+    added_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (collection_id, post_id)
+);
+
+-- Index for finding the collections a post is in
+CREATE INDEX idx_collection_posts_post_id ON collection_posts(post_id);
+
+
+---
+
+--
+-- Table 27: media_objects (Stored Media Files, Deduplicated per User)
+-- Each stored file has one row. Identical uploads by the same user (same SHA-256)
+-- share one object; ref_count counts the post_media rows using it, and the file
+-- is deleted from storage when it drops to zero.
+--
+CREATE TABLE IF NOT EXISTS media_objects (
+    id SERIAL PRIMARY KEY,
+
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    storage_key TEXT UNIQUE NOT NULL,   -- e.g. "uploads/12/<random>.jpg"; never derived from the file name
+    sha256 TEXT,                        -- Hex SHA-256 of the contents; NULL until a direct upload is completed
+    size_bytes BIGINT NOT NULL,
+    content_type TEXT NOT NULL,
+
+    ref_count INT NOT NULL DEFAULT 1 CHECK (ref_count >= 0),
+
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE(user_id, sha256)
+);
+
+-- The stored file behind a media item. Declared here because post_media is created
+-- first. NULL for media added before objects were tracked; those files are never
+-- deleted, since old keys were built from file names and may be shared.
+ALTER TABLE post_media ADD COLUMN IF NOT EXISTS object_id INT REFERENCES media_objects(id);
+CREATE INDEX IF NOT EXISTS idx_post_media_object_id ON post_media(object_id);
+
+
+---
+
+--
+-- Table 28: follows (Follow Graph)
+-- follower_id follows followed_id. Decides who may comment on posts whose
+-- comment_permission is 'followers'. Blocking removes follows in both directions.
+--
+CREATE TABLE IF NOT EXISTS follows (
+    follower_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followed_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (follower_id, followed_id),
+    CHECK (follower_id <> followed_id)
+);
+
+-- Index for listing a user's followers
+CREATE INDEX IF NOT EXISTS idx_follows_followed_id ON follows(followed_id);
+
+
+---
+
+--
+-- Table 29: post_reactions (Reactions to Posts)
+-- One reaction per user per post; reacting again replaces the previous kind.
+--
+CREATE TABLE IF NOT EXISTS post_reactions (
+    post_id INT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,                 -- One of models.ReactionKinds, e.g. 'heart'
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (post_id, user_id)
+);
+
+-- Index for listing a user's reactions
+CREATE INDEX IF NOT EXISTS idx_post_reactions_user_id ON post_reactions(user_id);
@@ -0,0 +1,92 @@
+// Package events decouples the handlers that produce domain events (a comment
+// was posted, a user was followed, ...) from the subsystems that react to them
+// (notifications, real-time delivery). Handlers only publish; consumers
+// subscribe to the event types they care about.
+package events
+
+import (
+	"log"
+	"sync"
+)
+
+// Type identifies what happened
+type Type string
+
+const (
+	CommentCreated Type = "comment.created"
+	UserFollowed   Type = "user.followed"
+	PostReacted    Type = "post.reacted"
+	GoalCompleted  Type = "goal.completed"
+
+	NotificationCreated Type = "notification.created"
+
+	SessionCreated Type = "session.created"
+	SessionDeleted Type = "session.deleted"
+
+	// A room's shared timer started or stopped; TargetID is the room
+	RoomTimerChanged Type = "room.timer_changed"
+
+	// A post was created already published; drafts get PostPublished instead
+	PostCreated Type = "post.created"
+	PostUpdated Type = "post.updated"
+	PostDeleted Type = "post.deleted"
+	// A draft or scheduled post became published (by its author or the scheduler)
+	PostPublished Type = "post.published"
+	// UserID is the mentioned user
+	PostMentioned Type = "post.mentioned"
+)
+
+// Event describes something that happened. Consumers look up whatever else
+// they need (post owner, parent comment author, ...) from the IDs.
+type Event struct {
+	Type      Type
+	ActorID   int // user who caused the event (0 for system events)
+	UserID    int // user the event is about, when it isn't implied by a post/comment
+	PostID    int // 0 when not applicable
+	CommentID int // 0 when not applicable
+	TargetID  int // ID of any other subject (a group, a challenge, ...), 0 when not applicable
+}
+
+// Subscriber handles a published event. Subscribers run synchronously in the
+// publishing goroutine, so they should be quick and must not panic.
+type Subscriber func(Event)
+
+// Bus fans published events out to subscribers of that event type
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[Type][]Subscriber
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[Type][]Subscriber)}
+}
+
+// Subscribe registers s for every future event of type t
+func (b *Bus) Subscribe(t Type, s Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[t] = append(b.subscribers[t], s)
+}
+
+// Publish delivers e to its subscribers. A nil Bus drops the event, so
+// handlers built without one (e.g. in tests) keep working.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	subs := b.subscribers[e.Type]
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("event subscriber for %s panicked: %v", e.Type, r)
+				}
+			}()
+			s(e)
+		}()
+	}
+}
@@ -0,0 +1,62 @@
+// Package groups watches focus sessions and announces when a study group
+// reaches its goal for the current period.
+package groups
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"tomo/backend/events"
+	"tomo/backend/models"
+)
+
+// Register checks the goals of the session owner's groups whenever a session is recorded
+func Register(bus *events.Bus, db *sql.DB) {
+	bus.Subscribe(events.SessionCreated, func(e events.Event) {
+		checkGoals(bus, db, e.ActorID)
+	})
+}
+
+func checkGoals(bus *events.Bus, db *sql.DB, userID int) {
+	groupIDs, err := models.GetGroupIDsWithGoalsForUser(db, userID)
+	if err != nil {
+		log.Printf("groups: failed to load groups for user %d: %v", userID, err)
+		return
+	}
+
+	for _, groupID := range groupIDs {
+		g, err := models.GetGroupByID(db, groupID)
+		if err != nil || g.GoalMinutes == nil || g.GoalPeriod == nil {
+			continue
+		}
+
+		start := models.PeriodStart(*g.GoalPeriod, time.Now())
+		total, err := models.GetGroupTotalMinutes(db, g.ID, start)
+		if err != nil {
+			log.Printf("groups: failed to total group %d: %v", g.ID, err)
+			continue
+		}
+		if total < *g.GoalMinutes {
+			continue
+		}
+
+		isNew, err := models.RecordGroupGoalCompletion(db, g.ID, start)
+		if err != nil {
+			log.Printf("groups: failed to record goal completion for group %d: %v", g.ID, err)
+			continue
+		}
+		if !isNew {
+			continue
+		}
+
+		members, err := models.GetGroupMembers(db, g.ID)
+		if err != nil {
+			log.Printf("groups: failed to load members of group %d: %v", g.ID, err)
+			continue
+		}
+		for _, m := range members {
+			bus.Publish(events.Event{Type: events.GoalCompleted, UserID: m.UserID, TargetID: g.ID})
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+// AdminHandler serves admin-only user management and system stats. Roles
+// themselves are managed with the tomoctl CLI, not through the API.
+type AdminHandler struct {
+	DB *sql.DB
+}
+
+// GET /admin/users?q= — look up users by email or username prefix
+func (h *AdminHandler) FindUsers(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+
+	// Escape LIKE wildcards so the query is matched literally
+	q = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(q)
+
+	users, err := models.FindUsers(h.DB, q, 50)
+	if err != nil {
+		http.Error(w, "failed to search users", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"users": users,
+		"count": len(users),
+	})
+}
+
+// GET /admin/users/{id} — full user record, including email, role and suspension
+func (h *AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	user, err := models.GetUserByID(h.DB, userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, user)
+}
+
+// GET /admin/stats — system-wide usage counts
+func (h *AdminHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := models.GetSystemStats(h.DB)
+	if err != nil {
+		http.Error(w, "failed to compute stats", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, stats)
+}
+
+// setSuspended suspends or unsuspends the user {id}
+func (h *AdminHandler) setSuspended(w http.ResponseWriter, r *http.Request, suspended bool) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+	if userID == user.UserID {
+		http.Error(w, "cannot suspend yourself", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := models.GetUserByID(h.DB, userID); err == sql.ErrNoRows {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := models.SetUserSuspended(h.DB, userID, suspended); err != nil {
+		http.Error(w, "failed to update user", http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := models.GetUserByID(h.DB, userID)
+	if err != nil {
+		http.Error(w, "failed to fetch updated user", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, updated)
+}
+
+// POST /admin/users/{id}/suspend — suspend a user: sign-in is refused, tokens
+// already issued stop working, and their profile and content are hidden
+func (h *AdminHandler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	h.setSuspended(w, r, true)
+}
+
+// POST /admin/users/{id}/unsuspend — lift a suspension
+func (h *AdminHandler) UnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	h.setSuspended(w, r, false)
+}
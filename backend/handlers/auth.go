@@ -61,6 +61,12 @@ func (h *AuthHandler) GoogleAuth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Suspended accounts cannot sign in
+	if user.SuspendedAt != nil {
+		http.Error(w, "account suspended", http.StatusForbidden)
+		return
+	}
+
 	// Create JWT token for our application
 	token, err := utils.CreateToken(user.ID, user.Email, 24*time.Hour)
 	if err != nil {
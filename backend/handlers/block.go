@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+// loadTargetUser resolves {username} to another user, writing an error
+// response on failure. Users cannot target themselves.
+func (h *UserHandler) loadTargetUser(w http.ResponseWriter, r *http.Request, userID int) (models.User, bool) {
+	target, err := models.GetUserByUsername(h.DB, r.PathValue("username"))
+	if err == sql.ErrNoRows {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return models.User{}, false
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return models.User{}, false
+	}
+	if target.ID == userID {
+		http.Error(w, "cannot block, mute or follow yourself", http.StatusBadRequest)
+		return models.User{}, false
+	}
+	return target, true
+}
+
+// POST /users/{username}/block — block a user (both users stop seeing each other)
+func (h *UserHandler) BlockUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	target, ok := h.loadTargetUser(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	if err := models.BlockUser(h.DB, user.UserID, target.ID); err != nil {
+		http.Error(w, "failed to block user", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "user blocked"})
+}
+
+// DELETE /users/{username}/block — unblock a user
+func (h *UserHandler) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	target, ok := h.loadTargetUser(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	if err := models.UnblockUser(h.DB, user.UserID, target.ID); err != nil {
+		http.Error(w, "failed to unblock user", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "user unblocked"})
+}
+
+// GET /me/blocks — list users the current user has blocked
+func (h *UserHandler) GetBlockedUsers(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	users, err := models.GetBlockedUsers(h.DB, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch blocked users", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"users": users,
+		"count": len(users),
+	})
+}
+
+// POST /users/{username}/mute — hide a user's posts from the current user's feed
+func (h *UserHandler) MuteUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	target, ok := h.loadTargetUser(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	if err := models.MuteUser(h.DB, user.UserID, target.ID); err != nil {
+		http.Error(w, "failed to mute user", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "user muted"})
+}
+
+// DELETE /users/{username}/mute — unmute a user
+func (h *UserHandler) UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	target, ok := h.loadTargetUser(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	if err := models.UnmuteUser(h.DB, user.UserID, target.ID); err != nil {
+		http.Error(w, "failed to unmute user", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "user unmuted"})
+}
+
+// GET /me/mutes — list users the current user has muted
+func (h *UserHandler) GetMutedUsers(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	users, err := models.GetMutedUsers(h.DB, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch muted users", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"users": users,
+		"count": len(users),
+	})
+}
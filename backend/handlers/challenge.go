@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+// MaxChallengeLength caps how long a challenge may run
+const MaxChallengeLength = 366 * 24 * time.Hour
+
+type ChallengeHandler struct {
+	DB *sql.DB
+}
+
+type CreateChallengeRequest struct {
+	Title            string    `json:"title"`
+	Description      string    `json:"description,omitempty"`
+	Metric           string    `json:"metric"`                      // 'total_minutes', 'days_over_threshold' or 'longest_streak'
+	ThresholdMinutes int       `json:"threshold_minutes,omitempty"` // required for 'days_over_threshold'
+	StartsAt         time.Time `json:"starts_at"`
+	EndsAt           time.Time `json:"ends_at"`
+}
+
+func validMetric(m string) bool {
+	return m == "total_minutes" || m == "days_over_threshold" || m == "longest_streak"
+}
+
+// loadChallenge parses {id} and fetches the challenge, finalizing it first if it
+// has ended and the background job has not caught up yet
+func (h *ChallengeHandler) loadChallenge(w http.ResponseWriter, r *http.Request) (models.Challenge, bool) {
+	challengeID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid challenge id", http.StatusBadRequest)
+		return models.Challenge{}, false
+	}
+
+	c, err := models.GetChallengeByID(h.DB, challengeID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "challenge not found", http.StatusNotFound)
+		return models.Challenge{}, false
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return models.Challenge{}, false
+	}
+
+	if c.FinalizedAt == nil && !time.Now().Before(c.EndsAt) {
+		if err := models.FinalizeChallenge(h.DB, c.ID); err != nil {
+			http.Error(w, "failed to finalize challenge", http.StatusInternalServerError)
+			return models.Challenge{}, false
+		}
+		if c, err = models.GetChallengeByID(h.DB, c.ID); err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return models.Challenge{}, false
+		}
+	}
+
+	return c, true
+}
+
+// POST /challenges — create a challenge
+func (h *ChallengeHandler) CreateChallenge(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateChallengeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	title := strings.TrimSpace(req.Title)
+	if title == "" || len(title) > 100 {
+		http.Error(w, "title must be between 1 and 100 characters", http.StatusBadRequest)
+		return
+	}
+	description := strings.TrimSpace(req.Description)
+	if len(description) > 1000 {
+		http.Error(w, "description must be 1000 characters or less", http.StatusBadRequest)
+		return
+	}
+	if !validMetric(req.Metric) {
+		http.Error(w, "metric must be 'total_minutes', 'days_over_threshold' or 'longest_streak'", http.StatusBadRequest)
+		return
+	}
+
+	threshold := req.ThresholdMinutes
+	switch {
+	case req.Metric == "total_minutes":
+		threshold = 1 // unused
+	case req.Metric == "days_over_threshold" && threshold == 0:
+		http.Error(w, "threshold_minutes is required for 'days_over_threshold'", http.StatusBadRequest)
+		return
+	case threshold == 0:
+		threshold = 1 // any focus counts toward a streak
+	}
+	if threshold < 1 || threshold > 1440 {
+		http.Error(w, "threshold_minutes must be between 1 and 1440", http.StatusBadRequest)
+		return
+	}
+
+	if req.StartsAt.IsZero() || req.EndsAt.IsZero() {
+		http.Error(w, "starts_at and ends_at are required", http.StatusBadRequest)
+		return
+	}
+	if !req.EndsAt.After(req.StartsAt) {
+		http.Error(w, "ends_at must be after starts_at", http.StatusBadRequest)
+		return
+	}
+	if !req.EndsAt.After(time.Now()) {
+		http.Error(w, "ends_at must be in the future", http.StatusBadRequest)
+		return
+	}
+	if req.EndsAt.Sub(req.StartsAt) > MaxChallengeLength {
+		http.Error(w, "challenges can run for at most a year", http.StatusBadRequest)
+		return
+	}
+
+	c, err := models.CreateChallenge(h.DB, models.Challenge{
+		CreatorID:        user.UserID,
+		Title:            title,
+		Description:      description,
+		Metric:           req.Metric,
+		ThresholdMinutes: threshold,
+		StartsAt:         req.StartsAt,
+		EndsAt:           req.EndsAt,
+	})
+	if err != nil {
+		http.Error(w, "failed to create challenge", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, c)
+}
+
+// GET /challenges?status=active|upcoming|finished — browse challenges (default: active)
+func (h *ChallengeHandler) GetChallenges(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "active"
+	}
+	if status != "active" && status != "upcoming" && status != "finished" {
+		http.Error(w, "status must be 'active', 'upcoming' or 'finished'", http.StatusBadRequest)
+		return
+	}
+
+	limit := 20
+	offset := 0
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	list, err := models.GetChallenges(h.DB, status, limit, offset)
+	if err != nil {
+		http.Error(w, "failed to fetch challenges", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"challenges": list,
+		"count":      len(list),
+	})
+}
+
+// GET /me/challenges — list challenges the user has joined
+func (h *ChallengeHandler) GetMyChallenges(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	list, err := models.GetChallengesForUser(h.DB, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch challenges", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"challenges": list,
+		"count":      len(list),
+	})
+}
+
+// GET /challenges/{id} — challenge details with live or final standings
+func (h *ChallengeHandler) GetChallenge(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	c, ok := h.loadChallenge(w, r)
+	if !ok {
+		return
+	}
+
+	var standings []models.Standing
+	var err error
+	if c.FinalizedAt != nil {
+		standings, err = models.GetFinalStandings(h.DB, c.ID)
+	} else {
+		standings, err = models.ComputeChallengeStandings(h.DB, c)
+	}
+	if err != nil {
+		http.Error(w, "failed to compute standings", http.StatusInternalServerError)
+		return
+	}
+	if standings == nil {
+		standings = []models.Standing{}
+	}
+
+	joined := false
+	for _, s := range standings {
+		if s.UserID == user.UserID {
+			joined = true
+			break
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"challenge": c,
+		"standings": standings,
+		"final":     c.FinalizedAt != nil,
+		"joined":    joined,
+	})
+}
+
+// POST /challenges/{id}/join — join a challenge that has not ended
+func (h *ChallengeHandler) JoinChallenge(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	c, ok := h.loadChallenge(w, r)
+	if !ok {
+		return
+	}
+	if c.FinalizedAt != nil || !time.Now().Before(c.EndsAt) {
+		http.Error(w, "challenge has ended", http.StatusConflict)
+		return
+	}
+
+	if err := models.JoinChallenge(h.DB, c.ID, user.UserID); err != nil {
+		http.Error(w, "failed to join challenge", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "joined challenge"})
+}
+
+// POST /challenges/{id}/leave — leave a challenge that has not ended
+func (h *ChallengeHandler) LeaveChallenge(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	c, ok := h.loadChallenge(w, r)
+	if !ok {
+		return
+	}
+	if c.FinalizedAt != nil || !time.Now().Before(c.EndsAt) {
+		http.Error(w, "challenge has ended", http.StatusConflict)
+		return
+	}
+
+	joined, err := models.IsChallengeParticipant(h.DB, c.ID, user.UserID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if !joined {
+		http.Error(w, "not a participant", http.StatusNotFound)
+		return
+	}
+
+	if err := models.LeaveChallenge(h.DB, c.ID, user.UserID); err != nil {
+		http.Error(w, "failed to leave challenge", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "left challenge"})
+}
+
+// DELETE /challenges/{id} — delete a challenge (creator only)
+func (h *ChallengeHandler) DeleteChallenge(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	challengeID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid challenge id", http.StatusBadRequest)
+		return
+	}
+
+	c, err := models.GetChallengeByID(h.DB, challengeID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "challenge not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if c.CreatorID != user.UserID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	if err := models.DeleteChallenge(h.DB, c.ID); err != nil {
+		http.Error(w, "failed to delete challenge", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "challenge deleted"})
+}
@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+// Limits on collections
+const (
+	MaxCollectionNameLength        = 100
+	MaxCollectionDescriptionLength = 500
+)
+
+type CollectionHandler struct {
+	DB *sql.DB
+}
+
+type CreateCollectionRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Visibility  string `json:"visibility,omitempty"` // 'private' (default) or 'public'
+}
+
+// UpdateCollectionRequest is a partial update; omitted fields are left unchanged
+type UpdateCollectionRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Visibility  *string `json:"visibility,omitempty"`
+}
+
+type AddCollectionPostRequest struct {
+	PostID int `json:"post_id"`
+}
+
+type ReorderCollectionRequest struct {
+	PostIDs []int `json:"post_ids"`
+}
+
+// validateCollectionFields trims and checks a collection's name and
+// description, returning an error message for bad input
+func validateCollectionFields(c *models.Collection) string {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Description = strings.TrimSpace(c.Description)
+	if c.Name == "" {
+		return "name is required"
+	}
+	if len(c.Name) > MaxCollectionNameLength {
+		return "name must be 100 characters or less"
+	}
+	if len(c.Description) > MaxCollectionDescriptionLength {
+		return "description must be 500 characters or less"
+	}
+	if c.Visibility != "private" && c.Visibility != "public" {
+		return "visibility must be 'private' or 'public'"
+	}
+	return ""
+}
+
+// isDuplicateName reports whether err is the unique (user_id, name) violation
+func isDuplicateName(err error) bool {
+	return strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "unique constraint")
+}
+
+// loadCollection parses {id} and fetches the collection, writing a 404 unless
+// the viewer owns it or it is public and its owner is visible to them
+func (h *CollectionHandler) loadCollection(w http.ResponseWriter, r *http.Request, viewerID int) (models.Collection, bool) {
+	collectionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid collection id", http.StatusBadRequest)
+		return models.Collection{}, false
+	}
+
+	collection, err := models.GetCollectionByID(h.DB, collectionID, viewerID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "collection not found", http.StatusNotFound)
+		return models.Collection{}, false
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return models.Collection{}, false
+	}
+
+	if collection.UserID != viewerID {
+		hidden := collection.Visibility != "public"
+		if !hidden {
+			hidden, err = models.IsHiddenFrom(h.DB, collection.UserID, viewerID)
+			if err != nil {
+				http.Error(w, "database error", http.StatusInternalServerError)
+				return models.Collection{}, false
+			}
+		}
+		if hidden {
+			http.Error(w, "collection not found", http.StatusNotFound)
+			return models.Collection{}, false
+		}
+	}
+
+	return collection, true
+}
+
+// loadOwnCollection is loadCollection for changes, which only the owner may make
+func (h *CollectionHandler) loadOwnCollection(w http.ResponseWriter, r *http.Request, userID int) (models.Collection, bool) {
+	collection, ok := h.loadCollection(w, r, userID)
+	if !ok {
+		return models.Collection{}, false
+	}
+	if collection.UserID != userID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return models.Collection{}, false
+	}
+	return collection, true
+}
+
+// GET /me/collections — the user's collections, most recently updated first
+func (h *CollectionHandler) GetMyCollections(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	collections, err := models.GetCollectionsForUser(h.DB, user.UserID, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch collections", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"collections": collections,
+		"count":       len(collections),
+	})
+}
+
+// GET /users/{username}/collections — a user's public collections (all of
+// them on your own profile)
+func (h *CollectionHandler) GetUserCollections(w http.ResponseWriter, r *http.Request) {
+	profileUser, viewer, ok := loadProfileUser(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	collections, err := models.GetCollectionsForUser(h.DB, profileUser.ID, viewer)
+	if err != nil {
+		http.Error(w, "failed to fetch collections", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"collections": collections,
+		"count":       len(collections),
+	})
+}
+
+// POST /collections — create a collection
+func (h *CollectionHandler) CreateCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Visibility == "" {
+		req.Visibility = "private"
+	}
+
+	collection := models.Collection{
+		UserID:      user.UserID,
+		Name:        req.Name,
+		Description: req.Description,
+		Visibility:  req.Visibility,
+	}
+	if msg := validateCollectionFields(&collection); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	created, err := models.CreateCollection(h.DB, collection)
+	if err != nil {
+		if isDuplicateName(err) {
+			http.Error(w, "you already have a collection with that name", http.StatusConflict)
+			return
+		}
+		http.Error(w, "failed to create collection", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, created)
+}
+
+// GET /collections/{id} — a collection with its posts in order. Other users
+// only see public collections, and only the posts they could see anyway.
+func (h *CollectionHandler) GetCollection(w http.ResponseWriter, r *http.Request) {
+	viewer := viewerID(r)
+
+	collection, ok := h.loadCollection(w, r, viewer)
+	if !ok {
+		return
+	}
+
+	posts, err := models.GetCollectionPosts(h.DB, collection, viewer)
+	if err != nil {
+		http.Error(w, "failed to fetch posts", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"collection": collection,
+		"posts":      posts,
+		"count":      len(posts),
+	})
+}
+
+// PATCH /collections/{id} — rename a collection or change its description or visibility
+func (h *CollectionHandler) UpdateCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	collection, ok := h.loadOwnCollection(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	var req UpdateCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name != nil {
+		collection.Name = *req.Name
+	}
+	if req.Description != nil {
+		collection.Description = *req.Description
+	}
+	if req.Visibility != nil {
+		collection.Visibility = *req.Visibility
+	}
+	if msg := validateCollectionFields(&collection); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if err := models.UpdateCollection(h.DB, collection); err != nil {
+		if isDuplicateName(err) {
+			http.Error(w, "you already have a collection with that name", http.StatusConflict)
+			return
+		}
+		http.Error(w, "failed to update collection", http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := models.GetCollectionByID(h.DB, collection.ID, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch updated collection", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, updated)
+}
+
+// DELETE /collections/{id} — delete a collection (its posts are kept)
+func (h *CollectionHandler) DeleteCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	collection, ok := h.loadOwnCollection(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteCollection(h.DB, collection.ID); err != nil {
+		http.Error(w, "failed to delete collection", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "collection deleted"})
+}
+
+// POST /collections/{id}/posts — add one of the user's posts to the end of a collection
+func (h *CollectionHandler) AddCollectionPost(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	collection, ok := h.loadOwnCollection(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	var req AddCollectionPostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Collections only hold the user's own posts
+	post, err := models.GetPostByID(h.DB, req.PostID)
+	if err == sql.ErrNoRows || (err == nil && post.UserID != user.UserID) {
+		http.Error(w, "post not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	err = models.AddPostToCollection(h.DB, collection.ID, post.ID)
+	if err == models.ErrCollectionFull {
+		http.Error(w, "a collection can hold at most 200 posts", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to add post", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "post added to collection"})
+}
+
+// DELETE /collections/{id}/posts/{postId} — remove a post from a collection
+func (h *CollectionHandler) RemoveCollectionPost(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	collection, ok := h.loadOwnCollection(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	postID, err := strconv.Atoi(r.PathValue("postId"))
+	if err != nil {
+		http.Error(w, "invalid post id", http.StatusBadRequest)
+		return
+	}
+
+	err = models.RemovePostFromCollection(h.DB, collection.ID, postID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "post is not in this collection", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to remove post", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "post removed from collection"})
+}
+
+// PUT /collections/{id}/posts — reorder a collection; post_ids lists every
+// post in it in the new order
+func (h *CollectionHandler) ReorderCollection(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	collection, ok := h.loadOwnCollection(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	var req ReorderCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := models.ReorderCollection(h.DB, collection.ID, req.PostIDs)
+	if err == models.ErrCollectionOrder {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to reorder collection", http.StatusInternalServerError)
+		return
+	}
+
+	posts, err := models.GetCollectionPosts(h.DB, collection, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch posts", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"collection": collection,
+		"posts":      posts,
+		"count":      len(posts),
+	})
+}
@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"tomo/backend/events"
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+type CommentHandler struct {
+	DB     *sql.DB
+	Events *events.Bus
+}
+
+const MaxCommentLength = 2000
+
+type CreateCommentRequest struct {
+	Content  string `json:"content"`
+	ParentID *int   `json:"parent_id,omitempty"` // set to reply to a top-level comment
+}
+
+type UpdateCommentRequest struct {
+	Content string `json:"content"`
+}
+
+// canCommentOnPost applies the post's comment_permission to a user who can
+// already view the post (see models.CanViewPost). The post owner can always comment
+// on their own post unless comments are off.
+func canCommentOnPost(db *sql.DB, post models.Post, userID int) (bool, error) {
+	if post.Status != "published" {
+		return false, nil
+	}
+	switch post.CommentPermission {
+	case "everyone":
+		return true, nil
+	case "followers":
+		if post.UserID == userID {
+			return true, nil
+		}
+		return models.IsFollowing(db, userID, post.UserID)
+	default:
+		return false, nil
+	}
+}
+
+// validateCommentContent trims content and checks its length
+func validateCommentContent(content string) (string, string) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return "", "content is required"
+	}
+	if len(content) > MaxCommentLength {
+		return "", "content must be 2000 characters or less"
+	}
+	return content, ""
+}
+
+// GET /posts/{id}/comments — list comments on a post as threads
+func (h *CommentHandler) GetPostComments(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	postID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid post id", http.StatusBadRequest)
+		return
+	}
+
+	post, err := models.GetPostByID(h.DB, postID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "post not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Comments are only visible to those who can see the post
+	visible, err := models.CanViewPost(h.DB, post, user.UserID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if !visible {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	threads, err := models.GetCommentThreadsForPost(h.DB, postID, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch comments", http.StatusInternalServerError)
+		return
+	}
+
+	// Count what the viewer can see; blocked users' comments are left out
+	count := len(threads)
+	for _, t := range threads {
+		count += len(t.Replies)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"comments": threads,
+		"count":    count,
+	})
+}
+
+// POST /posts/{id}/comments — comment on a post or reply to a comment
+func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	postID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid post id", http.StatusBadRequest)
+		return
+	}
+
+	var req CreateCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	content, msg := validateCommentContent(req.Content)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	post, err := models.GetPostByID(h.DB, postID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "post not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	visible, err := models.CanViewPost(h.DB, post, user.UserID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if !visible {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	allowed, err := canCommentOnPost(h.DB, post, user.UserID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		http.Error(w, "forbidden: comments are not allowed on this post", http.StatusForbidden)
+		return
+	}
+
+	// Replies must target a top-level comment on the same post
+	if req.ParentID != nil {
+		parent, err := models.GetCommentByID(h.DB, *req.ParentID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "parent comment not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if parent.PostID != postID {
+			http.Error(w, "parent comment belongs to a different post", http.StatusBadRequest)
+			return
+		}
+		if parent.ParentID != nil {
+			http.Error(w, "replies can only be made to top-level comments", http.StatusBadRequest)
+			return
+		}
+
+		// Blocked users cannot reply to each other
+		blocked, err := models.IsBlockedEitherWay(h.DB, parent.UserID, user.UserID)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if blocked {
+			http.Error(w, "parent comment not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	comment, err := models.CreateComment(h.DB, postID, user.UserID, req.ParentID, content)
+	if err != nil {
+		http.Error(w, "failed to create comment", http.StatusInternalServerError)
+		return
+	}
+
+	h.Events.Publish(events.Event{
+		Type:      events.CommentCreated,
+		ActorID:   user.UserID,
+		PostID:    postID,
+		CommentID: comment.ID,
+	})
+
+	utils.WriteJSON(w, http.StatusCreated, comment)
+}
+
+// PATCH /comments/{id} — edit a comment (author only)
+func (h *CommentHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	commentID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid comment id", http.StatusBadRequest)
+		return
+	}
+
+	comment, err := models.GetCommentByID(h.DB, commentID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "comment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	if comment.UserID != user.UserID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var req UpdateCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	content, msg := validateCommentContent(req.Content)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	updated, err := models.UpdateComment(h.DB, commentID, content)
+	if err != nil {
+		http.Error(w, "failed to update comment", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, updated)
+}
+
+// DELETE /comments/{id} — delete a comment (author or post owner)
+func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	commentID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid comment id", http.StatusBadRequest)
+		return
+	}
+
+	comment, err := models.GetCommentByID(h.DB, commentID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "comment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	if comment.UserID != user.UserID {
+		post, err := models.GetPostByID(h.DB, comment.PostID)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if post.UserID != user.UserID {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	// Replies cascade delete automatically
+	if err := models.DeleteComment(h.DB, commentID); err != nil {
+		http.Error(w, "failed to delete comment", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "comment deleted"})
+}
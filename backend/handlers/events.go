@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tomo/backend/middleware"
+	"tomo/backend/realtime"
+	"tomo/backend/utils"
+)
+
+// HeartbeatInterval keeps idle SSE connections from being closed by proxies
+const HeartbeatInterval = 25 * time.Second
+
+type EventStreamHandler struct {
+	Broker realtime.Broker
+}
+
+// POST /events/ticket — issue a single-use ticket for opening a stream
+// (GET /events?ticket=... or a room WebSocket) from a browser, which can't
+// send an Authorization header on those connections
+func (h *EventStreamHandler) IssueTicket(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ticket, err := middleware.IssueStreamTicket(user)
+	if err != nil {
+		http.Error(w, "failed to issue ticket", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]interface{}{
+		"ticket":     ticket,
+		"expires_in": int(middleware.StreamTicketTTL.Seconds()),
+	})
+}
+
+// GET /events — Server-Sent Events stream of the user's notifications,
+// session and post changes, room timers and new feed posts. Reconnecting clients send Last-Event-ID to
+// receive what they missed; a "resync" event means they should refetch.
+func (h *EventStreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// EventSource sends Last-Event-ID on reconnect; allow a query fallback
+	lastEventIDStr := r.Header.Get("Last-Event-ID")
+	if lastEventIDStr == "" {
+		lastEventIDStr = r.URL.Query().Get("last_event_id")
+	}
+	var lastEventID int64
+	if lastEventIDStr != "" {
+		id, err := strconv.ParseInt(lastEventIDStr, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		lastEventID = id
+	}
+
+	// The server's WriteTimeout would cut the stream off; streams live until the client leaves
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	sub := h.Broker.Subscribe(user.UserID, lastEventID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // disable nginx buffering
+	w.WriteHeader(http.StatusOK)
+
+	// Tell the client how long to wait before reconnecting
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if sub.Gap {
+		fmt.Fprint(w, "event: resync\ndata: {}\n\n")
+	}
+	for _, msg := range sub.Replay {
+		writeSSE(w, msg)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects with Last-Event-ID
+				return
+			}
+			writeSSE(w, msg)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// writeSSE writes a message in text/event-stream format
+func writeSSE(w http.ResponseWriter, msg realtime.Message) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", msg.ID, msg.Event, msg.Data)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+
+	"tomo/backend/events"
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+// POST /users/{username}/follow — follow a user
+func (h *UserHandler) FollowUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	target, ok := h.loadTargetUser(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	// Blocked users are hidden from each other, so answer as if the user doesn't exist
+	blocked, err := models.IsBlockedEitherWay(h.DB, user.UserID, target.ID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	created, err := models.FollowUser(h.DB, user.UserID, target.ID)
+	if err != nil {
+		http.Error(w, "failed to follow user", http.StatusInternalServerError)
+		return
+	}
+	// Following again is a no-op and doesn't notify twice
+	if created {
+		h.Events.Publish(events.Event{Type: events.UserFollowed, ActorID: user.UserID, UserID: target.ID})
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "user followed"})
+}
+
+// DELETE /users/{username}/follow — unfollow a user
+func (h *UserHandler) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	target, ok := h.loadTargetUser(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	if err := models.UnfollowUser(h.DB, user.UserID, target.ID); err != nil {
+		http.Error(w, "failed to unfollow user", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "user unfollowed"})
+}
+
+// GET /me/followers — list users following the current user
+func (h *UserHandler) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	users, err := models.GetFollowers(h.DB, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch followers", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"users": users,
+		"count": len(users),
+	})
+}
+
+// GET /me/following — list users the current user follows
+func (h *UserHandler) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	users, err := models.GetFollowing(h.DB, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch followed users", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"users": users,
+		"count": len(users),
+	})
+}
@@ -0,0 +1,635 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+const InviteCodeLength = 8
+
+type GroupHandler struct {
+	DB *sql.DB
+}
+
+type CreateGroupRequest struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	GoalMinutes *int    `json:"goal_minutes,omitempty"` // 0 clears the goal on update
+	GoalPeriod  *string `json:"goal_period,omitempty"`  // 'day', 'week' or 'month'
+}
+
+type UpdateGroupRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	GoalMinutes *int    `json:"goal_minutes,omitempty"`
+	GoalPeriod  *string `json:"goal_period,omitempty"`
+}
+
+type JoinGroupRequest struct {
+	InviteCode string `json:"invite_code"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role"` // 'admin' or 'member'
+}
+
+type UpdateSharingRequest struct {
+	ShareSessions bool `json:"share_sessions"`
+}
+
+// GoalProgress is a group's progress toward its goal in the current period
+type GoalProgress struct {
+	GoalMinutes     int       `json:"goal_minutes"`
+	Period          string    `json:"period"`
+	PeriodStart     time.Time `json:"period_start"`
+	ProgressMinutes int       `json:"progress_minutes"`
+	Percent         float64   `json:"percent"`
+	Completed       bool      `json:"completed"`
+}
+
+func validPeriod(p string) bool {
+	return p == "day" || p == "week" || p == "month"
+}
+
+// validateGoal checks that a goal is either fully set or cleared
+func validateGoal(minutes *int, period *string) string {
+	if minutes == nil && period == nil {
+		return ""
+	}
+	if minutes == nil || period == nil {
+		return "goal_minutes and goal_period must be set together"
+	}
+	if *minutes < 1 || *minutes > 100000 {
+		return "goal_minutes must be between 1 and 100000"
+	}
+	if !validPeriod(*period) {
+		return "goal_period must be 'day', 'week' or 'month'"
+	}
+	return ""
+}
+
+// loadGroupAsMember parses {id}, fetches the group and the caller's membership,
+// writing an error response on failure
+func (h *GroupHandler) loadGroupAsMember(w http.ResponseWriter, r *http.Request, userID int) (models.StudyGroup, models.GroupMember, bool) {
+	groupID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid group id", http.StatusBadRequest)
+		return models.StudyGroup{}, models.GroupMember{}, false
+	}
+
+	group, err := models.GetGroupByID(h.DB, groupID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "group not found", http.StatusNotFound)
+		return models.StudyGroup{}, models.GroupMember{}, false
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return models.StudyGroup{}, models.GroupMember{}, false
+	}
+
+	member, err := models.GetGroupMember(h.DB, groupID, userID)
+	if err == sql.ErrNoRows {
+		// Don't reveal groups to non-members
+		http.Error(w, "group not found", http.StatusNotFound)
+		return models.StudyGroup{}, models.GroupMember{}, false
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return models.StudyGroup{}, models.GroupMember{}, false
+	}
+
+	return group, member, true
+}
+
+func isGroupManager(m models.GroupMember) bool {
+	return m.Role == "owner" || m.Role == "admin"
+}
+
+// goalProgress computes progress for the current period, or nil if there is no goal
+func (h *GroupHandler) goalProgress(g models.StudyGroup) (*GoalProgress, error) {
+	if g.GoalMinutes == nil || g.GoalPeriod == nil {
+		return nil, nil
+	}
+
+	start := models.PeriodStart(*g.GoalPeriod, time.Now())
+	total, err := models.GetGroupTotalMinutes(h.DB, g.ID, start)
+	if err != nil {
+		return nil, err
+	}
+
+	percent := float64(total) / float64(*g.GoalMinutes) * 100
+	if percent > 100 {
+		percent = 100
+	}
+
+	return &GoalProgress{
+		GoalMinutes:     *g.GoalMinutes,
+		Period:          *g.GoalPeriod,
+		PeriodStart:     start,
+		ProgressMinutes: total,
+		Percent:         percent,
+		Completed:       total >= *g.GoalMinutes,
+	}, nil
+}
+
+// POST /groups — create a study group
+func (h *GroupHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 60 {
+		http.Error(w, "name must be between 1 and 60 characters", http.StatusBadRequest)
+		return
+	}
+	description := strings.TrimSpace(req.Description)
+	if len(description) > 500 {
+		http.Error(w, "description must be 500 characters or less", http.StatusBadRequest)
+		return
+	}
+	if msg := validateGoal(req.GoalMinutes, req.GoalPeriod); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	code, err := utils.RandomCode(InviteCodeLength)
+	if err != nil {
+		http.Error(w, "failed to create invite code", http.StatusInternalServerError)
+		return
+	}
+
+	group, err := models.CreateGroup(h.DB, user.UserID, name, description, code, req.GoalMinutes, req.GoalPeriod)
+	if err != nil {
+		http.Error(w, "failed to create group", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, group)
+}
+
+// GET /groups — list the user's groups
+func (h *GroupHandler) GetMyGroups(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	list, err := models.GetGroupsForUser(h.DB, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch groups", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"groups": list,
+		"count":  len(list),
+	})
+}
+
+// GET /groups/{id} — group details, members and goal progress (members only)
+func (h *GroupHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	group, _, ok := h.loadGroupAsMember(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	members, err := models.GetGroupMembers(h.DB, group.ID)
+	if err != nil {
+		http.Error(w, "failed to fetch members", http.StatusInternalServerError)
+		return
+	}
+
+	progress, err := h.goalProgress(group)
+	if err != nil {
+		http.Error(w, "failed to compute goal progress", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"group":   group,
+		"members": members,
+		"goal":    progress,
+	})
+}
+
+// PATCH /groups/{id} — update name, description or goal (owner/admin)
+func (h *GroupHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	group, member, ok := h.loadGroupAsMember(w, r, user.UserID)
+	if !ok {
+		return
+	}
+	if !isGroupManager(member) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var req UpdateGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > 60 {
+			http.Error(w, "name must be between 1 and 60 characters", http.StatusBadRequest)
+			return
+		}
+		group.Name = name
+	}
+	if req.Description != nil {
+		description := strings.TrimSpace(*req.Description)
+		if len(description) > 500 {
+			http.Error(w, "description must be 500 characters or less", http.StatusBadRequest)
+			return
+		}
+		group.Description = description
+	}
+
+	// goal_minutes: 0 removes the goal
+	if req.GoalMinutes != nil && *req.GoalMinutes == 0 {
+		group.GoalMinutes = nil
+		group.GoalPeriod = nil
+	} else if req.GoalMinutes != nil || req.GoalPeriod != nil {
+		minutes, period := req.GoalMinutes, req.GoalPeriod
+		if minutes == nil {
+			minutes = group.GoalMinutes
+		}
+		if period == nil {
+			period = group.GoalPeriod
+		}
+		if msg := validateGoal(minutes, period); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		group.GoalMinutes = minutes
+		group.GoalPeriod = period
+	}
+
+	if err := models.UpdateGroup(h.DB, group.ID, group.Name, group.Description, group.GoalMinutes, group.GoalPeriod); err != nil {
+		http.Error(w, "failed to update group", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, group)
+}
+
+// DELETE /groups/{id} — delete a group (owner only)
+func (h *GroupHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	group, member, ok := h.loadGroupAsMember(w, r, user.UserID)
+	if !ok {
+		return
+	}
+	if member.Role != "owner" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	if err := models.DeleteGroup(h.DB, group.ID); err != nil {
+		http.Error(w, "failed to delete group", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "group deleted"})
+}
+
+// POST /groups/join — join a group with an invite code
+func (h *GroupHandler) JoinGroup(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req JoinGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(req.InviteCode))
+	if code == "" {
+		http.Error(w, "invite_code is required", http.StatusBadRequest)
+		return
+	}
+
+	group, err := models.GetGroupByInviteCode(h.DB, code)
+	if err == sql.ErrNoRows {
+		http.Error(w, "invalid invite code", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := models.AddGroupMember(h.DB, group.ID, user.UserID); err != nil {
+		http.Error(w, "failed to join group", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, group)
+}
+
+// POST /groups/{id}/invite-code — replace the invite code (owner/admin)
+func (h *GroupHandler) RegenerateInviteCode(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	group, member, ok := h.loadGroupAsMember(w, r, user.UserID)
+	if !ok {
+		return
+	}
+	if !isGroupManager(member) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	code, err := utils.RandomCode(InviteCodeLength)
+	if err != nil {
+		http.Error(w, "failed to create invite code", http.StatusInternalServerError)
+		return
+	}
+	if err := models.UpdateGroupInviteCode(h.DB, group.ID, code); err != nil {
+		http.Error(w, "failed to update invite code", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"invite_code": code})
+}
+
+// PATCH /groups/{id}/members/{userId} — change a member's role (owner only)
+func (h *GroupHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	group, member, ok := h.loadGroupAsMember(w, r, user.UserID)
+	if !ok {
+		return
+	}
+	if member.Role != "owner" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	targetID, err := strconv.Atoi(r.PathValue("userId"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+	if targetID == user.UserID {
+		http.Error(w, "the owner's role cannot be changed", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Role != "admin" && req.Role != "member" {
+		http.Error(w, "role must be 'admin' or 'member'", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := models.GetGroupMember(h.DB, group.ID, targetID); err == sql.ErrNoRows {
+		http.Error(w, "member not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := models.UpdateGroupMemberRole(h.DB, group.ID, targetID, req.Role); err != nil {
+		http.Error(w, "failed to update member", http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := models.GetGroupMember(h.DB, group.ID, targetID)
+	if err != nil {
+		http.Error(w, "failed to fetch member", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, updated)
+}
+
+// DELETE /groups/{id}/members/{userId} — leave a group, or remove a member (owner/admin)
+func (h *GroupHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	group, member, ok := h.loadGroupAsMember(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	targetID, err := strconv.Atoi(r.PathValue("userId"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	target, err := models.GetGroupMember(h.DB, group.ID, targetID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "member not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	if target.Role == "owner" {
+		http.Error(w, "the owner cannot leave; delete the group instead", http.StatusBadRequest)
+		return
+	}
+	if targetID != user.UserID {
+		// Admins may only remove regular members; the owner may remove anyone
+		if !isGroupManager(member) || (member.Role == "admin" && target.Role != "member") {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	if err := models.RemoveGroupMember(h.DB, group.ID, targetID); err != nil {
+		http.Error(w, "failed to remove member", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "member removed"})
+}
+
+// PATCH /groups/{id}/me — choose whether the group may see your individual sessions
+func (h *GroupHandler) UpdateMySharing(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	group, _, ok := h.loadGroupAsMember(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	var req UpdateSharingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.UpdateGroupMemberSharing(h.DB, group.ID, user.UserID, req.ShareSessions); err != nil {
+		http.Error(w, "failed to update sharing", http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := models.GetGroupMember(h.DB, group.ID, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch member", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, updated)
+}
+
+// GET /groups/{id}/leaderboard?period=day|week|month — members ranked by focus minutes
+func (h *GroupHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	group, _, ok := h.loadGroupAsMember(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "week"
+	}
+	if !validPeriod(period) {
+		http.Error(w, "period must be 'day', 'week' or 'month'", http.StatusBadRequest)
+		return
+	}
+
+	start := models.PeriodStart(period, time.Now())
+	entries, err := models.GetGroupLeaderboard(h.DB, group.ID, start)
+	if err != nil {
+		http.Error(w, "failed to fetch leaderboard", http.StatusInternalServerError)
+		return
+	}
+
+	total := 0
+	for _, e := range entries {
+		total += e.TotalMinutes
+	}
+
+	progress, err := h.goalProgress(group)
+	if err != nil {
+		http.Error(w, "failed to compute goal progress", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"period":        period,
+		"period_start":  start,
+		"leaderboard":   entries,
+		"total_minutes": total,
+		"goal":          progress,
+	})
+}
+
+// GET /groups/{id}/members/{userId}/sessions — a member's individual sessions,
+// unless they only share totals
+func (h *GroupHandler) GetMemberSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	group, _, ok := h.loadGroupAsMember(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	targetID, err := strconv.Atoi(r.PathValue("userId"))
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	target, err := models.GetGroupMember(h.DB, group.ID, targetID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "member not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	if !target.ShareSessions && targetID != user.UserID {
+		http.Error(w, "forbidden: this member only shares totals", http.StatusForbidden)
+		return
+	}
+
+	sessions, err := models.GetSessionsByUserID(h.DB, targetID, 50, 0)
+	if err != nil {
+		http.Error(w, "failed to fetch sessions", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"sessions": sessions,
+		"count":    len(sessions),
+	})
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"tomo/backend/events"
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+// MaxJournalDays caps how many days GET /journal returns at once
+const MaxJournalDays = 31
+
+// JournalEntryRequest replaces a day's journal entry. Visibility and
+// comment_permission are kept when omitted (new entries are private); tags,
+// when given, replace the entry's tags.
+type JournalEntryRequest struct {
+	Title             string    `json:"title,omitempty"`
+	Content           string    `json:"content,omitempty"`
+	MoodRating        *int      `json:"mood_rating,omitempty"`
+	Visibility        string    `json:"visibility,omitempty"`
+	CommentPermission string    `json:"comment_permission,omitempty"`
+	Tags              *[]string `json:"tags,omitempty"`
+}
+
+// journalToday loads the user's timezone and returns today's date there,
+// writing an error response on failure
+func (h *PostHandler) journalToday(w http.ResponseWriter, userID int) (models.User, time.Time, bool) {
+	dbUser, err := models.GetUserByID(h.DB, userID)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return models.User{}, time.Time{}, false
+	}
+
+	date, err := models.CalendarDate(time.Now(), dbUser.Timezone)
+	if err != nil {
+		http.Error(w, "invalid timezone", http.StatusInternalServerError)
+		return models.User{}, time.Time{}, false
+	}
+	today, _ := time.Parse(models.JournalDateLayout, date)
+	return dbUser, today, true
+}
+
+// parseJournalDate reads {date} and rejects days that haven't started yet in
+// the user's timezone
+func parseJournalDate(w http.ResponseWriter, r *http.Request, today time.Time) (string, bool) {
+	date, err := time.Parse(models.JournalDateLayout, r.PathValue("date"))
+	if err != nil {
+		http.Error(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+		return "", false
+	}
+	if date.After(today) {
+		http.Error(w, "cannot journal a future date", http.StatusBadRequest)
+		return "", false
+	}
+	return date.Format(models.JournalDateLayout), true
+}
+
+// GET /journal?from=&to= — the user's days (newest first, last 7 by default),
+// each with its journal entry and that day's focus sessions and session posts
+func (h *PostHandler) GetJournal(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	dbUser, today, ok := h.journalToday(w, user.UserID)
+	if !ok {
+		return
+	}
+
+	to := today
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse(models.JournalDateLayout, v)
+		if err != nil {
+			http.Error(w, "to must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -6)
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse(models.JournalDateLayout, v)
+		if err != nil {
+			http.Error(w, "from must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+	if from.After(to) {
+		http.Error(w, "from cannot be after to", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) >= MaxJournalDays*24*time.Hour {
+		http.Error(w, "at most 31 days can be requested at once", http.StatusBadRequest)
+		return
+	}
+
+	days, err := models.GetJournalDays(h.DB, user.UserID, dbUser.Timezone, from, to)
+	if err != nil {
+		http.Error(w, "failed to fetch journal", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"timezone": dbUser.Timezone,
+		"days":     days,
+	})
+}
+
+// GET /journal/{date} — the user's journal entry for a day
+func (h *PostHandler) GetJournalEntry(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	_, today, ok := h.journalToday(w, user.UserID)
+	if !ok {
+		return
+	}
+	date, ok := parseJournalDate(w, r, today)
+	if !ok {
+		return
+	}
+
+	entry, err := models.GetJournalEntry(h.DB, user.UserID, date)
+	if err == sql.ErrNoRows {
+		http.Error(w, "no journal entry for this date", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	postDetails, err := models.GetPostWithDetails(h.DB, entry.ID, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch post details", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, postDetails)
+}
+
+// PUT /journal/{date} — create or replace the user's journal entry for a day
+func (h *PostHandler) PutJournalEntry(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	_, today, ok := h.journalToday(w, user.UserID)
+	if !ok {
+		return
+	}
+	date, ok := parseJournalDate(w, r, today)
+	if !ok {
+		return
+	}
+
+	var req JournalEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validation
+	if strings.TrimSpace(req.Title) == "" && strings.TrimSpace(req.Content) == "" {
+		http.Error(w, "title or content is required", http.StatusBadRequest)
+		return
+	}
+	if req.MoodRating != nil && (*req.MoodRating < 1 || *req.MoodRating > 5) {
+		http.Error(w, "mood_rating must be between 1 and 5", http.StatusBadRequest)
+		return
+	}
+	if req.Visibility != "" && !validPostVisibility(req.Visibility) {
+		http.Error(w, "visibility must be 'private', 'unlisted' or 'public'", http.StatusBadRequest)
+		return
+	}
+	if req.CommentPermission != "" && !validCommentPermission(req.CommentPermission) {
+		http.Error(w, "comment_permission must be 'off', 'followers' or 'everyone'", http.StatusBadRequest)
+		return
+	}
+
+	// #hashtags in the content are attached like any other tag
+	var edit models.TagEdit
+	if req.Tags != nil {
+		tags, err := models.NormalizeTags(*req.Tags)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		edit.Replace = &tags
+	}
+	edit.Add = models.ParseHashtags(req.Content)
+
+	created := false
+	entry, err := models.GetJournalEntry(h.DB, user.UserID, date)
+	if err == sql.ErrNoRows {
+		visibility := req.Visibility
+		if visibility == "" {
+			visibility = "private"
+		}
+		commentPermission := req.CommentPermission
+		if commentPermission == "" {
+			commentPermission = "everyone"
+		}
+
+		tags := edit.Add
+		if edit.Replace != nil {
+			tags = append(*edit.Replace, tags...)
+		}
+		entry, err = models.CreatePostWithTags(h.DB, models.Post{
+			UserID:            user.UserID,
+			PostType:          "daily",
+			JournalDate:       &date,
+			Content:           req.Content,
+			Title:             req.Title,
+			MoodRating:        req.MoodRating,
+			Visibility:        visibility,
+			CommentPermission: commentPermission,
+			Status:            "published",
+		}, tags, nil, h.markdownOptions())
+		if err == nil {
+			created = true
+		} else if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "unique constraint") {
+			// A concurrent request created the entry first; update it instead
+			entry, err = models.GetJournalEntry(h.DB, user.UserID, date)
+		}
+	}
+	if err != nil {
+		http.Error(w, "failed to save journal entry", http.StatusInternalServerError)
+		return
+	}
+
+	if !created {
+		entry.Title = req.Title
+		entry.Content = req.Content
+		entry.MoodRating = req.MoodRating
+		if req.Visibility != "" {
+			entry.Visibility = req.Visibility
+		}
+		if req.CommentPermission != "" {
+			entry.CommentPermission = req.CommentPermission
+		}
+		if err := models.UpdatePostWithTags(h.DB, entry, edit, nil, h.markdownOptions()); err != nil {
+			http.Error(w, "failed to save journal entry", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	postDetails, err := models.GetPostWithDetails(h.DB, entry.ID, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch post details", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+		if entry.Status == "published" {
+			h.Events.Publish(events.Event{Type: events.PostCreated, ActorID: user.UserID, PostID: entry.ID})
+		}
+	} else {
+		h.Events.Publish(events.Event{Type: events.PostUpdated, ActorID: user.UserID, PostID: entry.ID})
+	}
+	h.syncMentions(entry.ID)
+
+	utils.WriteJSON(w, status, postDetails)
+}
@@ -1,21 +1,37 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log"
+	"mime"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"tomo/backend/middleware"
 	"tomo/backend/models"
+	"tomo/backend/storage"
 	"tomo/backend/utils"
 )
 
 type MediaHandler struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Storage storage.Storage
+}
+
+// fileURL is the public URL of the file stored under key
+func (h *MediaHandler) fileURL(key string) string {
+	return storage.URL(h.Storage, key)
 }
 
 const (
@@ -23,18 +39,28 @@ const (
 	MaxMediaPerPost  = 3
 	AllowedImageExts = ".jpg,.jpeg,.png,.gif,.webp"
 	AllowedVideoExts = ".mp4,.mov,.avi,.webm"
+
+	// How long a presigned upload stays valid before its pending media is discarded
+	PendingUploadTTL = 15 * time.Minute
 )
 
 type UploadMediaRequest struct {
 	PostID           int    `json:"post_id"`
 	MediaType        string `json:"media_type"` // 'image' or 'video'
-	FileURL          string `json:"file_url"`   // URL after uploading to S3/R2
+	FileURL          string `json:"file_url"`   // URL after uploading to media storage
+	OriginalFilename string `json:"original_filename,omitempty"`
+}
+
+type PresignMediaRequest struct {
+	ContentType      string `json:"content_type"` // e.g. "image/jpeg"
+	SizeBytes        int64  `json:"size_bytes"`
 	OriginalFilename string `json:"original_filename,omitempty"`
 }
 
 // POST /posts/{id}/media — upload media to a post
-// NOTE: This expects the file to already be uploaded to S3/R2
-// and you're just registering the URL in the database
+// NOTE: This expects the file to already be uploaded to media storage
+// and you're just registering the URL in the database.
+// Prefer POST /posts/{id}/media/presign, which verifies the upload.
 func (h *MediaHandler) AddMediaToPost(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
 	if !ok {
@@ -87,14 +113,38 @@ func (h *MediaHandler) AddMediaToPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate file URL
+	// Validate file URL (only the user's own completed uploads can be attached)
 	if req.FileURL == "" {
 		http.Error(w, "file_url is required", http.StatusBadRequest)
 		return
 	}
+	key, ok := storage.KeyFromURL(h.Storage, req.FileURL)
+	if !ok {
+		http.Error(w, "file_url must point to an uploaded file", http.StatusBadRequest)
+		return
+	}
+	obj, err := models.GetMediaObjectByKey(h.DB, key)
+	if err == sql.ErrNoRows || (err == nil && (obj.UserID != user.UserID || obj.SHA256 == "")) {
+		http.Error(w, "file_url must point to an uploaded file", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
 
 	// Add media to database
-	media, err := models.AddMediaToPost(h.DB, postID, user.UserID, req.MediaType, req.FileURL, req.OriginalFilename, count)
+	media, _, err := models.AddMediaToPost(h.DB, models.PostMedia{
+		PostID:           postID,
+		UserID:           user.UserID,
+		MediaType:        req.MediaType,
+		OriginalFilename: req.OriginalFilename,
+		Position:         count,
+	}, obj, h.fileURL)
+	if err == sql.ErrNoRows {
+		http.Error(w, "file_url must point to an uploaded file", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "failed to add media", http.StatusInternalServerError)
 		return
@@ -170,27 +220,346 @@ func (h *MediaHandler) UploadMediaFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Read file content
-	fileBytes, err := io.ReadAll(file)
+	contentType := mime.TypeByExtension(ext)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	// Identical files the user uploaded before are stored once
+	sum, err := hashFile(file, header.Size)
 	if err != nil {
 		http.Error(w, "failed to read file", http.StatusInternalServerError)
 		return
 	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, "failed to read file", http.StatusInternalServerError)
+		return
+	}
+
+	media := models.PostMedia{
+		PostID:           postID,
+		UserID:           user.UserID,
+		MediaType:        mediaType,
+		OriginalFilename: header.Filename,
+		Position:         count,
+	}
+	var added models.PostMedia
+	var unused []string
+	obj, err := models.GetMediaObjectByHash(h.DB, user.UserID, sum)
+	if err == nil {
+		// Reuse the stored copy, unless it was deleted since the lookup
+		added, unused, err = models.AddMediaToPost(h.DB, media, obj, h.fileURL)
+	}
+	if err == sql.ErrNoRows {
+		key, err := newUploadKey(user.UserID, ext)
+		if err != nil {
+			http.Error(w, "failed to upload media", http.StatusInternalServerError)
+			return
+		}
+
+		// Stream the file to storage
+		if err := h.Storage.Put(r.Context(), key, file, header.Size, contentType); err != nil {
+			log.Printf("failed to upload media for post %d: %v", postID, err)
+			http.Error(w, "failed to upload media", http.StatusInternalServerError)
+			return
+		}
+
+		obj = models.MediaObject{UserID: user.UserID, StorageKey: key, SHA256: sum, SizeBytes: header.Size, ContentType: contentType}
+		added, unused, err = models.AddMediaToPost(h.DB, media, obj, h.fileURL)
+		if err != nil {
+			deleteStoredFiles(h.Storage, []string{key})
+			http.Error(w, "failed to save media", http.StatusInternalServerError)
+			return
+		}
+	} else if err != nil {
+		http.Error(w, "failed to save media", http.StatusInternalServerError)
+		return
+	}
+	deleteStoredFiles(h.Storage, unused)
+
+	utils.WriteJSON(w, http.StatusCreated, added)
+}
+
+// hashFile returns the hex SHA-256 of r, which must hold exactly size bytes
+func hashFile(r io.Reader, size int64) (string, error) {
+	hash := sha256.New()
+	n, err := io.Copy(hash, io.LimitReader(r, size+1))
+	if err != nil {
+		return "", err
+	}
+	if n != size {
+		return "", fmt.Errorf("expected %d bytes, got %d", size, n)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// errUploadMismatch means an uploaded file is not the size that was signed for
+var errUploadMismatch = errors.New("uploaded file does not match")
+
+// copyUpload copies the size bytes stored under src to dst and returns the hex
+// SHA-256 of what was copied. The file is buffered in a temporary file so the
+// hash always matches the stored copy, whatever happens to src meanwhile.
+func (h *MediaHandler) copyUpload(ctx context.Context, src, dst string, size int64, contentType string) (string, error) {
+	body, _, err := h.Storage.Get(ctx, src)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp("", "tomo-upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	sum, err := hashFile(io.TeeReader(body, tmp), size)
+	if err != nil {
+		return "", errUploadMismatch
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if err := h.Storage.Put(ctx, dst, tmp, size, contentType); err != nil {
+		return "", err
+	}
+	return sum, nil
+}
+
+// deleteStoredFiles removes files no media uses any more. Failures only leave
+// an orphaned file behind, so they are logged.
+func deleteStoredFiles(store storage.Storage, keys []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			log.Printf("failed to delete stored file %s: %v", key, err)
+		}
+	}
+}
+
+// discardPendingMedia deletes a pending upload and its file
+func (h *MediaHandler) discardPendingMedia(mediaID int) {
+	unused, err := models.DeleteMedia(h.DB, mediaID)
+	if err != nil {
+		log.Printf("failed to delete pending media %d: %v", mediaID, err)
+		return
+	}
+	deleteStoredFiles(h.Storage, unused)
+}
+
+// newUploadKey returns a random storage key for a user's upload. Keys are never
+// reused, so uploads can't overwrite each other.
+func newUploadKey(userID int, ext string) (string, error) {
+	name, err := utils.RandomSlug(24)
+	if err != nil {
+		return "", err
+	}
+	return "uploads/" + strconv.Itoa(userID) + "/" + name + ext, nil
+}
+
+// POST /posts/{id}/media/presign — reserve a media slot on a post and return a
+// signed URL to PUT the file straight to storage. The media stays pending until
+// POST /media/{id}/complete, and is discarded after PendingUploadTTL.
+func (h *MediaHandler) PresignMediaUpload(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	postIDStr := r.PathValue("id")
+	postID, err := strconv.Atoi(postIDStr)
+	if err != nil {
+		http.Error(w, "invalid post id", http.StatusBadRequest)
+		return
+	}
+
+	// Verify post ownership
+	post, err := models.GetPostByID(h.DB, postID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "post not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if post.UserID != user.UserID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var req PresignMediaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate content type and size
+	contentType, _, err := mime.ParseMediaType(req.ContentType)
+	if err != nil {
+		http.Error(w, "content_type is required", http.StatusBadRequest)
+		return
+	}
+	mediaType, ext, ok := models.UploadMediaType(contentType)
+	if !ok {
+		http.Error(w, "unsupported file type", http.StatusBadRequest)
+		return
+	}
+	if req.SizeBytes <= 0 || req.SizeBytes > MaxFileSize {
+		http.Error(w, "size_bytes must be between 1 and 10MB", http.StatusBadRequest)
+		return
+	}
 
-	fileURL, err := utils.UploadToS3(r.Context(), fileBytes, header.Filename, mediaType)
+	// Pending uploads count toward the limit
+	count, err := models.CountMediaForPost(h.DB, postID)
 	if err != nil {
-		http.Error(w, "failed to upload media", http.StatusInternalServerError)
+		http.Error(w, "failed to count media", http.StatusInternalServerError)
+		return
+	}
+	if count >= MaxMediaPerPost {
+		http.Error(w, "maximum 3 media items per post", http.StatusBadRequest)
+		return
+	}
+
+	key, err := newUploadKey(user.UserID, ext)
+	if err != nil {
+		http.Error(w, "failed to create upload", http.StatusInternalServerError)
 		return
 	}
 
-	// Save to database
-	media, err := models.AddMediaToPost(h.DB, postID, user.UserID, mediaType, fileURL, header.Filename, count)
+	upload, err := h.Storage.Presign(r.Context(), key, contentType, req.SizeBytes, PendingUploadTTL)
+	if err != nil {
+		log.Printf("failed to presign upload for post %d: %v", postID, err)
+		http.Error(w, "failed to create upload", http.StatusInternalServerError)
+		return
+	}
+
+	media, err := models.CreatePendingMedia(h.DB, models.PostMedia{
+		PostID:           postID,
+		UserID:           user.UserID,
+		MediaType:        mediaType,
+		Position:         count,
+		OriginalFilename: req.OriginalFilename,
+		ContentType:      contentType,
+		SizeBytes:        req.SizeBytes,
+	}, key, time.Now().Add(PendingUploadTTL), h.fileURL)
+	if err != nil {
+		http.Error(w, "failed to create upload", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]interface{}{
+		"media":      media,
+		"upload_url": upload.URL,
+		"method":     upload.Method,
+		"headers":    upload.Headers,
+	})
+}
+
+// POST /media/{id}/complete — check that a pending upload's file is in storage
+// with the size and content type it was signed for, then move it to its final
+// key and attach it to the post
+func (h *MediaHandler) CompleteMediaUpload(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	mediaIDStr := r.PathValue("id")
+	mediaID, err := strconv.Atoi(mediaIDStr)
+	if err != nil {
+		http.Error(w, "invalid media id", http.StatusBadRequest)
+		return
+	}
+
+	media, err := models.GetMediaByID(h.DB, mediaID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "media not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if media.UserID != user.UserID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	// Completing twice is harmless
+	if media.Status == "active" {
+		utils.WriteJSON(w, http.StatusOK, media)
+		return
+	}
+	if media.ExpiresAt != nil && !media.ExpiresAt.After(time.Now()) {
+		http.Error(w, "upload expired", http.StatusGone)
+		return
+	}
+
+	key, ok := storage.KeyFromURL(h.Storage, media.FileURL)
+	if !ok {
+		http.Error(w, "invalid media", http.StatusInternalServerError)
+		return
+	}
+
+	info, err := h.Storage.Stat(r.Context(), key)
+	if err == storage.ErrNotFound {
+		http.Error(w, "file has not been uploaded", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("failed to check upload for media %d: %v", mediaID, err)
+		http.Error(w, "failed to check upload", http.StatusBadGateway)
+		return
+	}
+
+	if info.Size != media.SizeBytes || info.ContentType != media.ContentType {
+		// Discard the mismatched file and free the slot; the client can presign again
+		h.discardPendingMedia(mediaID)
+		http.Error(w, "uploaded file does not match the requested size and content type", http.StatusBadRequest)
+		return
+	}
+
+	// The presigned URL stays valid until it expires, so the media must not
+	// use the upload key. Copy the file to a key only the server knows,
+	// hashing exactly the bytes that are copied.
+	_, ext, _ := models.UploadMediaType(media.ContentType)
+	finalKey, err := newUploadKey(user.UserID, ext)
 	if err != nil {
 		http.Error(w, "failed to save media", http.StatusInternalServerError)
 		return
 	}
+	sum, err := h.copyUpload(r.Context(), key, finalKey, media.SizeBytes, media.ContentType)
+	if err == errUploadMismatch {
+		h.discardPendingMedia(mediaID)
+		http.Error(w, "uploaded file does not match the requested size and content type", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("failed to copy upload for media %d: %v", mediaID, err)
+		http.Error(w, "failed to check upload", http.StatusBadGateway)
+		return
+	}
 
-	utils.WriteJSON(w, http.StatusCreated, media)
+	media, unused, err := models.CompleteMedia(h.DB, mediaID, sum, finalKey, h.fileURL)
+	if err != nil {
+		deleteStoredFiles(h.Storage, []string{finalKey})
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "upload expired", http.StatusGone)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to save media", http.StatusInternalServerError)
+		return
+	}
+	deleteStoredFiles(h.Storage, unused)
+
+	utils.WriteJSON(w, http.StatusOK, media)
 }
 
 // GET /posts/{id}/media — get all media for a post
@@ -220,7 +589,12 @@ func (h *MediaHandler) GetPostMedia(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check visibility
-	if post.Visibility == "private" && post.UserID != user.UserID {
+	visible, err := models.CanViewPost(h.DB, post, user.UserID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if !visible {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -268,12 +642,12 @@ func (h *MediaHandler) DeleteMedia(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// post handler deletes from s3!
-
-	if err := models.DeleteMedia(h.DB, mediaID); err != nil {
+	unused, err := models.DeleteMedia(h.DB, mediaID)
+	if err != nil {
 		http.Error(w, "failed to delete media", http.StatusInternalServerError)
 		return
 	}
+	deleteStoredFiles(h.Storage, unused)
 
 	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "media deleted"})
 }
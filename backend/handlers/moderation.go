@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+// ModerationHandler serves the moderation queue to moderators and admins
+type ModerationHandler struct {
+	DB *sql.DB
+}
+
+type ResolveReportRequest struct {
+	Status string `json:"status"` // 'resolved' (action taken) or 'dismissed'
+	Note   string `json:"note,omitempty"`
+}
+
+// GET /admin/reports?status=open|resolved|dismissed — list reports (default: open)
+func (h *ModerationHandler) GetReports(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "open"
+	}
+	if status != "open" && status != "resolved" && status != "dismissed" {
+		http.Error(w, "status must be 'open', 'resolved' or 'dismissed'", http.StatusBadRequest)
+		return
+	}
+
+	limit := 50
+	offset := 0
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	reports, err := models.GetReports(h.DB, status, limit, offset)
+	if err != nil {
+		http.Error(w, "failed to fetch reports", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"reports": reports,
+		"count":   len(reports),
+	})
+}
+
+// POST /admin/reports/{id}/resolve — close a report and every other open report on the same target
+func (h *ModerationHandler) ResolveReport(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	reportID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid report id", http.StatusBadRequest)
+		return
+	}
+
+	var req ResolveReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Status != "resolved" && req.Status != "dismissed" {
+		http.Error(w, "status must be 'resolved' or 'dismissed'", http.StatusBadRequest)
+		return
+	}
+	note := strings.TrimSpace(req.Note)
+	if len(note) > MaxReportDetailsLength {
+		http.Error(w, "note must be 1000 characters or less", http.StatusBadRequest)
+		return
+	}
+
+	report, err := models.GetReportByID(h.DB, reportID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "report not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if report.Status != "open" {
+		http.Error(w, "report is already closed", http.StatusConflict)
+		return
+	}
+
+	closed, err := models.ResolveReportsForTarget(h.DB, report.TargetType, report.TargetID, req.Status, user.UserID, note)
+	if err != nil {
+		http.Error(w, "failed to resolve report", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "report " + req.Status,
+		"closed":  closed,
+	})
+}
+
+// setModerationState hides or restores {type}/{id}, where type is post, comment or media
+func (h *ModerationHandler) setModerationState(w http.ResponseWriter, r *http.Request, state string) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	targetType := r.PathValue("type")
+	switch targetType {
+	case "post":
+		_, err = models.GetPostByID(h.DB, id)
+		if err == nil {
+			err = models.SetPostModerationState(h.DB, id, state)
+		}
+	case "comment":
+		_, err = models.GetCommentByID(h.DB, id)
+		if err == nil {
+			err = models.SetCommentModerationState(h.DB, id, state)
+		}
+	case "media":
+		_, err = models.GetMediaByID(h.DB, id)
+		if err == nil {
+			err = models.SetMediaModerationState(h.DB, id, state)
+		}
+	default:
+		http.Error(w, "type must be 'post', 'comment' or 'media'", http.StatusBadRequest)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, targetType+" not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to update "+targetType, http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": targetType + " " + state})
+}
+
+// POST /admin/content/{type}/{id}/hide — hide a post, comment or media item from everyone but its owner
+func (h *ModerationHandler) HideContent(w http.ResponseWriter, r *http.Request) {
+	h.setModerationState(w, r, "hidden")
+}
+
+// POST /admin/content/{type}/{id}/unhide — restore hidden content
+func (h *ModerationHandler) UnhideContent(w http.ResponseWriter, r *http.Request) {
+	h.setModerationState(w, r, "visible")
+}
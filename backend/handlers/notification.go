@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+type NotificationHandler struct {
+	DB *sql.DB
+}
+
+// notificationSummary renders a group as text, e.g. "3 people commented on your post"
+func notificationSummary(g models.NotificationGroup) string {
+	who := "Someone"
+	if len(g.Actors) > 0 {
+		who = g.Actors[0].DisplayName
+		if who == "" {
+			who = g.Actors[0].Username
+		}
+	}
+	if g.ActorCount > 1 {
+		who = fmt.Sprintf("%d people", g.ActorCount)
+	}
+
+	switch g.Type {
+	case "follow":
+		return who + " followed you"
+	case "reaction":
+		return who + " reacted to your post"
+	case "comment":
+		return who + " commented on your post"
+	case "reply":
+		return who + " replied to your comment"
+	case "goal_completed":
+		return "You completed a goal"
+	case "mention":
+		return who + " mentioned you in a post"
+	default:
+		return "You have a new notification"
+	}
+}
+
+// GET /notifications — list grouped notifications with the unread count
+func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Pagination (default: 30 groups per page)
+	limit := 30
+	offset := 0
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	groups, err := models.GetNotificationGroups(h.DB, user.UserID, limit, offset)
+	if err != nil {
+		http.Error(w, "failed to fetch notifications", http.StatusInternalServerError)
+		return
+	}
+
+	unread, err := models.CountUnreadNotificationGroups(h.DB, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to count notifications", http.StatusInternalServerError)
+		return
+	}
+
+	type groupResponse struct {
+		models.NotificationGroup
+		Summary string `json:"summary"`
+	}
+	items := make([]groupResponse, 0, len(groups))
+	for _, g := range groups {
+		items = append(items, groupResponse{NotificationGroup: g, Summary: notificationSummary(g)})
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"notifications": items,
+		"unread_count":  unread,
+	})
+}
+
+// GET /notifications/unread-count — number of unread notification groups
+func (h *NotificationHandler) GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	unread, err := models.CountUnreadNotificationGroups(h.DB, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to count notifications", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]int{"unread_count": unread})
+}
+
+// POST /notifications/{id}/read — mark a notification (and the rest of its group) as read
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	notificationID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid notification id", http.StatusBadRequest)
+		return
+	}
+
+	n, err := models.GetNotificationByID(h.DB, notificationID)
+	if err == sql.ErrNoRows || (err == nil && n.UserID != user.UserID) {
+		http.Error(w, "notification not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := models.MarkNotificationGroupRead(h.DB, user.UserID, n.Type, n.GroupKey); err != nil {
+		http.Error(w, "failed to mark notification read", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "notification marked read"})
+}
+
+// POST /notifications/read-all — mark every notification as read
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := models.MarkAllNotificationsRead(h.DB, user.UserID); err != nil {
+		http.Error(w, "failed to mark notifications read", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "all notifications marked read"})
+}
+
+// GET /me/notification-preferences — enabled/disabled state for each notification type
+func (h *NotificationHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	prefs, err := models.GetNotificationPreferences(h.DB, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch preferences", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, prefs)
+}
+
+// PATCH /me/notification-preferences — update preferences, e.g. {"reaction": false}
+func (h *NotificationHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req map[string]bool
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	known := make(map[string]bool, len(models.NotificationTypes))
+	for _, t := range models.NotificationTypes {
+		known[t] = true
+	}
+	for t := range req {
+		if !known[t] {
+			http.Error(w, "unknown notification type: "+t, http.StatusBadRequest)
+			return
+		}
+	}
+
+	for t, enabled := range req {
+		if err := models.SetNotificationPreference(h.DB, user.UserID, t, enabled); err != nil {
+			http.Error(w, "failed to update preferences", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	prefs, err := models.GetNotificationPreferences(h.DB, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch preferences", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, prefs)
+}
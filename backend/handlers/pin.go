@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"tomo/backend/events"
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+// POST /posts/{id}/pin — pin one of the user's public, published posts to the
+// top of their profile (at most models.MaxPinnedPosts)
+func (h *PostHandler) PinPost(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	post, ok := h.loadOwnPost(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	err := models.PinPost(h.DB, user.UserID, post.ID)
+	if err == models.ErrNotPinnable {
+		http.Error(w, "only public, published posts can be pinned", http.StatusBadRequest)
+		return
+	}
+	if err == models.ErrTooManyPins {
+		http.Error(w, fmt.Sprintf("you can pin at most %d posts", models.MaxPinnedPosts), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to pin post", http.StatusInternalServerError)
+		return
+	}
+
+	postDetails, err := models.GetPostWithDetails(h.DB, post.ID, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch post details", http.StatusInternalServerError)
+		return
+	}
+
+	h.Events.Publish(events.Event{Type: events.PostUpdated, ActorID: user.UserID, PostID: post.ID})
+
+	utils.WriteJSON(w, http.StatusOK, postDetails)
+}
+
+// DELETE /posts/{id}/pin — unpin a post
+func (h *PostHandler) UnpinPost(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	post, ok := h.loadOwnPost(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	if err := models.UnpinPost(h.DB, post.ID); err != nil {
+		http.Error(w, "failed to unpin post", http.StatusInternalServerError)
+		return
+	}
+
+	postDetails, err := models.GetPostWithDetails(h.DB, post.ID, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch post details", http.StatusInternalServerError)
+		return
+	}
+
+	h.Events.Publish(events.Event{Type: events.PostUpdated, ActorID: user.UserID, PostID: post.ID})
+
+	utils.WriteJSON(w, http.StatusOK, postDetails)
+}
+
+// GET /users/{username}/posts — a user's public profile timeline: pinned posts
+// first (on the first page only), then their other public posts, newest first
+func (h *PostHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
+	profileUser, viewer, ok := loadProfileUser(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	limit := 20
+	offset := 0
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	pinned := []models.PostWithDetails{}
+	if offset == 0 {
+		var err error
+		if pinned, err = models.GetPinnedPosts(h.DB, profileUser.ID, viewer); err != nil {
+			http.Error(w, "failed to fetch posts", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	posts, err := models.GetProfilePosts(h.DB, profileUser.ID, viewer, limit, offset)
+	if err != nil {
+		http.Error(w, "failed to fetch posts", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"pinned": pinned,
+		"posts":  posts,
+		"count":  len(posts),
+	})
+}
@@ -1,21 +1,34 @@
 package handlers
 
 import (
-	"context"
 	"database/sql"
 	"encoding/json"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"tomo/backend/events"
 	"tomo/backend/middleware"
 	"tomo/backend/models"
+	"tomo/backend/storage"
 	"tomo/backend/utils"
 )
 
 type PostHandler struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Events  *events.Bus
+	Storage storage.Storage
+}
+
+// markdownOptions are what post content is rendered with. Images must come
+// from our media storage, so none are kept without it.
+func (h *PostHandler) markdownOptions() utils.MarkdownOptions {
+	if h.Storage == nil {
+		return utils.PostMarkdownOptions("")
+	}
+	return utils.PostMarkdownOptions(h.Storage.BaseURL())
 }
 
 type CreatePostRequest struct {
@@ -26,6 +39,86 @@ type CreatePostRequest struct {
 	MoodRating *int     `json:"mood_rating,omitempty"`
 	Visibility string   `json:"visibility"`
 	Tags       []string `json:"tags,omitempty"`
+	// 'off', 'followers' or 'everyone' (default)
+	CommentPermission string `json:"comment_permission,omitempty"`
+	// 'draft' saves without publishing; 'published' (default)
+	Status string `json:"status,omitempty"`
+	// Publish at this time instead of now; the post is 'scheduled' until then
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	// Reflection template the post answers, with answers to its questions
+	TemplateID *int                `json:"template_id,omitempty"`
+	Answers    []models.PostAnswer `json:"answers,omitempty"`
+}
+
+// UpdatePostRequest is a partial update; omitted fields are left unchanged.
+// Tags replaces the post's tags (an empty list clears them) and cannot be
+// combined with AddTags/RemoveTags.
+type UpdatePostRequest struct {
+	Content           string    `json:"content,omitempty"`
+	Title             string    `json:"title,omitempty"`
+	MoodRating        *int      `json:"mood_rating,omitempty"`
+	Visibility        string    `json:"visibility,omitempty"`
+	CommentPermission string    `json:"comment_permission,omitempty"`
+	Tags              *[]string `json:"tags,omitempty"`
+	AddTags           []string  `json:"add_tags,omitempty"`
+	RemoveTags        []string  `json:"remove_tags,omitempty"`
+	// Delete tags left with no posts after this update
+	PruneUnusedTags bool `json:"prune_unused_tags,omitempty"`
+	// Replaces the answers to the post's template questions
+	Answers *[]models.PostAnswer `json:"answers,omitempty"`
+}
+
+// validPostVisibility reports whether v is a known post visibility
+func validPostVisibility(v string) bool {
+	return v == "private" || v == "unlisted" || v == "public"
+}
+
+// validCommentPermission reports whether p is a known comment_permission value
+func validCommentPermission(p string) bool {
+	return p == "off" || p == "followers" || p == "everyone"
+}
+
+// syncMentions records the @mentions in a post's content and notifies mentioned
+// users who can already see the post. The rest are notified once they can: when
+// a draft or scheduled post is published (see notifications.publishedProducer)
+// or a private post is made public. Failures are only
+// logged since the post itself is already saved.
+func (h *PostHandler) syncMentions(postID int) {
+	post, err := models.GetPostByID(h.DB, postID)
+	if err != nil {
+		log.Printf("failed to load post %d for mentions: %v", postID, err)
+		return
+	}
+
+	if err := models.SetPostMentions(h.DB, post.ID, post.UserID, models.ParseMentions(post.Content)); err != nil {
+		log.Printf("failed to record mentions for post %d: %v", post.ID, err)
+		return
+	}
+
+	userIDs, err := models.GetUnnotifiedMentions(h.DB, post.ID)
+	if err != nil {
+		log.Printf("failed to load mentions for post %d: %v", post.ID, err)
+		return
+	}
+	for _, userID := range userIDs {
+		visible, err := models.CanViewPost(h.DB, post, userID)
+		if err != nil {
+			log.Printf("failed to check visibility of post %d for user %d: %v", post.ID, userID, err)
+			continue
+		}
+		if !visible {
+			continue
+		}
+
+		claimed, err := models.ClaimMentionNotification(h.DB, post.ID, userID)
+		if err != nil {
+			log.Printf("failed to mark mention of user %d in post %d: %v", userID, post.ID, err)
+			continue
+		}
+		if claimed {
+			h.Events.Publish(events.Event{Type: events.PostMentioned, ActorID: post.UserID, UserID: userID, PostID: post.ID})
+		}
+	}
 }
 
 // POST /posts — create a new reflection post
@@ -43,13 +136,38 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validation
+	if req.Status == "" {
+		req.Status = "published"
+	}
+	if req.Status != "draft" && req.Status != "published" {
+		http.Error(w, "status must be 'draft' or 'published'", http.StatusBadRequest)
+		return
+	}
+	if req.PublishAt != nil {
+		if req.Status == "draft" {
+			http.Error(w, "drafts cannot have a publish_at", http.StatusBadRequest)
+			return
+		}
+		if msg := validatePublishAt(*req.PublishAt); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		req.Status = "scheduled"
+	}
+
+	// Drafts are autosaved as soon as writing starts, before a visibility is picked
+	if req.Status == "draft" && req.Visibility == "" {
+		req.Visibility = "private"
+	}
+
+	// Daily entries are keyed by date and written with PUT /journal/{date}
 	if req.PostType != "session" && req.PostType != "general" {
 		http.Error(w, "post_type must be 'session' or 'general'", http.StatusBadRequest)
 		return
 	}
 
-	if req.Visibility != "private" && req.Visibility != "public" {
-		http.Error(w, "visibility must be 'private' or 'public'", http.StatusBadRequest)
+	if !validPostVisibility(req.Visibility) {
+		http.Error(w, "visibility must be 'private', 'unlisted' or 'public'", http.StatusBadRequest)
 		return
 	}
 
@@ -58,6 +176,40 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.CommentPermission == "" {
+		req.CommentPermission = "everyone"
+	}
+	if !validCommentPermission(req.CommentPermission) {
+		http.Error(w, "comment_permission must be 'off', 'followers' or 'everyone'", http.StatusBadRequest)
+		return
+	}
+
+	// #hashtags in the content are attached like any other tag
+	tags, err := models.NormalizeTags(append(req.Tags, models.ParseHashtags(req.Content)...))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	answers, ok := validateTemplateAnswers(w, h.DB, req.TemplateID, user.UserID, req.Answers)
+	if !ok {
+		return
+	}
+
+	// The scheduler publishes without asking, so a scheduled post needs
+	// something to publish (see UpdatePost)
+	if req.Status == "scheduled" {
+		ok, err := h.publishable(models.Post{Title: req.Title, Content: req.Content}, &answers)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "a scheduled post cannot be empty", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// If post_type is 'session', verify session exists and belongs to user
 	if req.PostType == "session" {
 		if req.SessionID == nil {
@@ -81,64 +233,247 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Create post
-	post, err := models.CreatePost(h.DB, user.UserID, req.SessionID, req.PostType, req.Content, req.Title, req.MoodRating, req.Visibility)
+	// Create the post with its tags and answers
+	post, err := models.CreatePostWithTags(h.DB, models.Post{
+		UserID:            user.UserID,
+		SessionID:         req.SessionID,
+		PostType:          req.PostType,
+		Content:           req.Content,
+		Title:             req.Title,
+		MoodRating:        req.MoodRating,
+		Visibility:        req.Visibility,
+		CommentPermission: req.CommentPermission,
+		TemplateID:        req.TemplateID,
+		Status:            req.Status,
+		PublishAt:         req.PublishAt,
+	}, tags, answers, h.markdownOptions())
 	if err != nil {
 		http.Error(w, "failed to create post", http.StatusInternalServerError)
 		return
 	}
 
-	// Add tags if provided
-	if len(req.Tags) > 0 {
-		if err := models.AddTagsToPost(h.DB, user.UserID, post.ID, req.Tags); err != nil {
-			http.Error(w, "failed to add tags", http.StatusInternalServerError)
-			return
-		}
-	}
-
 	// Fetch complete post with tags and media (media will be empty for new posts)
-	postDetails, err := models.GetPostWithDetails(h.DB, post.ID)
+	postDetails, err := models.GetPostWithDetails(h.DB, post.ID, user.UserID)
 	if err != nil {
 		http.Error(w, "failed to fetch post details", http.StatusInternalServerError)
 		return
 	}
 
+	// Drafts and scheduled posts are announced by PostPublished when they go live
+	if post.Status == "published" {
+		h.Events.Publish(events.Event{Type: events.PostCreated, ActorID: user.UserID, PostID: post.ID})
+	}
+	h.syncMentions(post.ID)
+
 	utils.WriteJSON(w, http.StatusCreated, postDetails)
 }
 
-// GET /posts/user/{id} — get all posts for a specific user
-func (h *PostHandler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
-	authUser, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+// MaxPostsPageSize caps the limit query parameter on post listings
+const MaxPostsPageSize = 100
+
+// parseDateParam accepts an RFC 3339 timestamp or a YYYY-MM-DD date (UTC).
+// With endOfDay, a bare date means the end of that day, so ranges are inclusive.
+func parseDateParam(v string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// parsePostFilter reads the filters shared by post listings from the query
+// string, returning an error message for bad input
+func parsePostFilter(r *http.Request) (models.PostFilter, string) {
+	q := r.URL.Query()
+	f := models.PostFilter{Limit: 20, Cursor: q.Get("cursor")}
+
+	// tag=a&tag=b or tag=a,b
+	var names []string
+	for _, v := range q["tag"] {
+		names = append(names, strings.Split(v, ",")...)
+	}
+	tags, err := models.NormalizeTags(names)
+	if err != nil {
+		return f, err.Error()
+	}
+	f.Tags = tags
+
+	switch q.Get("match") {
+	case "", "any":
+	case "all":
+		f.MatchAll = true
+	default:
+		return f, "match must be 'any' or 'all'"
+	}
+
+	if v := q.Get("post_type"); v != "" {
+		if v != "session" && v != "general" && v != "daily" {
+			return f, "post_type must be 'session', 'general' or 'daily'"
+		}
+		f.PostType = v
+	}
+	if v := q.Get("visibility"); v != "" {
+		if !validPostVisibility(v) {
+			return f, "visibility must be 'private', 'unlisted' or 'public'"
+		}
+		f.Visibility = v
+	}
+
+	for name, dest := range map[string]*int{"mood_min": &f.MoodMin, "mood_max": &f.MoodMax} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 5 {
+				return f, name + " must be between 1 and 5"
+			}
+			*dest = n
+		}
+	}
+	if f.MoodMin > 0 && f.MoodMax > 0 && f.MoodMin > f.MoodMax {
+		return f, "mood_min cannot be greater than mood_max"
+	}
+
+	if v := q.Get("from"); v != "" {
+		t, err := parseDateParam(v, false)
+		if err != nil {
+			return f, "from must be a date (YYYY-MM-DD) or RFC 3339 timestamp"
+		}
+		f.From = &t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := parseDateParam(v, true)
+		if err != nil {
+			return f, "to must be a date (YYYY-MM-DD) or RFC 3339 timestamp"
+		}
+		f.To = &t
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxPostsPageSize {
+			return f, "limit must be between 1 and 100"
+		}
+		f.Limit = n
+	}
+
+	return f, ""
+}
+
+// listOwnPosts writes a filtered page of the current user's posts with the given status
+func (h *PostHandler) listOwnPosts(w http.ResponseWriter, r *http.Request, status string) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
 	if !ok {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	userIDStr := r.PathValue("id")
-	userID, err := strconv.Atoi(userIDStr)
+	filter, msg := parsePostFilter(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	filter.Status = status
+
+	// Get posts with full details (tags + media)
+	page, err := models.GetPostsWithDetailsByUserID(h.DB, user.UserID, filter)
+	if err == models.ErrInvalidCursor {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		http.Error(w, "invalid user id", http.StatusBadRequest)
+		http.Error(w, "failed to fetch posts", http.StatusInternalServerError)
 		return
 	}
 
-	// Only allow users to view their own posts
-	if userID != authUser.UserID {
-		http.Error(w, "forbidden: can only view your own posts", http.StatusForbidden)
+	utils.WriteJSON(w, http.StatusOK, page)
+}
+
+// GET /me/posts — the user's own published posts, newest first. Supports tag
+// (repeatable or comma-separated) with match=any|all, post_type, visibility,
+// mood_min, mood_max, from, to, limit and cursor.
+func (h *PostHandler) GetMyPosts(w http.ResponseWriter, r *http.Request) {
+	h.listOwnPosts(w, r, "published")
+}
+
+// GET /me/drafts — the user's unpublished drafts, newest first (same filters as /me/posts)
+func (h *PostHandler) GetMyDrafts(w http.ResponseWriter, r *http.Request) {
+	h.listOwnPosts(w, r, "draft")
+}
+
+// GET /me/scheduled — the user's posts waiting to be published, newest first (same filters as /me/posts)
+func (h *PostHandler) GetMyScheduledPosts(w http.ResponseWriter, r *http.Request) {
+	h.listOwnPosts(w, r, "scheduled")
+}
+
+// GET /feed — public posts from everyone, minus blocked and muted users
+func (h *PostHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// Pagination (default: 20 posts per page)
 	limit := 20
 	offset := 0
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
 
-	// Get posts with full details (tags + media)
-	posts, err := models.GetPostsWithDetailsByUserID(h.DB, userID, limit, offset)
+	posts, err := models.GetFeed(h.DB, user.UserID, limit, offset)
+	if err != nil {
+		http.Error(w, "failed to fetch feed", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"posts": posts,
+		"count": len(posts),
+	})
+}
+
+// GET /hashtags/{tag}/posts — public posts from everyone with a tag, newest first
+func (h *PostHandler) GetHashtagPosts(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tag, err := models.NormalizeTag(r.PathValue("tag"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := 20
+	offset := 0
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	posts, err := models.GetPublicPostsByTag(h.DB, user.UserID, tag, limit, offset)
 	if err != nil {
 		http.Error(w, "failed to fetch posts", http.StatusInternalServerError)
 		return
 	}
 
 	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"tag":   tag,
 		"posts": posts,
 		"count": len(posts),
 	})
@@ -169,14 +504,19 @@ func (h *PostHandler) GetPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check access: only owner can view private posts
-	if post.Visibility == "private" && post.UserID != user.UserID {
+	// Check access
+	visible, err := models.CanViewPost(h.DB, post, user.UserID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if !visible {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
 
 	// Get complete post with tags and media
-	postDetails, err := models.GetPostWithDetails(h.DB, postID)
+	postDetails, err := models.GetPostWithDetails(h.DB, postID, user.UserID)
 	if err != nil {
 		http.Error(w, "failed to fetch post details", http.StatusInternalServerError)
 		return
@@ -216,43 +556,170 @@ func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req CreatePostRequest
+	var req UpdatePostRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
 
+	// Validate tag changes up front so nothing is written on bad input
+	if req.Tags != nil && (len(req.AddTags) > 0 || len(req.RemoveTags) > 0) {
+		http.Error(w, "tags cannot be combined with add_tags or remove_tags", http.StatusBadRequest)
+		return
+	}
+	var edit models.TagEdit
+	edit.Prune = req.PruneUnusedTags
+	if req.Tags != nil {
+		tags, err := models.NormalizeTags(*req.Tags)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		edit.Replace = &tags
+	}
+	if edit.Add, err = models.NormalizeTags(req.AddTags); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if edit.Remove, err = models.NormalizeTags(req.RemoveTags); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var answers *[]models.PostAnswer
+	if req.Answers != nil {
+		if post.TemplateID == nil {
+			http.Error(w, "post was not written from a template", http.StatusBadRequest)
+			return
+		}
+		validated, ok := validateTemplateAnswers(w, h.DB, post.TemplateID, user.UserID, *req.Answers)
+		if !ok {
+			return
+		}
+		answers = &validated
+	}
+
 	// Use existing values if not provided
-	content := req.Content
-	if content == "" {
-		content = post.Content
+	if req.Content != "" {
+		post.Content = req.Content
 	}
-	title := req.Title
-	if title == "" {
-		title = post.Title
+	if req.Title != "" {
+		post.Title = req.Title
 	}
-	visibility := req.Visibility
-	if visibility == "" {
-		visibility = post.Visibility
+	if req.Visibility != "" {
+		if !validPostVisibility(req.Visibility) {
+			http.Error(w, "visibility must be 'private', 'unlisted' or 'public'", http.StatusBadRequest)
+			return
+		}
+		post.Visibility = req.Visibility
 	}
-	moodRating := req.MoodRating
-	if moodRating == nil {
-		moodRating = post.MoodRating
+	if req.MoodRating != nil {
+		if *req.MoodRating < 1 || *req.MoodRating > 5 {
+			http.Error(w, "mood_rating must be between 1 and 5", http.StatusBadRequest)
+			return
+		}
+		post.MoodRating = req.MoodRating
+	}
+	if req.CommentPermission != "" {
+		if !validCommentPermission(req.CommentPermission) {
+			http.Error(w, "comment_permission must be 'off', 'followers' or 'everyone'", http.StatusBadRequest)
+			return
+		}
+		post.CommentPermission = req.CommentPermission
 	}
 
-	// Update
-	if err := models.UpdatePost(h.DB, postID, content, title, moodRating, visibility); err != nil {
+	// The scheduler publishes without asking, so a scheduled post must stay
+	// publishable
+	if post.Status == "scheduled" {
+		ok, err := h.publishable(post, answers)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "a scheduled post cannot be empty; unschedule it first", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// #hashtags in the content are attached like any other tag
+	edit.Add = append(edit.Add, models.ParseHashtags(post.Content)...)
+
+	// Update post, tags and answers together
+	if err := models.UpdatePostWithTags(h.DB, post, edit, answers, h.markdownOptions()); err != nil {
 		http.Error(w, "failed to update post", http.StatusInternalServerError)
 		return
 	}
 
 	// Get updated post with details
-	postDetails, err := models.GetPostWithDetails(h.DB, postID)
+	postDetails, err := models.GetPostWithDetails(h.DB, postID, user.UserID)
 	if err != nil {
 		http.Error(w, "failed to fetch updated post", http.StatusInternalServerError)
 		return
 	}
 
+	h.Events.Publish(events.Event{Type: events.PostUpdated, ActorID: user.UserID, PostID: postID})
+	h.syncMentions(postID)
+
+	utils.WriteJSON(w, http.StatusOK, postDetails)
+}
+
+// POST /posts/{id}/publish — publish a draft or scheduled post now
+func (h *PostHandler) PublishPost(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	postID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid post id", http.StatusBadRequest)
+		return
+	}
+
+	// Check ownership
+	post, err := models.GetPostByID(h.DB, postID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "post not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	if post.UserID != user.UserID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if post.Status == "published" {
+		http.Error(w, "post is already published", http.StatusConflict)
+		return
+	}
+	if !h.checkPublishable(w, post) {
+		return
+	}
+
+	err = models.PublishPost(h.DB, postID)
+	if err == sql.ErrNoRows {
+		// Published by a concurrent request
+		http.Error(w, "post is already published", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to publish post", http.StatusInternalServerError)
+		return
+	}
+
+	postDetails, err := models.GetPostWithDetails(h.DB, postID, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch post details", http.StatusInternalServerError)
+		return
+	}
+
+	h.Events.Publish(events.Event{Type: events.PostPublished, ActorID: user.UserID, PostID: postID})
+
 	utils.WriteJSON(w, http.StatusOK, postDetails)
 }
 
@@ -287,22 +754,15 @@ func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
-	defer cancel()
-
-	// Delete media files from S3 before deleting post
-	media, _ := models.GetMediaForPost(h.DB, postID)
-	for _, m := range media {
-		if err := utils.DeleteFromS3(ctx, m.FileURL); err != nil {
-			log.Printf("failed to delete S3 file %s: %v", m.FileURL, err)
-		}
-	}
-
-	// Delete post (media rows cascade delete automatically)
-	if err := models.DeletePost(h.DB, postID); err != nil {
+	// Delete post and its media, then any files no other post uses
+	unused, err := models.DeletePost(h.DB, postID)
+	if err != nil {
 		http.Error(w, "failed to delete post", http.StatusInternalServerError)
 		return
 	}
+	deleteStoredFiles(h.Storage, unused)
+
+	h.Events.Publish(events.Event{Type: events.PostDeleted, ActorID: user.UserID, PostID: postID})
 
 	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "post deleted"})
 }
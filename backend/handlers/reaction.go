@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"tomo/backend/events"
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+type ReactionRequest struct {
+	Kind string `json:"kind"`
+}
+
+// loadViewablePost resolves {id} to a published post the user can view,
+// writing an error response on failure
+func (h *PostHandler) loadViewablePost(w http.ResponseWriter, r *http.Request, userID int) (models.Post, bool) {
+	postID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid post id", http.StatusBadRequest)
+		return models.Post{}, false
+	}
+
+	post, err := models.GetPostByID(h.DB, postID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "post not found", http.StatusNotFound)
+		return models.Post{}, false
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return models.Post{}, false
+	}
+
+	visible, err := models.CanViewPost(h.DB, post, userID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return models.Post{}, false
+	}
+	if !visible || post.Status != "published" {
+		http.Error(w, "post not found", http.StatusNotFound)
+		return models.Post{}, false
+	}
+	return post, true
+}
+
+// PUT /posts/{id}/reaction — react to a post, replacing any earlier reaction
+func (h *PostHandler) SetReaction(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req ReactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !models.ValidReactionKind(req.Kind) {
+		http.Error(w, "kind must be one of: "+strings.Join(models.ReactionKinds, ", "), http.StatusBadRequest)
+		return
+	}
+
+	post, ok := h.loadViewablePost(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	created, err := models.SetPostReaction(h.DB, post.ID, user.UserID, req.Kind)
+	if err != nil {
+		http.Error(w, "failed to save reaction", http.StatusInternalServerError)
+		return
+	}
+	// Only a first reaction notifies; switching kinds doesn't
+	if created {
+		h.Events.Publish(events.Event{Type: events.PostReacted, ActorID: user.UserID, PostID: post.ID})
+	}
+
+	reactions, err := models.GetPostReactions(h.DB, post.ID, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch reactions", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, reactions)
+}
+
+// DELETE /posts/{id}/reaction — remove the current user's reaction
+func (h *PostHandler) DeleteReaction(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	post, ok := h.loadViewablePost(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	if err := models.DeletePostReaction(h.DB, post.ID, user.UserID); err != nil {
+		http.Error(w, "failed to remove reaction", http.StatusInternalServerError)
+		return
+	}
+
+	reactions, err := models.GetPostReactions(h.DB, post.ID, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch reactions", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, reactions)
+}
+
+// GET /posts/{id}/reactions — reaction counts and the current user's reaction
+func (h *PostHandler) GetReactions(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	post, ok := h.loadViewablePost(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	reactions, err := models.GetPostReactions(h.DB, post.ID, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch reactions", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, reactions)
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+const MaxReportDetailsLength = 1000
+
+type ReportHandler struct {
+	DB *sql.DB
+}
+
+type CreateReportRequest struct {
+	TargetType string `json:"target_type"` // 'post', 'comment', 'media' or 'user'
+	TargetID   int    `json:"target_id"`
+	Reason     string `json:"reason"`
+	Details    string `json:"details,omitempty"`
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// reportTargetOwner finds who owns a report target, checking that the reporter
+// can actually see it. Returns sql.ErrNoRows if it doesn't exist or is hidden.
+func reportTargetOwner(db *sql.DB, targetType string, targetID, reporterID int) (int, error) {
+	var postID, ownerID int
+	switch targetType {
+	case "user":
+		u, err := models.GetUserByID(db, targetID)
+		return u.ID, err
+	case "comment":
+		c, err := models.GetCommentByID(db, targetID)
+		if err != nil {
+			return 0, err
+		}
+		postID, ownerID = c.PostID, c.UserID
+	case "media":
+		m, err := models.GetMediaByID(db, targetID)
+		if err != nil {
+			return 0, err
+		}
+		postID, ownerID = m.PostID, m.UserID
+	default:
+		postID = targetID
+	}
+
+	// Content can only be reported by those who can see the post it belongs to
+	post, err := models.GetPostByID(db, postID)
+	if err != nil {
+		return 0, err
+	}
+	visible, err := models.CanViewPost(db, post, reporterID)
+	if err != nil {
+		return 0, err
+	}
+	if !visible {
+		return 0, sql.ErrNoRows
+	}
+
+	if ownerID == 0 {
+		ownerID = post.UserID
+	}
+	return ownerID, nil
+}
+
+// POST /reports — report a post, comment, media item or user to moderators
+func (h *ReportHandler) CreateReport(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !contains(models.ReportTargets, req.TargetType) {
+		http.Error(w, "target_type must be 'post', 'comment', 'media' or 'user'", http.StatusBadRequest)
+		return
+	}
+	if !contains(models.ReportReasons, req.Reason) {
+		http.Error(w, "invalid reason", http.StatusBadRequest)
+		return
+	}
+	details := strings.TrimSpace(req.Details)
+	if len(details) > MaxReportDetailsLength {
+		http.Error(w, "details must be 1000 characters or less", http.StatusBadRequest)
+		return
+	}
+
+	ownerID, err := reportTargetOwner(h.DB, req.TargetType, req.TargetID, user.UserID)
+	if err == sql.ErrNoRows {
+		http.Error(w, req.TargetType+" not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if ownerID == user.UserID {
+		http.Error(w, "cannot report your own content", http.StatusBadRequest)
+		return
+	}
+
+	report, err := models.CreateReport(h.DB, user.UserID, req.TargetType, req.TargetID, req.Reason, details)
+	if err == sql.ErrNoRows {
+		http.Error(w, "you have already reported this", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to create report", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, report)
+}
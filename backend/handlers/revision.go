@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"tomo/backend/events"
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+// loadOwnPost parses {id} and fetches the post, writing an error response
+// unless it belongs to userID
+func (h *PostHandler) loadOwnPost(w http.ResponseWriter, r *http.Request, userID int) (models.Post, bool) {
+	postID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid post id", http.StatusBadRequest)
+		return models.Post{}, false
+	}
+
+	post, err := models.GetPostByID(h.DB, postID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "post not found", http.StatusNotFound)
+		return models.Post{}, false
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return models.Post{}, false
+	}
+
+	if post.UserID != userID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return models.Post{}, false
+	}
+
+	return post, true
+}
+
+// GET /posts/{id}/revisions — list previous versions of the user's post, newest first
+func (h *PostHandler) GetPostRevisions(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	post, ok := h.loadOwnPost(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	revisions, err := models.GetRevisionsForPost(h.DB, post.ID)
+	if err != nil {
+		http.Error(w, "failed to fetch revisions", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"revisions": revisions,
+		"count":     len(revisions),
+	})
+}
+
+// POST /posts/{id}/revisions/{revisionId}/restore — bring back an earlier title,
+// content and mood. The version being replaced is saved as a new revision.
+func (h *PostHandler) RestorePostRevision(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	post, ok := h.loadOwnPost(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	revisionID, err := strconv.Atoi(r.PathValue("revisionId"))
+	if err != nil {
+		http.Error(w, "invalid revision id", http.StatusBadRequest)
+		return
+	}
+
+	revision, err := models.GetRevisionByID(h.DB, revisionID)
+	if err == sql.ErrNoRows || (err == nil && revision.PostID != post.ID) {
+		http.Error(w, "revision not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	post.Title = revision.Title
+	post.Content = revision.Content
+	post.MoodRating = revision.MoodRating
+
+	// Restoring an empty revision must not leave a scheduled post with nothing
+	// to publish (see UpdatePost)
+	if post.Status == "scheduled" {
+		ok, err := h.publishable(post, nil)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "a scheduled post cannot be empty; unschedule it first", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// #hashtags in the restored content are attached like any other tag
+	edit := models.TagEdit{Add: models.ParseHashtags(post.Content)}
+	if err := models.UpdatePostWithTags(h.DB, post, edit, nil, h.markdownOptions()); err != nil {
+		http.Error(w, "failed to restore revision", http.StatusInternalServerError)
+		return
+	}
+
+	postDetails, err := models.GetPostWithDetails(h.DB, post.ID, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch updated post", http.StatusInternalServerError)
+		return
+	}
+
+	h.Events.Publish(events.Event{Type: events.PostUpdated, ActorID: user.UserID, PostID: post.ID})
+	h.syncMentions(post.ID)
+
+	utils.WriteJSON(w, http.StatusOK, postDetails)
+}
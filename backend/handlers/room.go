@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/websocket"
+
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/rooms"
+	"tomo/backend/utils"
+)
+
+const MaxRoomMembers = 20
+
+type RoomHandler struct {
+	DB    *sql.DB
+	Rooms *rooms.Manager
+}
+
+type CreateRoomRequest struct {
+	Name string `json:"name"`
+}
+
+type JoinRoomRequest struct {
+	InviteCode string `json:"invite_code"`
+}
+
+// loadRoom parses {id} and fetches the room, writing an error response on failure
+func (h *RoomHandler) loadRoom(w http.ResponseWriter, r *http.Request) (models.FocusRoom, bool) {
+	roomID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid room id", http.StatusBadRequest)
+		return models.FocusRoom{}, false
+	}
+
+	room, err := models.GetRoomByID(h.DB, roomID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "room not found", http.StatusNotFound)
+		return models.FocusRoom{}, false
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return models.FocusRoom{}, false
+	}
+
+	return room, true
+}
+
+// POST /rooms — create a co-focus room
+func (h *RoomHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 60 {
+		http.Error(w, "name must be between 1 and 60 characters", http.StatusBadRequest)
+		return
+	}
+
+	code, err := utils.RandomCode(InviteCodeLength)
+	if err != nil {
+		http.Error(w, "failed to create invite code", http.StatusInternalServerError)
+		return
+	}
+
+	room, err := models.CreateRoom(h.DB, user.UserID, name, code)
+	if err != nil {
+		http.Error(w, "failed to create room", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, room)
+}
+
+// GET /rooms — list the rooms the user has joined
+func (h *RoomHandler) GetMyRooms(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	list, err := models.GetRoomsForUser(h.DB, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch rooms", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"rooms": list,
+		"count": len(list),
+	})
+}
+
+// GET /rooms/{id} — room details, members and live participants (members only)
+func (h *RoomHandler) GetRoom(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	room, ok := h.loadRoom(w, r)
+	if !ok {
+		return
+	}
+
+	member, err := models.IsRoomMember(h.DB, room.ID, user.UserID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if !member {
+		http.Error(w, "forbidden: join the room first", http.StatusForbidden)
+		return
+	}
+
+	members, err := models.GetRoomMembers(h.DB, room.ID)
+	if err != nil {
+		http.Error(w, "failed to fetch members", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"room":         room,
+		"members":      members,
+		"participants": h.Rooms.Participants(room.ID),
+	})
+}
+
+// POST /rooms/join — become a member of a room with its invite code
+func (h *RoomHandler) JoinRoom(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req JoinRoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	code := strings.ToUpper(strings.TrimSpace(req.InviteCode))
+	if code == "" {
+		http.Error(w, "invite_code is required", http.StatusBadRequest)
+		return
+	}
+
+	room, err := models.GetRoomByInviteCode(h.DB, code)
+	if err == sql.ErrNoRows {
+		http.Error(w, "invalid invite code", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	member, err := models.IsRoomMember(h.DB, room.ID, user.UserID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if !member {
+		count, err := models.CountRoomMembers(h.DB, room.ID)
+		if err != nil {
+			http.Error(w, "database error", http.StatusInternalServerError)
+			return
+		}
+		if count >= MaxRoomMembers {
+			http.Error(w, "room is full (max 20 members)", http.StatusConflict)
+			return
+		}
+
+		if err := models.AddRoomMember(h.DB, room.ID, user.UserID); err != nil {
+			http.Error(w, "failed to join room", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, room)
+}
+
+// POST /rooms/{id}/invite-code — replace the invite code (owner only)
+func (h *RoomHandler) RegenerateInviteCode(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	room, ok := h.loadRoom(w, r)
+	if !ok {
+		return
+	}
+	if room.OwnerID != user.UserID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	code, err := utils.RandomCode(InviteCodeLength)
+	if err != nil {
+		http.Error(w, "failed to create invite code", http.StatusInternalServerError)
+		return
+	}
+	if err := models.UpdateRoomInviteCode(h.DB, room.ID, code); err != nil {
+		http.Error(w, "failed to update invite code", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"invite_code": code})
+}
+
+// POST /rooms/{id}/leave — leave a room (the owner deletes it instead)
+func (h *RoomHandler) LeaveRoom(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	room, ok := h.loadRoom(w, r)
+	if !ok {
+		return
+	}
+
+	if room.OwnerID == user.UserID {
+		http.Error(w, "the owner cannot leave; delete the room instead", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.RemoveRoomMember(h.DB, room.ID, user.UserID); err != nil {
+		http.Error(w, "failed to leave room", http.StatusInternalServerError)
+		return
+	}
+	h.Rooms.Kick(room.ID, user.UserID)
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "left room"})
+}
+
+// DELETE /rooms/{id} — delete a room (owner only)
+func (h *RoomHandler) DeleteRoom(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	room, ok := h.loadRoom(w, r)
+	if !ok {
+		return
+	}
+
+	if room.OwnerID != user.UserID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	if err := models.DeleteRoom(h.DB, room.ID); err != nil {
+		http.Error(w, "failed to delete room", http.StatusInternalServerError)
+		return
+	}
+	h.Rooms.CloseRoom(room.ID)
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "room deleted"})
+}
+
+// GET /rooms/{id}/ws — WebSocket connection for presence, chat and the shared timer (members only)
+func (h *RoomHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	room, ok := h.loadRoom(w, r)
+	if !ok {
+		return
+	}
+
+	member, err := models.IsRoomMember(h.DB, room.ID, user.UserID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if !member {
+		http.Error(w, "forbidden: join the room first", http.StatusForbidden)
+		return
+	}
+
+	dbUser, err := models.GetUserByID(h.DB, user.UserID)
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	server := websocket.Server{
+		Handshake: checkWebSocketOrigin,
+		Handler: func(ws *websocket.Conn) {
+			h.Rooms.Serve(ws, room, dbUser)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// checkWebSocketOrigin rejects browser connections from other sites. Native
+// clients send no Origin; browsers must come from this host or an origin in
+// CORS_ALLOWED_ORIGINS.
+func checkWebSocketOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid origin %q", origin)
+	}
+	allowed := u.Host == r.Host
+	for _, candidate := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		if candidate = strings.TrimSpace(candidate); candidate == "*" || candidate == origin {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("origin %q not allowed", origin)
+	}
+
+	config.Origin = u
+	return nil
+}
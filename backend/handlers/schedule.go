@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"tomo/backend/events"
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+// MaxScheduleAhead caps how far in the future a post can be scheduled
+const MaxScheduleAhead = 365 * 24 * time.Hour
+
+type SchedulePostRequest struct {
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+// validatePublishAt returns an error message if t can't be used as a publish time
+func validatePublishAt(t time.Time) string {
+	if !t.After(time.Now()) {
+		return "publish_at must be in the future"
+	}
+	if t.After(time.Now().Add(MaxScheduleAhead)) {
+		return "publish_at must be within a year"
+	}
+	return ""
+}
+
+// publishable reports whether post has something to publish: a title, content
+// or template answers. answers are the post's answers, or nil to load them.
+// Autosaves may have stored an empty draft.
+func (h *PostHandler) publishable(post models.Post, answers *[]models.PostAnswer) (bool, error) {
+	if strings.TrimSpace(post.Title) != "" || strings.TrimSpace(post.Content) != "" {
+		return true, nil
+	}
+	if answers != nil {
+		return len(*answers) > 0, nil
+	}
+	stored, err := models.GetAnswersForPost(h.DB, post.ID)
+	return len(stored) > 0, err
+}
+
+// checkPublishable writes an error response if post has nothing to publish
+func (h *PostHandler) checkPublishable(w http.ResponseWriter, post models.Post) bool {
+	ok, err := h.publishable(post, nil)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		http.Error(w, "cannot publish an empty post", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// PUT /posts/{id}/schedule — schedule a draft (or reschedule a scheduled post)
+// to be published at publish_at. It stays visible only to its author until then.
+func (h *PostHandler) SchedulePost(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	post, ok := h.loadOwnPost(w, r, user.UserID)
+	if !ok {
+		return
+	}
+	if post.Status == "published" {
+		http.Error(w, "post is already published", http.StatusConflict)
+		return
+	}
+
+	var req SchedulePostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.PublishAt == nil {
+		http.Error(w, "publish_at is required", http.StatusBadRequest)
+		return
+	}
+	if msg := validatePublishAt(*req.PublishAt); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if !h.checkPublishable(w, post) {
+		return
+	}
+
+	err := models.SchedulePost(h.DB, post.ID, *req.PublishAt)
+	if err == sql.ErrNoRows {
+		// Published meanwhile, by a concurrent request or the scheduler
+		http.Error(w, "post is already published", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to schedule post", http.StatusInternalServerError)
+		return
+	}
+
+	postDetails, err := models.GetPostWithDetails(h.DB, post.ID, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch post details", http.StatusInternalServerError)
+		return
+	}
+
+	h.Events.Publish(events.Event{Type: events.PostUpdated, ActorID: user.UserID, PostID: post.ID})
+
+	utils.WriteJSON(w, http.StatusOK, postDetails)
+}
+
+// DELETE /posts/{id}/schedule — cancel a scheduled publish, turning the post
+// back into a draft
+func (h *PostHandler) UnschedulePost(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	post, ok := h.loadOwnPost(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	err := models.UnschedulePost(h.DB, post.ID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "post is not scheduled", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to unschedule post", http.StatusInternalServerError)
+		return
+	}
+
+	postDetails, err := models.GetPostWithDetails(h.DB, post.ID, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch post details", http.StatusInternalServerError)
+		return
+	}
+
+	h.Events.Publish(events.Event{Type: events.PostUpdated, ActorID: user.UserID, PostID: post.ID})
+
+	utils.WriteJSON(w, http.StatusOK, postDetails)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+// MaxSearchQueryLength caps the q parameter of post search
+const MaxSearchQueryLength = 200
+
+// GET /search/posts?q= — full-text search over the user's own posts and public
+// posts they can see, best matches first, with highlighted snippets
+func (h *PostHandler) SearchPosts(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	if len(query) > MaxSearchQueryLength {
+		http.Error(w, "q must be 200 characters or less", http.StatusBadRequest)
+		return
+	}
+
+	limit := 20
+	offset := 0
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	results, err := models.SearchPosts(h.DB, user.UserID, query, limit, offset)
+	if err != nil {
+		http.Error(w, "failed to search posts", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"results": results,
+		"count":   len(results),
+	})
+}
@@ -7,13 +7,17 @@ import (
 	"net/http"
 	"time"
 
+	"tomo/backend/events"
 	"tomo/backend/middleware"
 	"tomo/backend/models"
+	"tomo/backend/storage"
 	"tomo/backend/utils"
 )
 
 type SessionHandler struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Events  *events.Bus
+	Storage storage.Storage
 }
 
 type CreateSessionRequest struct {
@@ -65,12 +69,14 @@ func (h *SessionHandler) CreateSession(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create session
-	session, err := models.CreateSession(h.DB, user.UserID, startTime, endTime)
+	session, err := models.CreateSession(h.DB, user.UserID, nil, startTime, endTime)
 	if err != nil {
 		http.Error(w, "failed to create session", http.StatusInternalServerError)
 		return
 	}
 
+	h.Events.Publish(events.Event{Type: events.SessionCreated, ActorID: user.UserID, TargetID: session.ID})
+
 	utils.WriteJSON(w, http.StatusCreated, session)
 }
 
@@ -177,10 +183,15 @@ func (h *SessionHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := models.DeleteSession(h.DB, id); err != nil {
+	// Delete the session and its posts, then any media files they used
+	unused, err := models.DeleteSession(h.DB, id)
+	if err != nil {
 		http.Error(w, "failed to delete session", http.StatusInternalServerError)
 		return
 	}
+	deleteStoredFiles(h.Storage, unused)
+
+	h.Events.Publish(events.Event{Type: events.SessionDeleted, ActorID: user.UserID, TargetID: id})
 
 	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "session deleted"})
 }
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	_ "embed"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+// ShareExcerptLength caps the description shown in link previews, in characters
+const ShareExcerptLength = 200
+
+//go:embed templates/share.html
+var shareTemplateSource string
+
+var shareTemplate = template.Must(template.New("share").Parse(shareTemplateSource))
+
+// sharePage is the data rendered by templates/share.html
+type sharePage struct {
+	Title         string
+	Description   string
+	URL           string
+	ImageURL      string // first image attached to the post, for the preview card
+	Images        []string
+	Author        string
+	Mood          int
+	PublishedAt   string // RFC 3339
+	PublishedDate string
+	Content       template.HTML // already sanitized by utils.RenderMarkdown
+	NoIndex       bool          // unlisted posts stay out of search engines
+}
+
+// publicBaseURL is where share links point: PUBLIC_BASE_URL if set (e.g.
+// "https://tomo.app"), otherwise the host the request came in on
+func publicBaseURL(r *http.Request) string {
+	if base := strings.TrimSpace(os.Getenv("PUBLIC_BASE_URL")); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// imageSources is the CSP img-src list for share pages: the API itself and
+// the media storage origin, which may be plain http for local storage
+func (h *PostHandler) imageSources() string {
+	sources := "'self'"
+	if h.Storage == nil {
+		return sources
+	}
+	if u, err := url.Parse(h.Storage.BaseURL()); err == nil && u.Scheme != "" && u.Host != "" {
+		sources += " " + u.Scheme + "://" + u.Host
+	}
+	return sources
+}
+
+// GET /p/{slug} — server-rendered page for a public or unlisted post, with
+// Open Graph and Twitter card metadata so shared links preview nicely
+func (h *PostHandler) GetSharePage(w http.ResponseWriter, r *http.Request) {
+	post, err := models.GetPostBySlug(h.DB, r.PathValue("slug"))
+	if err == sql.ErrNoRows {
+		http.Error(w, "post not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Same rules as for a signed-out viewer with the link
+	visible, err := models.CanViewSharedPost(h.DB, post, 0)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if !visible {
+		http.Error(w, "post not found", http.StatusNotFound)
+		return
+	}
+
+	details, err := models.GetPostWithDetails(h.DB, post.ID, 0)
+	if err != nil {
+		http.Error(w, "failed to fetch post details", http.StatusInternalServerError)
+		return
+	}
+	author, err := models.GetUserByID(h.DB, post.UserID)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	page := sharePage{
+		Title:       strings.TrimSpace(post.Title),
+		Description: utils.Excerpt(details.ContentHTML, ShareExcerptLength),
+		URL:         publicBaseURL(r) + "/p/" + post.ShareSlug,
+		Author:      "@" + author.Username,
+		Content:     template.HTML(details.ContentHTML),
+		NoIndex:     post.Visibility == "unlisted",
+	}
+	if author.DisplayName != "" {
+		page.Author = author.DisplayName + " (@" + author.Username + ")"
+	}
+	if page.Title == "" {
+		page.Title = "A reflection by " + page.Author
+	}
+	if page.Description == "" {
+		page.Description = "A reflection shared on tomo"
+	}
+	if post.MoodRating != nil {
+		page.Mood = *post.MoodRating
+	}
+	if post.PublishedAt != nil {
+		page.PublishedAt = post.PublishedAt.Format(time.RFC3339)
+		page.PublishedDate = post.PublishedAt.Format("January 2, 2006")
+	}
+	for _, m := range details.Media {
+		if m.MediaType == "image" {
+			page.Images = append(page.Images, m.FileURL)
+		}
+	}
+	if len(page.Images) > 0 {
+		page.ImageURL = page.Images[0]
+	}
+
+	// Render first so a template error doesn't leave a half-written page
+	var buf bytes.Buffer
+	if err := shareTemplate.Execute(&buf, page); err != nil {
+		log.Printf("failed to render share page for post %d: %v", post.ID, err)
+		http.Error(w, "failed to render page", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// Not cached by shared caches, so a post made private or deleted stops
+	// being served right away
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src "+h.imageSources()+"; style-src 'unsafe-inline'")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+type TagHandler struct {
+	DB *sql.DB
+}
+
+type RenameTagRequest struct {
+	Name string `json:"name"`
+}
+
+type MergeTagRequest struct {
+	Into int `json:"into"` // ID of the tag that absorbs this one
+}
+
+// loadOwnTag parses {id} and fetches the tag, writing an error response unless
+// it belongs to userID
+func (h *TagHandler) loadOwnTag(w http.ResponseWriter, r *http.Request, userID int) (models.Tag, bool) {
+	tagID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid tag id", http.StatusBadRequest)
+		return models.Tag{}, false
+	}
+
+	tag, err := models.GetTagByID(h.DB, tagID)
+	if err == sql.ErrNoRows || (err == nil && tag.UserID != userID) {
+		http.Error(w, "tag not found", http.StatusNotFound)
+		return models.Tag{}, false
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return models.Tag{}, false
+	}
+
+	return tag, true
+}
+
+// GET /tags?sort=name|count — list the user's tags with usage counts
+func (h *TagHandler) GetMyTags(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy != "" && sortBy != "name" && sortBy != "count" {
+		http.Error(w, "sort must be 'name' or 'count'", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := models.GetTagsForUser(h.DB, user.UserID, sortBy)
+	if err != nil {
+		http.Error(w, "failed to fetch tags", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"tags":  tags,
+		"count": len(tags),
+	})
+}
+
+// PATCH /tags/{id} — rename a tag
+func (h *TagHandler) RenameTag(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tag, ok := h.loadOwnTag(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	var req RenameTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	name, err := models.NormalizeTag(req.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if name != tag.Name {
+		// Renaming onto an existing tag is a merge, which must be explicit
+		if err := models.RenameTag(h.DB, tag.ID, name); err == models.ErrTagExists {
+			http.Error(w, "a tag with that name already exists; merge the tags instead", http.StatusConflict)
+			return
+		} else if err != nil {
+			http.Error(w, "failed to rename tag", http.StatusInternalServerError)
+			return
+		}
+		tag.Name = name
+	}
+
+	utils.WriteJSON(w, http.StatusOK, tag)
+}
+
+// POST /tags/{id}/merge — move this tag's posts onto another tag and delete this one
+func (h *TagHandler) MergeTag(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	source, ok := h.loadOwnTag(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	var req MergeTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Into == source.ID {
+		http.Error(w, "cannot merge a tag into itself", http.StatusBadRequest)
+		return
+	}
+
+	target, err := models.GetTagByID(h.DB, req.Into)
+	if err == sql.ErrNoRows || (err == nil && target.UserID != user.UserID) {
+		http.Error(w, "target tag not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := models.MergeTags(h.DB, source.ID, target.ID); err != nil {
+		http.Error(w, "failed to merge tags", http.StatusInternalServerError)
+		return
+	}
+
+	merged, err := models.GetTagByID(h.DB, target.ID)
+	if err != nil {
+		http.Error(w, "failed to fetch merged tag", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, merged)
+}
+
+// DELETE /tags/{id} — delete a tag and remove it from all posts
+func (h *TagHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tag, ok := h.loadOwnTag(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteTag(h.DB, tag.ID); err != nil {
+		http.Error(w, "failed to delete tag", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "tag deleted"})
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+// Limits on user-defined reflection templates
+const (
+	MaxTemplateNameLength   = 100
+	MaxTemplateQuestions    = 20
+	MaxTemplatePromptLength = 200
+)
+
+type TemplateHandler struct {
+	DB *sql.DB
+}
+
+type TemplateQuestionRequest struct {
+	Prompt string `json:"prompt"`
+	Type   string `json:"type"` // 'text', 'scale' or 'yes_no'
+}
+
+type CreateTemplateRequest struct {
+	Name        string                    `json:"name"`
+	Description string                    `json:"description,omitempty"`
+	Questions   []TemplateQuestionRequest `json:"questions"`
+}
+
+// validateTemplateAnswers checks a post's answers against its template, writing
+// an error response if the template can't be used or an answer is invalid
+func validateTemplateAnswers(w http.ResponseWriter, db *sql.DB, templateID *int, userID int, answers []models.PostAnswer) ([]models.PostAnswer, bool) {
+	if templateID == nil {
+		if len(answers) > 0 {
+			http.Error(w, "answers require a template_id", http.StatusBadRequest)
+			return nil, false
+		}
+		return nil, true
+	}
+
+	template, err := models.GetTemplateByID(db, *templateID)
+	if err == sql.ErrNoRows || (err == nil && !template.VisibleTo(userID)) {
+		http.Error(w, "template not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	validated, err := models.ValidateAnswers(template, answers)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return validated, true
+}
+
+// loadTemplate parses {id} and fetches the template, writing an error response
+// unless userID may use it
+func (h *TemplateHandler) loadTemplate(w http.ResponseWriter, r *http.Request, userID int) (models.ReflectionTemplate, bool) {
+	templateID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid template id", http.StatusBadRequest)
+		return models.ReflectionTemplate{}, false
+	}
+
+	template, err := models.GetTemplateByID(h.DB, templateID)
+	if err == sql.ErrNoRows || (err == nil && !template.VisibleTo(userID)) {
+		http.Error(w, "template not found", http.StatusNotFound)
+		return models.ReflectionTemplate{}, false
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return models.ReflectionTemplate{}, false
+	}
+
+	return template, true
+}
+
+// GET /templates — built-in templates and the user's own
+func (h *TemplateHandler) GetTemplates(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	templates, err := models.GetTemplatesForUser(h.DB, user.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch templates", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"templates": templates,
+		"count":     len(templates),
+	})
+}
+
+// POST /templates — create a reflection template
+func (h *TemplateHandler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if len(name) > MaxTemplateNameLength {
+		http.Error(w, "name must be 100 characters or less", http.StatusBadRequest)
+		return
+	}
+	if len(req.Questions) == 0 || len(req.Questions) > MaxTemplateQuestions {
+		http.Error(w, "a template needs between 1 and 20 questions", http.StatusBadRequest)
+		return
+	}
+
+	template := models.ReflectionTemplate{
+		UserID:      &user.UserID,
+		Name:        name,
+		Description: strings.TrimSpace(req.Description),
+	}
+	for _, q := range req.Questions {
+		prompt := strings.TrimSpace(q.Prompt)
+		if prompt == "" {
+			http.Error(w, "every question needs a prompt", http.StatusBadRequest)
+			return
+		}
+		if len(prompt) > MaxTemplatePromptLength {
+			http.Error(w, "prompts must be 200 characters or less", http.StatusBadRequest)
+			return
+		}
+		if !contains(models.QuestionTypes, q.Type) {
+			http.Error(w, "question type must be 'text', 'scale' or 'yes_no'", http.StatusBadRequest)
+			return
+		}
+		template.Questions = append(template.Questions, models.TemplateQuestion{Prompt: prompt, Type: q.Type})
+	}
+
+	created, err := models.CreateTemplate(h.DB, template)
+	if err != nil {
+		http.Error(w, "failed to create template", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, created)
+}
+
+// GET /templates/{id} — get a template with its questions
+func (h *TemplateHandler) GetTemplate(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	template, ok := h.loadTemplate(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, template)
+}
+
+// DELETE /templates/{id} — delete one of the user's templates. Posts written
+// from it keep their answers.
+func (h *TemplateHandler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	template, ok := h.loadTemplate(w, r, user.UserID)
+	if !ok {
+		return
+	}
+	if template.BuiltIn {
+		http.Error(w, "forbidden: built-in templates cannot be deleted", http.StatusForbidden)
+		return
+	}
+
+	if err := models.DeleteTemplate(h.DB, template.ID); err != nil {
+		http.Error(w, "failed to delete template", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "template deleted"})
+}
+
+// GET /templates/{id}/insights?from=&to= — aggregate the user's answers to each
+// question: averages and spread for scales, yes/no counts, recent text answers,
+// and a weekly series
+func (h *TemplateHandler) GetTemplateInsights(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	template, ok := h.loadTemplate(w, r, user.UserID)
+	if !ok {
+		return
+	}
+
+	// Same date formats as /me/posts; a bare 'to' date is inclusive
+	var from, to *time.Time
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := parseDateParam(v, false)
+		if err != nil {
+			http.Error(w, "from must be a date (YYYY-MM-DD) or RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		from = &t
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := parseDateParam(v, true)
+		if err != nil {
+			http.Error(w, "to must be a date (YYYY-MM-DD) or RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		to = &t
+	}
+
+	insights, err := models.GetTemplateInsights(h.DB, user.UserID, template, from, to)
+	if err != nil {
+		http.Error(w, "failed to compute insights", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"template_id": template.ID,
+		"questions":   insights,
+	})
+}
@@ -5,20 +5,27 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+	"time"
 
+	"tomo/backend/events"
 	"tomo/backend/middleware"
 	"tomo/backend/models"
+	"tomo/backend/storage"
 	"tomo/backend/utils"
 )
 
 type UserHandler struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Events  *events.Bus
+	Storage storage.Storage
 }
 
 type UpdateProfileRequest struct {
-	Username    *string `json:"username,omitempty"`
-	DisplayName *string `json:"display_name,omitempty"`
-	PictureURL  *string `json:"picture_url,omitempty"`
+	Username       *string `json:"username,omitempty"`
+	DisplayName    *string `json:"display_name,omitempty"`
+	PictureURL     *string `json:"picture_url,omitempty"`
+	SearchLanguage *string `json:"search_language,omitempty"` // one of models.SearchLanguages
+	Timezone       *string `json:"timezone,omitempty"`        // IANA name, e.g. 'Europe/Berlin'
 }
 
 // GET /me — return currently authenticated user
@@ -111,6 +118,25 @@ func (h *UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	searchLanguage := currentUser.SearchLanguage
+	if req.SearchLanguage != nil {
+		searchLanguage = strings.ToLower(strings.TrimSpace(*req.SearchLanguage))
+		if !contains(models.SearchLanguages, searchLanguage) {
+			http.Error(w, "unsupported search_language", http.StatusBadRequest)
+			return
+		}
+	}
+
+	timezone := currentUser.Timezone
+	if req.Timezone != nil {
+		timezone = strings.TrimSpace(*req.Timezone)
+		// "Local" would mean the server's zone, which users can't know
+		if _, err := time.LoadLocation(timezone); err != nil || timezone == "" || timezone == "Local" {
+			http.Error(w, "timezone must be an IANA name like 'Europe/Berlin'", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Update profile
 	if err := models.UpdateProfile(h.DB, user.UserID, username, displayName, pictureURL); err != nil {
 		if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "unique constraint") {
@@ -121,6 +147,21 @@ func (h *UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Changing the language re-indexes every post, so only do it when needed
+	if searchLanguage != currentUser.SearchLanguage {
+		if err := models.SetSearchLanguage(h.DB, user.UserID, searchLanguage); err != nil {
+			http.Error(w, "failed to update search language", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if timezone != currentUser.Timezone {
+		if err := models.SetTimezone(h.DB, user.UserID, timezone); err != nil {
+			http.Error(w, "failed to update timezone", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// Return updated user
 	updatedUser, err := models.GetUserByID(h.DB, user.UserID)
 	if err != nil {
@@ -131,6 +172,50 @@ func (h *UserHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJSON(w, http.StatusOK, updatedUser)
 }
 
+// viewerID returns the signed-in user's ID on routes with optional auth, or 0
+func viewerID(r *http.Request) int {
+	viewer, signedIn := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
+	if !signedIn {
+		return 0
+	}
+	return viewer.UserID
+}
+
+// loadProfileUser fetches the user named by {username} along with the viewer's
+// ID (0 when signed out), writing a 404 if the viewer may not see them
+func loadProfileUser(w http.ResponseWriter, r *http.Request, db *sql.DB) (models.User, int, bool) {
+	username := r.PathValue("username")
+	if username == "" {
+		http.Error(w, "username is required", http.StatusBadRequest)
+		return models.User{}, 0, false
+	}
+
+	user, err := models.GetUserByUsername(db, username)
+	if err == sql.ErrNoRows {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return models.User{}, 0, false
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return models.User{}, 0, false
+	}
+
+	// Suspended users are hidden from everyone else, and signed-in viewers never
+	// see users they have blocked or been blocked by
+	viewer := viewerID(r)
+	hidden, err := models.IsHiddenFrom(db, user.ID, viewer)
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return models.User{}, 0, false
+	}
+	if hidden {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return models.User{}, 0, false
+	}
+
+	return user, viewer, true
+}
+
 // DELETE /me — delete the current user
 func (h *UserHandler) DeleteMe(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(middleware.UserContextKey).(middleware.UserClaims)
@@ -139,29 +224,21 @@ func (h *UserHandler) DeleteMe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := models.DeleteUser(h.DB, user.UserID); err != nil {
+	// Delete the user and everything they own, then their media files
+	unused, err := models.DeleteUser(h.DB, user.UserID)
+	if err != nil {
 		http.Error(w, "failed to delete user", http.StatusInternalServerError)
 		return
 	}
+	deleteStoredFiles(h.Storage, unused)
 
 	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "user deleted"})
 }
 
-// GET /users/{username} — get user by username (public)
+// GET /users/{username} — get user by username (public; hidden when suspended or blocked)
 func (h *UserHandler) GetUserByUsername(w http.ResponseWriter, r *http.Request) {
-	username := r.PathValue("username")
-	if username == "" {
-		http.Error(w, "username is required", http.StatusBadRequest)
-		return
-	}
-
-	user, err := models.GetUserByUsername(h.DB, username)
-	if err == sql.ErrNoRows {
-		http.Error(w, "user not found", http.StatusNotFound)
-		return
-	}
-	if err != nil {
-		http.Error(w, "database error", http.StatusInternalServerError)
+	user, _, ok := loadProfileUser(w, r, h.DB)
+	if !ok {
 		return
 	}
 
@@ -0,0 +1,120 @@
+// Package jobs runs periodic background work alongside the API server.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"tomo/backend/events"
+	"tomo/backend/models"
+	"tomo/backend/storage"
+	"tomo/backend/utils"
+)
+
+// Every runs fn immediately and then once per interval until ctx is cancelled.
+// Errors are logged; the job keeps running.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := fn(ctx); err != nil {
+				log.Printf("jobs: %s failed: %v", name, err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Start schedules all background jobs. Jobs publish their domain events to bus.
+func Start(ctx context.Context, db *sql.DB, bus *events.Bus, store storage.Storage) {
+	Every(ctx, "finalize challenges", time.Minute, FinalizeChallenges(db))
+	Every(ctx, "publish scheduled posts", time.Minute, PublishScheduledPosts(db, bus))
+	Every(ctx, "expire pending uploads", 5*time.Minute, ExpirePendingUploads(db, store))
+	Every(ctx, "refresh post html", 10*time.Minute, RefreshContentHTML(db, utils.PostMarkdownOptions(store.BaseURL())))
+}
+
+// refreshBatchSize is how many posts RefreshContentHTML re-renders per query
+const refreshBatchSize = 100
+
+// RefreshContentHTML re-renders cached post HTML left over from an older
+// Markdown renderer or sanitizer policy, in batches until none is left. Posts
+// are rendered with opts, like on create and update.
+func RefreshContentHTML(db *sql.DB, opts utils.MarkdownOptions) func(context.Context) error {
+	return func(ctx context.Context) error {
+		lastID := 0
+		for ctx.Err() == nil {
+			var err error
+			lastID, err = models.RefreshStaleContentHTML(db, opts, lastID, refreshBatchSize)
+			if err != nil || lastID == 0 {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// ExpirePendingUploads discards presigned uploads that were never completed,
+// along with any file that did reach storage. Only 'pending' rows past their
+// expires_at are touched; completed media is 'active' and has no expiry.
+func ExpirePendingUploads(db *sql.DB, store storage.Storage) func(context.Context) error {
+	return func(ctx context.Context) error {
+		keys, err := models.DeleteExpiredPendingMedia(db)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			// Nothing was uploaded for most of these; deleting a missing key succeeds
+			if err := store.Delete(ctx, key); err != nil {
+				log.Printf("jobs: failed to delete stored file %s: %v", key, err)
+			}
+		}
+		return nil
+	}
+}
+
+// PublishScheduledPosts publishes posts whose publish_at has passed. Feeds pick
+// them up from then on; PostPublished fans out notifications and device sync.
+// Posts emptied since they were scheduled go back to being drafts.
+func PublishScheduledPosts(db *sql.DB, bus *events.Bus) func(context.Context) error {
+	return func(ctx context.Context) error {
+		published, reverted, err := models.PublishDuePosts(db)
+		if err != nil {
+			return err
+		}
+		for _, post := range published {
+			bus.Publish(events.Event{Type: events.PostPublished, ActorID: post.UserID, PostID: post.ID})
+		}
+		for _, post := range reverted {
+			bus.Publish(events.Event{Type: events.PostUpdated, ActorID: post.UserID, PostID: post.ID})
+		}
+		return nil
+	}
+}
+
+// FinalizeChallenges stores final results for challenges that have ended
+func FinalizeChallenges(db *sql.DB) func(context.Context) error {
+	return func(ctx context.Context) error {
+		ids, err := models.GetUnfinalizedEndedChallengeIDs(db)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err := models.FinalizeChallenge(db, id); err != nil {
+				log.Printf("jobs: failed to finalize challenge %d: %v", id, err)
+			}
+		}
+		return nil
+	}
+}
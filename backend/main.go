@@ -11,11 +11,14 @@ import (
 	"time"
 
 	"tomo/backend/config"
+	"tomo/backend/events"
+	"tomo/backend/jobs"
 	"tomo/backend/routes"
 )
 
 func main() {
 	config.ConnectDB()
+	config.ConnectStorage()
 	defer func() {
 		if config.DB != nil {
 			if err := config.DB.Close(); err != nil {
@@ -30,7 +33,18 @@ func main() {
 	}
 	addr := ":" + port
 
-	router := routes.NewRouter(config.DB)
+	// Domain events, published by handlers and background jobs
+	bus := events.NewBus()
+	router := routes.NewRouter(config.DB, bus, config.Storage)
+
+	// Background jobs stop when the server shuts down. They start after the
+	// router so event subscribers are registered before the first run.
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	if config.DB != nil {
+		jobs.Start(jobsCtx, config.DB, bus, config.Storage)
+	}
+
 	handler := withCORS(logRequests(router))
 
 	server := &http.Server{
@@ -53,6 +67,8 @@ func main() {
 	<-quit
 
 	log.Println("shutting down API…")
+	stopJobs()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -85,7 +101,7 @@ func withCORS(next http.Handler) http.Handler {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 		}
 
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PATCH,DELETE,OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,PATCH,DELETE,OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		if credentialsAllowed {
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
@@ -2,9 +2,11 @@ package middleware
 
 import (
 	"context"
+	"database/sql"
 	"net/http"
 	"strings"
 
+	"tomo/backend/models"
 	"tomo/backend/utils"
 )
 
@@ -17,6 +19,17 @@ const UserContextKey = contextKey("user")
 type UserClaims struct {
 	UserID int
 	Email  string
+	Role   string // 'user', 'moderator' or 'admin', as currently stored for the user
+}
+
+// userDB is where every authenticated request's user is looked up; see UseDB
+var userDB *sql.DB
+
+// UseDB sets the database the middleware checks users against. A token only
+// identifies its user: roles are read from the users table, so role changes
+// and suspensions take effect on tokens already issued.
+func UseDB(db *sql.DB) {
+	userDB = db
 }
 
 // AuthMiddleware validates JWTs for protected routes
@@ -35,25 +48,118 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		tokenStr := parts[1]
+		authenticate(w, r, next, parts[1])
+	})
+}
+
+// OptionalAuthMiddleware is AuthMiddleware for public routes that tailor their
+// response to the viewer. Requests without an Authorization header pass through
+// anonymously; a header that is present must still be valid.
+func OptionalAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		AuthMiddleware(next).ServeHTTP(w, r)
+	})
+}
 
-		// Validate token using utils.ValidateToken
-		claims, err := utils.ValidateToken(tokenStr)
-		if err != nil {
-			http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+// StreamAuthMiddleware is AuthMiddleware for streaming endpoints (SSE,
+// WebSocket). Browsers cannot set headers on EventSource or WebSocket
+// connections, so they pass a single-use ?ticket= from IssueStreamTicket instead.
+func StreamAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			AuthMiddleware(next).ServeHTTP(w, r)
 			return
 		}
 
-		user := UserClaims{
-			UserID: int((*claims)["sub"].(float64)), // JWT numbers decode as float64
-			Email:  (*claims)["email"].(string),
+		ticket := r.URL.Query().Get("ticket")
+		if ticket == "" {
+			http.Error(w, "missing Authorization header or ticket", http.StatusUnauthorized)
+			return
+		}
+		user, ok := redeemStreamTicket(ticket)
+		if !ok {
+			http.Error(w, "invalid or expired ticket", http.StatusUnauthorized)
+			return
 		}
 
-		ctx := context.WithValue(r.Context(), UserContextKey, user)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		serveUser(w, r, next, user)
 	})
 }
 
+// RequireRole allows only users whose role is one of roles. It must run after
+// AuthMiddleware, e.g. AuthMiddleware(RequireRole("admin")(handler)), which
+// sets the role from the database rather than the token.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := r.Context().Value(UserContextKey).(UserClaims)
+			if !ok {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			for _, role := range roles {
+				if user.Role == role {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			http.Error(w, "forbidden", http.StatusForbidden)
+		})
+	}
+}
+
+// authenticate validates tokenStr and calls next with the user in the context
+func authenticate(w http.ResponseWriter, r *http.Request, next http.Handler, tokenStr string) {
+	// Validate token using utils.ValidateToken
+	claims, err := utils.ValidateToken(tokenStr)
+	if err != nil {
+		http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+		return
+	}
+
+	// Roles are never read from the token; serveUser fills in the current role
+	user := UserClaims{
+		UserID: int((*claims)["sub"].(float64)), // JWT numbers decode as float64
+		Email:  (*claims)["email"].(string),
+	}
+
+	serveUser(w, r, next, user)
+}
+
+// serveUser checks that user still exists and isn't suspended, then calls
+// next with the user, and their current role, in the context
+func serveUser(w http.ResponseWriter, r *http.Request, next http.Handler, user UserClaims) {
+	if userDB == nil {
+		http.Error(w, "authentication unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	current, err := models.GetUserByID(userDB, user.UserID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "user not found", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	if current.SuspendedAt != nil {
+		http.Error(w, "account suspended", http.StatusForbidden)
+		return
+	}
+
+	user.Role = current.Role
+
+	ctx := context.WithValue(r.Context(), UserContextKey, user)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
 // Helper to extract user info from context
 func GetUserFromContext(r *http.Request) (map[string]interface{}, bool) {
 	user, ok := r.Context().Value(UserContextKey).(map[string]interface{})
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	"tomo/backend/utils"
+)
+
+const (
+	// StreamTicketTTL is how long a stream ticket can be redeemed after it is issued
+	StreamTicketTTL = 30 * time.Second
+	// streamTicketLength is the length of ticket strings (62^32 possibilities)
+	streamTicketLength = 32
+)
+
+// Stream tickets let browsers authenticate EventSource and WebSocket
+// connections, which can't carry an Authorization header, without putting a
+// long-lived JWT in a URL (where it ends up in logs and history). A ticket is
+// issued to an authenticated user, works once, and expires quickly. Tickets
+// live in memory, so they must be redeemed on the instance that issued them.
+var streamTickets = struct {
+	sync.Mutex
+	byTicket map[string]streamTicket
+}{byTicket: make(map[string]streamTicket)}
+
+type streamTicket struct {
+	user      UserClaims
+	expiresAt time.Time
+}
+
+// IssueStreamTicket returns a single-use ticket that authenticates one stream
+// connection as user within StreamTicketTTL
+func IssueStreamTicket(user UserClaims) (string, error) {
+	ticket, err := utils.RandomSlug(streamTicketLength)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	streamTickets.Lock()
+	defer streamTickets.Unlock()
+
+	// Drop tickets that were never redeemed
+	for t, st := range streamTickets.byTicket {
+		if now.After(st.expiresAt) {
+			delete(streamTickets.byTicket, t)
+		}
+	}
+	streamTickets.byTicket[ticket] = streamTicket{user: user, expiresAt: now.Add(StreamTicketTTL)}
+	return ticket, nil
+}
+
+// redeemStreamTicket consumes a ticket, returning its user if it was still valid
+func redeemStreamTicket(ticket string) (UserClaims, bool) {
+	streamTickets.Lock()
+	defer streamTickets.Unlock()
+
+	st, ok := streamTickets.byTicket[ticket]
+	if !ok {
+		return UserClaims{}, false
+	}
+	delete(streamTickets.byTicket, ticket)
+	if time.Now().After(st.expiresAt) {
+		return UserClaims{}, false
+	}
+	return st.user, true
+}
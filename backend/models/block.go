@@ -0,0 +1,144 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RelatedUser is another user's public profile with the time a block or mute was created
+type RelatedUser struct {
+	UserID      int       `json:"user_id"`
+	Username    string    `json:"username,omitempty"`
+	DisplayName string    `json:"display_name,omitempty"`
+	PictureURL  string    `json:"picture_url,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// blockedBetween returns a SQL condition that is true when the user in column
+// col and the user bound to param have blocked each other in either direction
+func blockedBetween(col, param string) string {
+	return `EXISTS (SELECT 1 FROM user_blocks b
+	                WHERE (b.blocker_id = ` + param + ` AND b.blocked_id = ` + col + `)
+	                   OR (b.blocker_id = ` + col + ` AND b.blocked_id = ` + param + `))`
+}
+
+// CREATE: block a user (no-op if already blocked). Blocking also clears any
+// mute, since a block already hides everything a mute would, and removes
+// follows in both directions.
+func BlockUser(db *sql.DB, blockerID, blockedID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+		 VALUES ($1, $2, NOW())
+		 ON CONFLICT DO NOTHING`,
+		blockerID, blockedID,
+	); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(
+		`DELETE FROM user_mutes WHERE muter_id=$1 AND muted_id=$2`,
+		blockerID, blockedID,
+	); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(
+		`DELETE FROM follows
+		 WHERE (follower_id=$1 AND followed_id=$2) OR (follower_id=$2 AND followed_id=$1)`,
+		blockerID, blockedID,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DELETE: unblock a user
+func UnblockUser(db *sql.DB, blockerID, blockedID int) error {
+	_, err := db.Exec(
+		`DELETE FROM user_blocks WHERE blocker_id=$1 AND blocked_id=$2`,
+		blockerID, blockedID,
+	)
+	return err
+}
+
+// READ: check whether either user has blocked the other
+func IsBlockedEitherWay(db *sql.DB, userA, userB int) (bool, error) {
+	if userA == userB {
+		return false, nil
+	}
+	var blocked bool
+	err := db.QueryRow(
+		`SELECT `+blockedBetween("$2", "$1"),
+		userA, userB,
+	).Scan(&blocked)
+	return blocked, err
+}
+
+// READ: list the users someone has blocked, most recent first
+func GetBlockedUsers(db *sql.DB, userID int) ([]RelatedUser, error) {
+	return queryRelatedUsers(db,
+		`SELECT u.id, COALESCE(u.username, ''), COALESCE(u.display_name, ''), COALESCE(u.picture_url, ''), b.created_at
+		 FROM user_blocks b
+		 JOIN users u ON u.id = b.blocked_id
+		 WHERE b.blocker_id=$1
+		 ORDER BY b.created_at DESC`,
+		userID,
+	)
+}
+
+// CREATE: mute a user (no-op if already muted)
+func MuteUser(db *sql.DB, muterID, mutedID int) error {
+	_, err := db.Exec(
+		`INSERT INTO user_mutes (muter_id, muted_id, created_at)
+		 VALUES ($1, $2, NOW())
+		 ON CONFLICT DO NOTHING`,
+		muterID, mutedID,
+	)
+	return err
+}
+
+// DELETE: unmute a user
+func UnmuteUser(db *sql.DB, muterID, mutedID int) error {
+	_, err := db.Exec(
+		`DELETE FROM user_mutes WHERE muter_id=$1 AND muted_id=$2`,
+		muterID, mutedID,
+	)
+	return err
+}
+
+// READ: list the users someone has muted, most recent first
+func GetMutedUsers(db *sql.DB, userID int) ([]RelatedUser, error) {
+	return queryRelatedUsers(db,
+		`SELECT u.id, COALESCE(u.username, ''), COALESCE(u.display_name, ''), COALESCE(u.picture_url, ''), m.created_at
+		 FROM user_mutes m
+		 JOIN users u ON u.id = m.muted_id
+		 WHERE m.muter_id=$1
+		 ORDER BY m.created_at DESC`,
+		userID,
+	)
+}
+
+func queryRelatedUsers(db *sql.DB, query string, args ...interface{}) ([]RelatedUser, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []RelatedUser{}
+	for rows.Next() {
+		var u RelatedUser
+		if err := rows.Scan(&u.UserID, &u.Username, &u.DisplayName, &u.PictureURL, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,363 @@
+package models
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// Challenge is a focus competition over a fixed period
+type Challenge struct {
+	ID               int        `json:"id"`
+	CreatorID        int        `json:"creator_id"`
+	Title            string     `json:"title"`
+	Description      string     `json:"description,omitempty"`
+	Metric           string     `json:"metric"`                      // 'total_minutes', 'days_over_threshold' or 'longest_streak'
+	ThresholdMinutes int        `json:"threshold_minutes,omitempty"` // minutes for a day to count (day-based metrics)
+	StartsAt         time.Time  `json:"starts_at"`
+	EndsAt           time.Time  `json:"ends_at"`
+	FinalizedAt      *time.Time `json:"finalized_at,omitempty"` // set once results are final
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// Standing is a participant's position in a challenge
+type Standing struct {
+	Rank        int    `json:"rank"`
+	UserID      int    `json:"user_id"`
+	Username    string `json:"username,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	PictureURL  string `json:"picture_url,omitempty"`
+	Score       int    `json:"score"` // minutes, days or streak length depending on the metric
+}
+
+// DailyTotal is a user's focus minutes on one calendar day
+type DailyTotal struct {
+	Day     time.Time
+	Minutes int
+}
+
+const challengeColumns = `id, creator_id, title, COALESCE(description, ''), metric, threshold_minutes, starts_at, ends_at, finalized_at, created_at`
+
+func scanChallenge(row rowScanner) (Challenge, error) {
+	var c Challenge
+	err := row.Scan(&c.ID, &c.CreatorID, &c.Title, &c.Description, &c.Metric, &c.ThresholdMinutes, &c.StartsAt, &c.EndsAt, &c.FinalizedAt, &c.CreatedAt)
+	return c, err
+}
+
+// CREATE: insert a new challenge
+func CreateChallenge(db *sql.DB, c Challenge) (Challenge, error) {
+	return scanChallenge(db.QueryRow(
+		`INSERT INTO challenges (creator_id, title, description, metric, threshold_minutes, starts_at, ends_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		 RETURNING `+challengeColumns,
+		c.CreatorID, c.Title, c.Description, c.Metric, c.ThresholdMinutes, c.StartsAt, c.EndsAt,
+	))
+}
+
+// READ: get a challenge by ID
+func GetChallengeByID(db *sql.DB, challengeID int) (Challenge, error) {
+	return scanChallenge(db.QueryRow(
+		`SELECT `+challengeColumns+`
+		 FROM challenges
+		 WHERE id=$1`,
+		challengeID,
+	))
+}
+
+// READ: list challenges by status ('upcoming', 'active' or 'finished')
+func GetChallenges(db *sql.DB, status string, limit, offset int) ([]Challenge, error) {
+	var where, order string
+	switch status {
+	case "upcoming":
+		where, order = "starts_at > NOW()", "starts_at ASC"
+	case "finished":
+		where, order = "ends_at <= NOW()", "ends_at DESC"
+	default:
+		where, order = "starts_at <= NOW() AND ends_at > NOW()", "ends_at ASC"
+	}
+
+	rows, err := db.Query(
+		`SELECT `+challengeColumns+`
+		 FROM challenges
+		 WHERE `+where+`
+		 ORDER BY `+order+`
+		 LIMIT $1 OFFSET $2`,
+		limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	challenges := []Challenge{}
+	for rows.Next() {
+		c, err := scanChallenge(rows)
+		if err != nil {
+			return nil, err
+		}
+		challenges = append(challenges, c)
+	}
+	return challenges, rows.Err()
+}
+
+// READ: get the challenges a user has joined, most recent first
+func GetChallengesForUser(db *sql.DB, userID int) ([]Challenge, error) {
+	rows, err := db.Query(
+		`SELECT c.id, c.creator_id, c.title, COALESCE(c.description, ''), c.metric, c.threshold_minutes,
+		        c.starts_at, c.ends_at, c.finalized_at, c.created_at
+		 FROM challenges c
+		 JOIN challenge_participants p ON p.challenge_id = c.id
+		 WHERE p.user_id=$1
+		 ORDER BY c.starts_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	challenges := []Challenge{}
+	for rows.Next() {
+		c, err := scanChallenge(rows)
+		if err != nil {
+			return nil, err
+		}
+		challenges = append(challenges, c)
+	}
+	return challenges, rows.Err()
+}
+
+// READ: IDs of challenges that have ended but have not been finalized
+func GetUnfinalizedEndedChallengeIDs(db *sql.DB) ([]int, error) {
+	rows, err := db.Query(
+		`SELECT id FROM challenges WHERE ends_at <= NOW() AND finalized_at IS NULL`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// READ: check whether a user has joined a challenge
+func IsChallengeParticipant(db *sql.DB, challengeID, userID int) (bool, error) {
+	var exists bool
+	err := db.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM challenge_participants WHERE challenge_id=$1 AND user_id=$2)`,
+		challengeID, userID,
+	).Scan(&exists)
+	return exists, err
+}
+
+// CREATE: join a challenge (no-op if already joined)
+func JoinChallenge(db *sql.DB, challengeID, userID int) error {
+	_, err := db.Exec(
+		`INSERT INTO challenge_participants (challenge_id, user_id, joined_at)
+		 VALUES ($1, $2, NOW())
+		 ON CONFLICT DO NOTHING`,
+		challengeID, userID,
+	)
+	return err
+}
+
+// DELETE: leave a challenge
+func LeaveChallenge(db *sql.DB, challengeID, userID int) error {
+	_, err := db.Exec(
+		`DELETE FROM challenge_participants WHERE challenge_id=$1 AND user_id=$2`,
+		challengeID, userID,
+	)
+	return err
+}
+
+// DELETE: remove a challenge
+func DeleteChallenge(db *sql.DB, challengeID int) error {
+	_, err := db.Exec(`DELETE FROM challenges WHERE id=$1`, challengeID)
+	return err
+}
+
+// READ: compute current standings from focus_sessions. Sessions count toward
+// the day (UTC) and period in which they started.
+func ComputeChallengeStandings(db DBTX, c Challenge) ([]Standing, error) {
+	rows, err := db.Query(
+		`SELECT u.id, COALESCE(u.username, ''), COALESCE(u.display_name, ''), COALESCE(u.picture_url, ''),
+		        d.day, COALESCE(d.minutes, 0)
+		 FROM challenge_participants p
+		 JOIN users u ON u.id = p.user_id
+		 LEFT JOIN (
+		     SELECT user_id, date_trunc('day', start_time AT TIME ZONE 'UTC') AS day, SUM(duration_minutes) AS minutes
+		     FROM focus_sessions
+		     WHERE start_time >= $2 AND start_time < $3
+		     GROUP BY 1, 2
+		 ) d ON d.user_id = p.user_id
+		 WHERE p.challenge_id=$1
+		 ORDER BY u.id, d.day`,
+		c.ID, c.StartsAt, c.EndsAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var standings []Standing
+	daily := make(map[int][]DailyTotal)
+	for rows.Next() {
+		var s Standing
+		var day *time.Time
+		var minutes int
+		if err := rows.Scan(&s.UserID, &s.Username, &s.DisplayName, &s.PictureURL, &day, &minutes); err != nil {
+			return nil, err
+		}
+		if _, seen := daily[s.UserID]; !seen {
+			standings = append(standings, s)
+			daily[s.UserID] = []DailyTotal{}
+		}
+		if day != nil {
+			daily[s.UserID] = append(daily[s.UserID], DailyTotal{Day: *day, Minutes: minutes})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range standings {
+		standings[i].Score = ChallengeScore(c.Metric, c.ThresholdMinutes, daily[standings[i].UserID])
+	}
+	RankStandings(standings)
+	return standings, nil
+}
+
+// ChallengeScore scores a participant's daily totals (sorted by day) for a metric
+func ChallengeScore(metric string, thresholdMinutes int, days []DailyTotal) int {
+	switch metric {
+	case "days_over_threshold":
+		count := 0
+		for _, d := range days {
+			if d.Minutes >= thresholdMinutes {
+				count++
+			}
+		}
+		return count
+
+	case "longest_streak":
+		longest, current := 0, 0
+		var prev time.Time
+		for _, d := range days {
+			if d.Minutes < thresholdMinutes {
+				current = 0
+				continue
+			}
+			if current > 0 && d.Day.Sub(prev) == 24*time.Hour {
+				current++
+			} else {
+				current = 1
+			}
+			prev = d.Day
+			if current > longest {
+				longest = current
+			}
+		}
+		return longest
+
+	default: // total_minutes
+		total := 0
+		for _, d := range days {
+			total += d.Minutes
+		}
+		return total
+	}
+}
+
+// RankStandings sorts by score (highest first) and assigns ranks; equal scores share a rank
+func RankStandings(standings []Standing) {
+	sort.SliceStable(standings, func(i, j int) bool {
+		return standings[i].Score > standings[j].Score
+	})
+	for i := range standings {
+		standings[i].Rank = i + 1
+		if i > 0 && standings[i].Score == standings[i-1].Score {
+			standings[i].Rank = standings[i-1].Rank
+		}
+	}
+}
+
+// READ: get the stored final results of a finalized challenge
+func GetFinalStandings(db *sql.DB, challengeID int) ([]Standing, error) {
+	rows, err := db.Query(
+		`SELECT p.final_rank, u.id, COALESCE(u.username, ''), COALESCE(u.display_name, ''), COALESCE(u.picture_url, ''), p.final_score
+		 FROM challenge_participants p
+		 JOIN users u ON u.id = p.user_id
+		 WHERE p.challenge_id=$1
+		 ORDER BY p.final_rank ASC, u.id ASC`,
+		challengeID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	standings := []Standing{}
+	for rows.Next() {
+		var s Standing
+		if err := rows.Scan(&s.Rank, &s.UserID, &s.Username, &s.DisplayName, &s.PictureURL, &s.Score); err != nil {
+			return nil, err
+		}
+		standings = append(standings, s)
+	}
+	return standings, rows.Err()
+}
+
+// UPDATE: compute and store final results once a challenge has ended.
+// Safe to call concurrently; only the first caller stores results.
+func FinalizeChallenge(db *sql.DB, challengeID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the challenge row so two finalizers cannot interleave
+	c, err := scanChallenge(tx.QueryRow(
+		`SELECT `+challengeColumns+`
+		 FROM challenges
+		 WHERE id=$1
+		 FOR UPDATE`,
+		challengeID,
+	))
+	if err != nil {
+		return err
+	}
+	if c.FinalizedAt != nil || time.Now().Before(c.EndsAt) {
+		return nil
+	}
+
+	standings, err := ComputeChallengeStandings(tx, c)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range standings {
+		if _, err := tx.Exec(
+			`UPDATE challenge_participants
+			 SET final_rank=$1, final_score=$2
+			 WHERE challenge_id=$3 AND user_id=$4`,
+			s.Rank, s.Score, c.ID, s.UserID,
+		); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE challenges SET finalized_at=NOW() WHERE id=$1`, c.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,284 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MaxCollectionPosts caps how many posts a collection can hold
+const MaxCollectionPosts = 200
+
+var (
+	ErrCollectionFull  = errors.New("collection is full")
+	ErrCollectionOrder = errors.New("post_ids must list every post in the collection exactly once")
+)
+
+// Collection is a named, ordered list of a user's own posts
+type Collection struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Visibility  string    `json:"visibility"` // 'private' or 'public'
+	PostCount   int       `json:"post_count"` // posts the viewer can see
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// collectionColumns selects collections c with the number of posts the viewer
+// bound to viewerParam can see: all of them for the owner, otherwise only
+// those a profile would show (see profileConditions)
+func collectionColumns(viewerParam string) string {
+	return `c.id, c.user_id, c.name, COALESCE(c.description, ''), c.visibility, c.created_at, c.updated_at,
+		        (SELECT COUNT(*)
+		         FROM collection_posts cp
+		         JOIN posts p ON p.id = cp.post_id
+		         WHERE cp.collection_id = c.id
+		           AND (c.user_id = ` + viewerParam + ` OR (` + profileConditions + `)))`
+}
+
+func scanCollection(row rowScanner) (Collection, error) {
+	var c Collection
+	err := row.Scan(&c.ID, &c.UserID, &c.Name, &c.Description, &c.Visibility, &c.CreatedAt, &c.UpdatedAt, &c.PostCount)
+	return c, err
+}
+
+// ValidateCollectionOrder checks that order lists exactly the posts in current,
+// each once
+func ValidateCollectionOrder(current, order []int) error {
+	if len(order) != len(current) {
+		return ErrCollectionOrder
+	}
+	remaining := make(map[int]bool, len(current))
+	for _, id := range current {
+		remaining[id] = true
+	}
+	for _, id := range order {
+		if !remaining[id] {
+			return ErrCollectionOrder
+		}
+		delete(remaining, id)
+	}
+	return nil
+}
+
+// CREATE: insert a new, empty collection
+func CreateCollection(db *sql.DB, c Collection) (Collection, error) {
+	var id int
+	if err := db.QueryRow(
+		`INSERT INTO collections (user_id, name, description, visibility, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, NOW(), NOW())
+		 RETURNING id`,
+		c.UserID, c.Name, c.Description, c.Visibility,
+	).Scan(&id); err != nil {
+		return Collection{}, err
+	}
+	return GetCollectionByID(db, id, c.UserID)
+}
+
+// READ: get a collection, counting the posts viewerID can see
+func GetCollectionByID(db *sql.DB, collectionID, viewerID int) (Collection, error) {
+	return scanCollection(db.QueryRow(
+		`SELECT `+collectionColumns("$2")+`
+		 FROM collections c
+		 WHERE c.id=$1`,
+		collectionID, viewerID,
+	))
+}
+
+// READ: get a user's collections, most recently updated first. Other viewers
+// only get the public ones.
+func GetCollectionsForUser(db *sql.DB, userID, viewerID int) ([]Collection, error) {
+	rows, err := db.Query(
+		`SELECT `+collectionColumns("$2")+`
+		 FROM collections c
+		 WHERE c.user_id=$1 AND (c.user_id=$2 OR c.visibility='public')
+		 ORDER BY c.updated_at DESC, c.id DESC`,
+		userID, viewerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []Collection{}
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
+
+// UPDATE: change a collection's name, description and visibility
+func UpdateCollection(db *sql.DB, c Collection) error {
+	_, err := db.Exec(
+		`UPDATE collections
+		 SET name=$1, description=$2, visibility=$3, updated_at=NOW()
+		 WHERE id=$4`,
+		c.Name, c.Description, c.Visibility, c.ID,
+	)
+	return err
+}
+
+// DELETE: remove a collection. Its posts are not affected.
+func DeleteCollection(db *sql.DB, collectionID int) error {
+	_, err := db.Exec(`DELETE FROM collections WHERE id=$1`, collectionID)
+	return err
+}
+
+// READ: get the posts in a collection, in order. The owner sees every post;
+// other viewers only see posts a profile would show.
+func GetCollectionPosts(db *sql.DB, c Collection, viewerID int) ([]PostWithDetails, error) {
+	return queryPostsWithDetails(db, viewerID,
+		`SELECT `+postColumns+`
+		 FROM posts p
+		 JOIN collection_posts cp ON cp.post_id = p.id
+		 WHERE cp.collection_id=$1
+		   AND ($2::boolean OR (`+profileConditions+`))
+		 ORDER BY cp.position ASC`,
+		c.ID, c.UserID == viewerID,
+	)
+}
+
+// UPDATE: append a post to a collection. Adding a post that is already in it
+// is a no-op. Returns ErrCollectionFull at MaxCollectionPosts.
+func AddPostToCollection(db *sql.DB, collectionID, postID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the collection so concurrent adds get distinct positions
+	if _, err := tx.Exec(`SELECT 1 FROM collections WHERE id=$1 FOR UPDATE`, collectionID); err != nil {
+		return err
+	}
+
+	var count int
+	if err := tx.QueryRow(
+		`SELECT COUNT(*) FROM collection_posts WHERE collection_id=$1`,
+		collectionID,
+	).Scan(&count); err != nil {
+		return err
+	}
+	if count >= MaxCollectionPosts {
+		return ErrCollectionFull
+	}
+
+	res, err := tx.Exec(
+		`INSERT INTO collection_posts (collection_id, post_id, position, added_at)
+		 SELECT $1, $2::int, COALESCE(MAX(position) + 1, 0), NOW()
+		 FROM collection_posts
+		 WHERE collection_id=$1
+		 ON CONFLICT DO NOTHING`,
+		collectionID, postID,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// Already in the collection
+		return nil
+	}
+
+	if _, err := tx.Exec(`UPDATE collections SET updated_at=NOW() WHERE id=$1`, collectionID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UPDATE: remove a post from a collection. Returns sql.ErrNoRows if it wasn't in it.
+func RemovePostFromCollection(db *sql.DB, collectionID, postID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		`DELETE FROM collection_posts WHERE collection_id=$1 AND post_id=$2`,
+		collectionID, postID,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(`UPDATE collections SET updated_at=NOW() WHERE id=$1`, collectionID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UPDATE: reorder a collection. postIDs must list every post in it exactly
+// once (ErrCollectionOrder otherwise).
+func ReorderCollection(db *sql.DB, collectionID int, postIDs []int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(
+		`SELECT post_id FROM collection_posts WHERE collection_id=$1 FOR UPDATE`,
+		collectionID,
+	)
+	if err != nil {
+		return err
+	}
+	var current []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		current = append(current, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := ValidateCollectionOrder(current, postIDs); err != nil {
+		return err
+	}
+
+	ids := make([]int64, len(postIDs))
+	for i, id := range postIDs {
+		ids[i] = int64(id)
+	}
+	if _, err := tx.Exec(
+		`UPDATE collection_posts cp
+		 SET position = o.n - 1
+		 FROM unnest($2::int[]) WITH ORDINALITY AS o(post_id, n)
+		 WHERE cp.collection_id=$1 AND cp.post_id = o.post_id`,
+		collectionID, pq.Array(ids),
+	); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE collections SET updated_at=NOW() WHERE id=$1`, collectionID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Comment represents a comment on a post. Replies point at a top-level
+// comment through ParentID; replies to replies are not allowed.
+type Comment struct {
+	ID        int        `json:"id"`
+	PostID    int        `json:"post_id"`
+	UserID    int        `json:"user_id"`
+	ParentID  *int       `json:"parent_id,omitempty"` // NULL for top-level comments
+	Content   string     `json:"content"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"` // set once the author edits
+}
+
+// CommentThread is a top-level comment with its replies
+type CommentThread struct {
+	Comment
+	Replies []Comment `json:"replies"`
+}
+
+const commentColumns = `id, post_id, user_id, parent_id, content, created_at, updated_at`
+
+func scanComment(row rowScanner) (Comment, error) {
+	var c Comment
+	err := row.Scan(&c.ID, &c.PostID, &c.UserID, &c.ParentID, &c.Content, &c.CreatedAt, &c.UpdatedAt)
+	return c, err
+}
+
+// CREATE: add a comment (or a reply when parentID is set) to a post
+func CreateComment(db *sql.DB, postID, userID int, parentID *int, content string) (Comment, error) {
+	return scanComment(db.QueryRow(
+		`INSERT INTO comments (post_id, user_id, parent_id, content, created_at)
+		 VALUES ($1, $2, $3, $4, NOW())
+		 RETURNING `+commentColumns,
+		postID, userID, parentID, content,
+	))
+}
+
+// READ: get a comment by ID
+func GetCommentByID(db *sql.DB, commentID int) (Comment, error) {
+	return scanComment(db.QueryRow(
+		`SELECT `+commentColumns+`
+		 FROM comments
+		 WHERE id=$1`,
+		commentID,
+	))
+}
+
+// READ: get all comments for a post grouped into threads (oldest first).
+// Comments hidden by moderators, by suspended users, or by users who have
+// blocked (or been blocked by) the viewer are left out, along with any replies
+// under them.
+func GetCommentThreadsForPost(db *sql.DB, postID, viewerID int) ([]CommentThread, error) {
+	rows, err := db.Query(
+		`SELECT `+commentColumns+`
+		 FROM comments c
+		 WHERE c.post_id=$1
+		   AND `+commentVisibleTo("c", "$2")+`
+		 ORDER BY c.created_at ASC, c.id ASC`,
+		postID, viewerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	threads := []CommentThread{}
+	index := make(map[int]int) // top-level comment ID -> position in threads
+	var replies []Comment
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		if c.ParentID == nil {
+			index[c.ID] = len(threads)
+			threads = append(threads, CommentThread{Comment: c, Replies: []Comment{}})
+		} else {
+			replies = append(replies, c)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Replies are always newer than their parent, but attach after the scan so
+	// ordering of the result set never matters
+	for _, reply := range replies {
+		if i, ok := index[*reply.ParentID]; ok {
+			threads[i].Replies = append(threads[i].Replies, reply)
+		}
+	}
+
+	return threads, nil
+}
+
+// commentVisibleTo returns a SQL condition that is true when the comment
+// aliased as alias is shown to the viewer bound to viewerParam: not hidden by
+// a moderator, not by a suspended user, and not by someone the viewer has
+// blocked or been blocked by
+func commentVisibleTo(alias, viewerParam string) string {
+	return alias + `.moderation_state = 'visible'
+		   AND NOT EXISTS (SELECT 1 FROM users su WHERE su.id = ` + alias + `.user_id AND su.suspended_at IS NOT NULL)
+		   AND NOT ` + blockedBetween(alias+".user_id", viewerParam)
+}
+
+// READ: count the comments (including replies) on a post that
+// GetCommentThreadsForPost would show the viewer. Replies under a comment the
+// viewer can't see aren't shown, so they aren't counted either.
+func CountCommentsForPost(db *sql.DB, postID, viewerID int) (int, error) {
+	var count int
+	err := db.QueryRow(
+		`SELECT COUNT(*)
+		 FROM comments c
+		 WHERE c.post_id=$1
+		   AND `+commentVisibleTo("c", "$2")+`
+		   AND (c.parent_id IS NULL OR EXISTS (
+		         SELECT 1 FROM comments pc
+		         WHERE pc.id = c.parent_id AND `+commentVisibleTo("pc", "$2")+`
+		       ))`,
+		postID, viewerID,
+	).Scan(&count)
+	return count, err
+}
+
+// UPDATE: edit a comment's content
+func UpdateComment(db *sql.DB, commentID int, content string) (Comment, error) {
+	return scanComment(db.QueryRow(
+		`UPDATE comments
+		 SET content=$1, updated_at=NOW()
+		 WHERE id=$2
+		 RETURNING `+commentColumns,
+		content, commentID,
+	))
+}
+
+// UPDATE: set a comment's moderation state ('visible' or 'hidden')
+func SetCommentModerationState(db *sql.DB, commentID int, state string) error {
+	_, err := db.Exec(`UPDATE comments SET moderation_state=$1 WHERE id=$2`, state, commentID)
+	return err
+}
+
+// DELETE: remove a comment by ID (replies cascade delete automatically)
+func DeleteComment(db *sql.DB, commentID int) error {
+	_, err := db.Exec(`DELETE FROM comments WHERE id=$1`, commentID)
+	return err
+}
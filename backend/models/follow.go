@@ -0,0 +1,63 @@
+package models
+
+import "database/sql"
+
+// CREATE: follow a user. Returns false if the follow already existed.
+// Nothing is inserted while either user has blocked the other.
+func FollowUser(db *sql.DB, followerID, followedID int) (bool, error) {
+	res, err := db.Exec(
+		`INSERT INTO follows (follower_id, followed_id, created_at)
+		 SELECT $1, $2, NOW()
+		 WHERE NOT `+blockedBetween("$1", "$2")+`
+		 ON CONFLICT DO NOTHING`,
+		followerID, followedID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DELETE: unfollow a user
+func UnfollowUser(db *sql.DB, followerID, followedID int) error {
+	_, err := db.Exec(
+		`DELETE FROM follows WHERE follower_id=$1 AND followed_id=$2`,
+		followerID, followedID,
+	)
+	return err
+}
+
+// READ: check whether followerID follows followedID
+func IsFollowing(db *sql.DB, followerID, followedID int) (bool, error) {
+	var following bool
+	err := db.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM follows WHERE follower_id=$1 AND followed_id=$2)`,
+		followerID, followedID,
+	).Scan(&following)
+	return following, err
+}
+
+// READ: users following userID, newest first
+func GetFollowers(db *sql.DB, userID int) ([]RelatedUser, error) {
+	return queryRelatedUsers(db,
+		`SELECT u.id, COALESCE(u.username, ''), COALESCE(u.display_name, ''), COALESCE(u.picture_url, ''), f.created_at
+		 FROM follows f
+		 JOIN users u ON u.id = f.follower_id
+		 WHERE f.followed_id=$1 AND u.suspended_at IS NULL
+		 ORDER BY f.created_at DESC`,
+		userID,
+	)
+}
+
+// READ: users userID follows, newest first
+func GetFollowing(db *sql.DB, userID int) ([]RelatedUser, error) {
+	return queryRelatedUsers(db,
+		`SELECT u.id, COALESCE(u.username, ''), COALESCE(u.display_name, ''), COALESCE(u.picture_url, ''), f.created_at
+		 FROM follows f
+		 JOIN users u ON u.id = f.followed_id
+		 WHERE f.follower_id=$1 AND u.suspended_at IS NULL
+		 ORDER BY f.created_at DESC`,
+		userID,
+	)
+}
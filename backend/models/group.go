@@ -0,0 +1,332 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// StudyGroup is a group of users who share focus totals and an optional goal
+type StudyGroup struct {
+	ID          int       `json:"id"`
+	OwnerID     int       `json:"owner_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	InviteCode  string    `json:"invite_code"`
+	GoalMinutes *int      `json:"goal_minutes,omitempty"` // e.g. 6000 for "100 hours"
+	GoalPeriod  *string   `json:"goal_period,omitempty"`  // 'day', 'week' or 'month'
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// GroupMember is a member of a study group with their public profile
+type GroupMember struct {
+	UserID        int       `json:"user_id"`
+	Username      string    `json:"username,omitempty"`
+	DisplayName   string    `json:"display_name,omitempty"`
+	PictureURL    string    `json:"picture_url,omitempty"`
+	Role          string    `json:"role"`           // 'owner', 'admin' or 'member'
+	ShareSessions bool      `json:"share_sessions"` // false: only totals are shared
+	JoinedAt      time.Time `json:"joined_at"`
+}
+
+// LeaderboardEntry is a member's focus total for a period
+type LeaderboardEntry struct {
+	Rank         int    `json:"rank"`
+	UserID       int    `json:"user_id"`
+	Username     string `json:"username,omitempty"`
+	DisplayName  string `json:"display_name,omitempty"`
+	PictureURL   string `json:"picture_url,omitempty"`
+	TotalMinutes int    `json:"total_minutes"`
+	SessionCount int    `json:"session_count"`
+}
+
+const groupColumns = `id, owner_id, name, COALESCE(description, ''), invite_code, goal_minutes, goal_period, created_at`
+
+func scanGroup(row rowScanner) (StudyGroup, error) {
+	var g StudyGroup
+	err := row.Scan(&g.ID, &g.OwnerID, &g.Name, &g.Description, &g.InviteCode, &g.GoalMinutes, &g.GoalPeriod, &g.CreatedAt)
+	return g, err
+}
+
+// CREATE: insert a new group; the creator joins as owner
+func CreateGroup(db *sql.DB, ownerID int, name, description, inviteCode string, goalMinutes *int, goalPeriod *string) (StudyGroup, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return StudyGroup{}, err
+	}
+	defer tx.Rollback()
+
+	g, err := scanGroup(tx.QueryRow(
+		`INSERT INTO study_groups (owner_id, name, description, invite_code, goal_minutes, goal_period, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		 RETURNING `+groupColumns,
+		ownerID, name, description, inviteCode, goalMinutes, goalPeriod,
+	))
+	if err != nil {
+		return StudyGroup{}, err
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO group_members (group_id, user_id, role, joined_at)
+		 VALUES ($1, $2, 'owner', NOW())`,
+		g.ID, ownerID,
+	); err != nil {
+		return StudyGroup{}, err
+	}
+
+	return g, tx.Commit()
+}
+
+// READ: get a group by ID
+func GetGroupByID(db *sql.DB, groupID int) (StudyGroup, error) {
+	return scanGroup(db.QueryRow(
+		`SELECT `+groupColumns+`
+		 FROM study_groups
+		 WHERE id=$1`,
+		groupID,
+	))
+}
+
+// READ: get a group by invite code
+func GetGroupByInviteCode(db *sql.DB, code string) (StudyGroup, error) {
+	return scanGroup(db.QueryRow(
+		`SELECT `+groupColumns+`
+		 FROM study_groups
+		 WHERE invite_code=$1`,
+		code,
+	))
+}
+
+// READ: get all groups a user belongs to
+func GetGroupsForUser(db *sql.DB, userID int) ([]StudyGroup, error) {
+	rows, err := db.Query(
+		`SELECT g.id, g.owner_id, g.name, COALESCE(g.description, ''), g.invite_code, g.goal_minutes, g.goal_period, g.created_at
+		 FROM study_groups g
+		 JOIN group_members m ON m.group_id = g.id
+		 WHERE m.user_id=$1
+		 ORDER BY g.name ASC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []StudyGroup{}
+	for rows.Next() {
+		g, err := scanGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// READ: get every member of a group, owner first
+func GetGroupMembers(db *sql.DB, groupID int) ([]GroupMember, error) {
+	rows, err := db.Query(
+		`SELECT u.id, COALESCE(u.username, ''), COALESCE(u.display_name, ''), COALESCE(u.picture_url, ''),
+		        m.role, m.share_sessions, m.joined_at
+		 FROM group_members m
+		 JOIN users u ON u.id = m.user_id
+		 WHERE m.group_id=$1
+		 ORDER BY m.role ASC, m.joined_at ASC`,
+		groupID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []GroupMember{}
+	for rows.Next() {
+		var m GroupMember
+		if err := rows.Scan(&m.UserID, &m.Username, &m.DisplayName, &m.PictureURL, &m.Role, &m.ShareSessions, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// READ: get a single membership. Returns sql.ErrNoRows if the user is not a member.
+func GetGroupMember(db *sql.DB, groupID, userID int) (GroupMember, error) {
+	var m GroupMember
+	err := db.QueryRow(
+		`SELECT u.id, COALESCE(u.username, ''), COALESCE(u.display_name, ''), COALESCE(u.picture_url, ''),
+		        m.role, m.share_sessions, m.joined_at
+		 FROM group_members m
+		 JOIN users u ON u.id = m.user_id
+		 WHERE m.group_id=$1 AND m.user_id=$2`,
+		groupID, userID,
+	).Scan(&m.UserID, &m.Username, &m.DisplayName, &m.PictureURL, &m.Role, &m.ShareSessions, &m.JoinedAt)
+	return m, err
+}
+
+// READ: get the IDs of all groups a user belongs to that have a goal
+func GetGroupIDsWithGoalsForUser(db *sql.DB, userID int) ([]int, error) {
+	rows, err := db.Query(
+		`SELECT g.id
+		 FROM study_groups g
+		 JOIN group_members m ON m.group_id = g.id
+		 WHERE m.user_id=$1 AND g.goal_minutes IS NOT NULL`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// CREATE: add a member (no-op if already a member)
+func AddGroupMember(db *sql.DB, groupID, userID int) error {
+	_, err := db.Exec(
+		`INSERT INTO group_members (group_id, user_id, role, joined_at)
+		 VALUES ($1, $2, 'member', NOW())
+		 ON CONFLICT DO NOTHING`,
+		groupID, userID,
+	)
+	return err
+}
+
+// UPDATE: change a group's name, description and goal
+func UpdateGroup(db *sql.DB, groupID int, name, description string, goalMinutes *int, goalPeriod *string) error {
+	_, err := db.Exec(
+		`UPDATE study_groups
+		 SET name=$1, description=$2, goal_minutes=$3, goal_period=$4
+		 WHERE id=$5`,
+		name, description, goalMinutes, goalPeriod, groupID,
+	)
+	return err
+}
+
+// UPDATE: replace a group's invite code (old codes stop working)
+func UpdateGroupInviteCode(db *sql.DB, groupID int, code string) error {
+	_, err := db.Exec(
+		`UPDATE study_groups SET invite_code=$1 WHERE id=$2`,
+		code, groupID,
+	)
+	return err
+}
+
+// UPDATE: change a member's role
+func UpdateGroupMemberRole(db *sql.DB, groupID, userID int, role string) error {
+	_, err := db.Exec(
+		`UPDATE group_members SET role=$1 WHERE group_id=$2 AND user_id=$3`,
+		role, groupID, userID,
+	)
+	return err
+}
+
+// UPDATE: choose whether a member's individual sessions are visible to the group
+func UpdateGroupMemberSharing(db *sql.DB, groupID, userID int, shareSessions bool) error {
+	_, err := db.Exec(
+		`UPDATE group_members SET share_sessions=$1 WHERE group_id=$2 AND user_id=$3`,
+		shareSessions, groupID, userID,
+	)
+	return err
+}
+
+// DELETE: remove a member from a group
+func RemoveGroupMember(db *sql.DB, groupID, userID int) error {
+	_, err := db.Exec(
+		`DELETE FROM group_members WHERE group_id=$1 AND user_id=$2`,
+		groupID, userID,
+	)
+	return err
+}
+
+// DELETE: remove a group
+func DeleteGroup(db *sql.DB, groupID int) error {
+	_, err := db.Exec(`DELETE FROM study_groups WHERE id=$1`, groupID)
+	return err
+}
+
+// PeriodStart returns the start of the current day, week (Monday) or month in UTC
+func PeriodStart(period string, now time.Time) time.Time {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case "week":
+		offset := (int(day.Weekday()) + 6) % 7 // days since Monday
+		return day.AddDate(0, 0, -offset)
+	case "month":
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// READ: rank a group's members by focus minutes since a point in time.
+// Sessions are attributed to the period in which they started.
+func GetGroupLeaderboard(db *sql.DB, groupID int, since time.Time) ([]LeaderboardEntry, error) {
+	rows, err := db.Query(
+		`SELECT u.id, COALESCE(u.username, ''), COALESCE(u.display_name, ''), COALESCE(u.picture_url, ''),
+		        COALESCE(SUM(fs.duration_minutes), 0), COUNT(fs.id)
+		 FROM group_members m
+		 JOIN users u ON u.id = m.user_id
+		 LEFT JOIN focus_sessions fs ON fs.user_id = m.user_id AND fs.start_time >= $2
+		 WHERE m.group_id=$1
+		 GROUP BY u.id
+		 ORDER BY 5 DESC, u.id ASC`,
+		groupID, since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []LeaderboardEntry{}
+	for rows.Next() {
+		var e LeaderboardEntry
+		if err := rows.Scan(&e.UserID, &e.Username, &e.DisplayName, &e.PictureURL, &e.TotalMinutes, &e.SessionCount); err != nil {
+			return nil, err
+		}
+		// Members with equal totals share a rank
+		e.Rank = len(entries) + 1
+		if n := len(entries); n > 0 && entries[n-1].TotalMinutes == e.TotalMinutes {
+			e.Rank = entries[n-1].Rank
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// READ: total focus minutes of all members since a point in time
+func GetGroupTotalMinutes(db *sql.DB, groupID int, since time.Time) (int, error) {
+	var total int
+	err := db.QueryRow(
+		`SELECT COALESCE(SUM(fs.duration_minutes), 0)
+		 FROM group_members m
+		 JOIN focus_sessions fs ON fs.user_id = m.user_id
+		 WHERE m.group_id=$1 AND fs.start_time >= $2`,
+		groupID, since,
+	).Scan(&total)
+	return total, err
+}
+
+// CREATE: record that a group reached its goal for a period. Returns false if
+// it was already recorded, so completion is only announced once per period.
+func RecordGroupGoalCompletion(db *sql.DB, groupID int, periodStart time.Time) (bool, error) {
+	res, err := db.Exec(
+		`INSERT INTO group_goal_completions (group_id, period_start, completed_at)
+		 VALUES ($1, $2, NOW())
+		 ON CONFLICT DO NOTHING`,
+		groupID, periodStart,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RecentInsightAnswers is how many of the latest text answers insights include
+const RecentInsightAnswers = 5
+
+// InsightWeek aggregates one question's answers over a calendar week (UTC)
+type InsightWeek struct {
+	WeekStart time.Time `json:"week_start"`
+	Answered  int       `json:"answered"`
+	Average   *float64  `json:"average,omitempty"`   // scale questions
+	YesShare  *float64  `json:"yes_share,omitempty"` // yes_no questions, 0-1
+}
+
+// RecentAnswer is a text answer with the post it came from
+type RecentAnswer struct {
+	PostID    int       `json:"post_id"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// QuestionInsight summarizes a user's answers to one template question
+type QuestionInsight struct {
+	QuestionID   int            `json:"question_id"`
+	Prompt       string         `json:"prompt"`
+	Type         string         `json:"type"`
+	Answered     int            `json:"answered"`
+	Average      *float64       `json:"average,omitempty"`      // scale
+	Distribution map[int]int    `json:"distribution,omitempty"` // scale: answer -> count
+	Yes          int            `json:"yes,omitempty"`          // yes_no
+	No           int            `json:"no,omitempty"`           // yes_no
+	Recent       []RecentAnswer `json:"recent,omitempty"`       // text, newest first
+	Weekly       []InsightWeek  `json:"weekly"`
+}
+
+// READ: aggregate a user's answers to a template's questions, optionally within
+// [from, to). Only published posts count.
+func GetTemplateInsights(db *sql.DB, userID int, t ReflectionTemplate, from, to *time.Time) ([]QuestionInsight, error) {
+	insights := make([]QuestionInsight, len(t.Questions))
+	index := make(map[int]int, len(t.Questions))
+	ids := make([]int64, len(t.Questions))
+	for i, q := range t.Questions {
+		insights[i] = QuestionInsight{QuestionID: q.ID, Prompt: q.Prompt, Type: q.Type, Weekly: []InsightWeek{}}
+		if q.Type == "scale" {
+			insights[i].Distribution = map[int]int{}
+		}
+		index[q.ID] = i
+		ids[i] = int64(q.ID)
+	}
+
+	// Shared by every query below: $1 user, $2 question IDs, $3/$4 date range
+	const answersInRange = `
+		 FROM post_answers a
+		 JOIN posts p ON p.id = a.post_id
+		 WHERE p.user_id = $1
+		   AND p.status = 'published'
+		   AND a.question_id = ANY($2)
+		   AND ($3::timestamptz IS NULL OR p.created_at >= $3)
+		   AND ($4::timestamptz IS NULL OR p.created_at < $4)`
+	args := []interface{}{userID, pq.Array(ids), from, to}
+
+	// Weekly counts; totals are summed from these
+	rows, err := db.Query(
+		`SELECT a.question_id, date_trunc('week', p.created_at) AS week, COUNT(*),
+		        COALESCE(SUM(a.scale_answer), 0), COUNT(*) FILTER (WHERE a.yes_no_answer)`+
+			answersInRange+`
+		 GROUP BY a.question_id, week
+		 ORDER BY week ASC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	scaleSums := make([]int, len(insights))
+	for rows.Next() {
+		var questionID, count, scaleSum, yes int
+		var week time.Time
+		if err := rows.Scan(&questionID, &week, &count, &scaleSum, &yes); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		i := index[questionID]
+		point := InsightWeek{WeekStart: week, Answered: count}
+		switch insights[i].Type {
+		case "scale":
+			avg := float64(scaleSum) / float64(count)
+			point.Average = &avg
+			scaleSums[i] += scaleSum
+		case "yes_no":
+			share := float64(yes) / float64(count)
+			point.YesShare = &share
+			insights[i].Yes += yes
+			insights[i].No += count - yes
+		}
+		insights[i].Answered += count
+		insights[i].Weekly = append(insights[i].Weekly, point)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range insights {
+		if insights[i].Type == "scale" && insights[i].Answered > 0 {
+			avg := float64(scaleSums[i]) / float64(insights[i].Answered)
+			insights[i].Average = &avg
+		}
+	}
+
+	// Spread of scale answers
+	rows, err = db.Query(
+		`SELECT a.question_id, a.scale_answer, COUNT(*)`+answersInRange+`
+		   AND a.scale_answer IS NOT NULL
+		 GROUP BY a.question_id, a.scale_answer`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var questionID, answer, count int
+		if err := rows.Scan(&questionID, &answer, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		insights[index[questionID]].Distribution[answer] = count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Latest text answers per question
+	rows, err = db.Query(
+		`SELECT question_id, post_id, text_answer, created_at
+		 FROM (
+		     SELECT a.question_id, p.id AS post_id, a.text_answer, p.created_at,
+		            ROW_NUMBER() OVER (PARTITION BY a.question_id ORDER BY p.created_at DESC) AS n`+
+			answersInRange+`
+		       AND a.text_answer IS NOT NULL
+		 ) recent
+		 WHERE n <= $5
+		 ORDER BY created_at DESC`,
+		append(args, RecentInsightAnswers)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var questionID int
+		var a RecentAnswer
+		if err := rows.Scan(&questionID, &a.PostID, &a.Text, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		i := index[questionID]
+		insights[i].Recent = append(insights[i].Recent, a)
+	}
+	return insights, rows.Err()
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+	_ "time/tzdata" // users pick any IANA timezone, even where the host has no tz database
+
+	"github.com/lib/pq"
+)
+
+// JournalDateLayout is the format of journal dates, e.g. 2025-03-14
+const JournalDateLayout = "2006-01-02"
+
+// JournalSession is a focus session with the reflections written about it
+type JournalSession struct {
+	FocusSession
+	Posts []PostWithDetails `json:"posts"`
+}
+
+// JournalDay pairs a day's journal entry with the focus sessions started that day
+type JournalDay struct {
+	Date         string           `json:"date"`
+	Entry        *PostWithDetails `json:"entry"` // nil if nothing was journaled
+	Sessions     []JournalSession `json:"sessions"`
+	FocusMinutes int              `json:"focus_minutes"`
+}
+
+// CalendarDate returns the date (JournalDateLayout) that t falls on in timezone
+func CalendarDate(t time.Time, timezone string) (string, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return "", err
+	}
+	return t.In(loc).Format(JournalDateLayout), nil
+}
+
+// READ: get a user's journal entry for a date
+func GetJournalEntry(db *sql.DB, userID int, date string) (Post, error) {
+	return scanPost(db.QueryRow(
+		`SELECT `+postColumns+`
+		 FROM posts
+		 WHERE user_id=$1 AND journal_date=$2::date`,
+		userID, date,
+	))
+}
+
+// READ: get a user's days from `from` to `to` (inclusive dates, newest first),
+// each with its journal entry and the focus sessions started that day in
+// timezone, along with the posts written about those sessions
+func GetJournalDays(db *sql.DB, userID int, timezone string, from, to time.Time) ([]JournalDay, error) {
+	fromDate, toDate := from.Format(JournalDateLayout), to.Format(JournalDateLayout)
+
+	days := []JournalDay{}
+	index := map[string]int{}
+	for d := to; !d.Before(from); d = d.AddDate(0, 0, -1) {
+		date := d.Format(JournalDateLayout)
+		index[date] = len(days)
+		days = append(days, JournalDay{Date: date, Sessions: []JournalSession{}})
+	}
+
+	entries, err := queryPostsWithDetails(db, userID,
+		`SELECT `+postColumns+`
+		 FROM posts
+		 WHERE user_id=$1 AND journal_date BETWEEN $2::date AND $3::date`,
+		userID, fromDate, toDate,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].JournalDate == nil {
+			continue
+		}
+		if d, ok := index[*entries[i].JournalDate]; ok {
+			days[d].Entry = &entries[i]
+		}
+	}
+
+	rows, err := db.Query(
+		`SELECT `+sessionColumns+`, to_char(start_time AT TIME ZONE $2, 'YYYY-MM-DD')
+		 FROM focus_sessions
+		 WHERE user_id=$1 AND (start_time AT TIME ZONE $2)::date BETWEEN $3::date AND $4::date
+		 ORDER BY start_time ASC`,
+		userID, timezone, fromDate, toDate,
+	)
+	if err != nil {
+		return nil, err
+	}
+	// Where each session landed, for attaching its posts below
+	type position struct{ day, session int }
+	positions := map[int]position{}
+	var sessionIDs []int64
+	for rows.Next() {
+		var date string
+		session, err := scanSession(withExtraColumns{rows, []interface{}{&date}})
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d, ok := index[date]
+		if !ok {
+			continue
+		}
+		positions[session.ID] = position{d, len(days[d].Sessions)}
+		sessionIDs = append(sessionIDs, int64(session.ID))
+		days[d].Sessions = append(days[d].Sessions, JournalSession{FocusSession: session, Posts: []PostWithDetails{}})
+		days[d].FocusMinutes += session.DurationMinutes
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(sessionIDs) == 0 {
+		return days, nil
+	}
+	posts, err := queryPostsWithDetails(db, userID,
+		`SELECT `+postColumns+`
+		 FROM posts
+		 WHERE user_id=$1 AND session_id = ANY($2)
+		 ORDER BY created_at ASC`,
+		userID, pq.Array(sessionIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		if post.SessionID == nil {
+			continue
+		}
+		if p, ok := positions[*post.SessionID]; ok {
+			days[p.day].Sessions[p.session].Posts = append(days[p.day].Sessions[p.session].Posts, post)
+		}
+	}
+
+	return days, nil
+}
@@ -7,36 +7,139 @@ import (
 
 // PostMedia represents a media attachment (image/video) on a post
 type PostMedia struct {
-	ID               int       `json:"id"`
-	PostID           int       `json:"post_id"`
-	UserID           int       `json:"user_id"`
-	MediaType        string    `json:"media_type"` // 'image' or 'video'
-	FileURL          string    `json:"file_url"`
-	Position         int       `json:"position"`
-	OriginalFilename string    `json:"original_filename,omitempty"`
-	CreatedAt        time.Time `json:"created_at"`
+	ID               int        `json:"id"`
+	PostID           int        `json:"post_id"`
+	UserID           int        `json:"user_id"`
+	MediaType        string     `json:"media_type"` // 'image' or 'video'
+	FileURL          string     `json:"file_url"`
+	Position         int        `json:"position"`
+	OriginalFilename string     `json:"original_filename,omitempty"`
+	ContentType      string     `json:"content_type,omitempty"` // set for direct uploads
+	SizeBytes        int64      `json:"size_bytes,omitempty"`   // set for direct uploads
+	Status           string     `json:"status"`                 // 'pending' until a direct upload is completed, then 'active'
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`   // when a pending upload is discarded
+	CreatedAt        time.Time  `json:"created_at"`
 }
 
-// CREATE: Add media to a post
-func AddMediaToPost(db *sql.DB, postID, userID int, mediaType, fileURL, originalFilename string, position int) (PostMedia, error) {
+// Columns selected for a PostMedia, in scanMedia order
+const mediaColumns = `id, post_id, user_id, media_type, file_url, position, COALESCE(original_filename, ''),
+	COALESCE(content_type, ''), COALESCE(size_bytes, 0), status, expires_at, created_at`
+
+func scanMedia(row rowScanner) (PostMedia, error) {
 	var media PostMedia
+	err := row.Scan(&media.ID, &media.PostID, &media.UserID, &media.MediaType, &media.FileURL, &media.Position, &media.OriginalFilename,
+		&media.ContentType, &media.SizeBytes, &media.Status, &media.ExpiresAt, &media.CreatedAt)
+	return media, err
+}
 
-	err := db.QueryRow(
-		`INSERT INTO post_media (post_id, user_id, media_type, file_url, original_filename, position, created_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, NOW())
-		 RETURNING id, post_id, user_id, media_type, file_url, position, original_filename, created_at`,
-		postID, userID, mediaType, fileURL, originalFilename, position,
-	).Scan(&media.ID, &media.PostID, &media.UserID, &media.MediaType, &media.FileURL, &media.Position, &media.OriginalFilename, &media.CreatedAt)
+// uploadContentTypes are the content types accepted for direct uploads, with
+// their media type and the file extension used for the object key
+var uploadContentTypes = map[string]struct{ mediaType, ext string }{
+	"image/jpeg":      {"image", ".jpg"},
+	"image/png":       {"image", ".png"},
+	"image/gif":       {"image", ".gif"},
+	"image/webp":      {"image", ".webp"},
+	"video/mp4":       {"video", ".mp4"},
+	"video/quicktime": {"video", ".mov"},
+	"video/x-msvideo": {"video", ".avi"},
+	"video/webm":      {"video", ".webm"},
+}
 
-	return media, err
+// UploadMediaType returns the media type ('image' or 'video') and file
+// extension for a direct upload's content type, or ok=false if it isn't allowed
+func UploadMediaType(contentType string) (mediaType, ext string, ok bool) {
+	t, found := uploadContentTypes[contentType]
+	return t.mediaType, t.ext, found
+}
+
+// CREATE: attach a stored file to a post. obj is either an existing object
+// (with an ID) or a file just stored under obj.StorageKey. If the user already
+// has an object with the same contents, that one is reused; the storage keys
+// of files made redundant (obj's own file, in that case) are returned for
+// deletion. fileURL gives the public URL of a stored file. Returns
+// sql.ErrNoRows if an existing obj has been deleted since it was looked up.
+func AddMediaToPost(db *sql.DB, media PostMedia, obj MediaObject, fileURL func(key string) string) (PostMedia, []string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return PostMedia{}, nil, err
+	}
+	defer tx.Rollback()
+
+	var stored MediaObject
+	if obj.ID != 0 {
+		stored, err = retainMediaObject(tx, obj.ID)
+	} else {
+		stored, err = storeMediaObject(tx, obj)
+	}
+	if err != nil {
+		return PostMedia{}, nil, err
+	}
+
+	added, err := scanMedia(tx.QueryRow(
+		`INSERT INTO post_media (post_id, user_id, media_type, file_url, original_filename, content_type, size_bytes,
+		                         position, object_id, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		 RETURNING `+mediaColumns,
+		media.PostID, media.UserID, media.MediaType, fileURL(stored.StorageKey), media.OriginalFilename,
+		stored.ContentType, stored.SizeBytes, media.Position, stored.ID,
+	))
+	if err != nil {
+		return PostMedia{}, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return PostMedia{}, nil, err
+	}
+
+	var unused []string
+	if stored.StorageKey != obj.StorageKey {
+		unused = append(unused, obj.StorageKey)
+	}
+	return added, unused, nil
+}
+
+// CREATE: reserve a slot for a direct upload to storageKey. The row stays
+// 'pending' (and hidden from the post) until CompleteMedia, and is discarded
+// after expiresAt. fileURL gives the public URL of a stored file.
+func CreatePendingMedia(db *sql.DB, media PostMedia, storageKey string, expiresAt time.Time, fileURL func(key string) string) (PostMedia, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return PostMedia{}, err
+	}
+	defer tx.Rollback()
+
+	// The object gets its hash once the upload is verified
+	var objectID int
+	if err := tx.QueryRow(
+		`INSERT INTO media_objects (user_id, storage_key, size_bytes, content_type, ref_count, created_at)
+		 VALUES ($1, $2, $3, $4, 1, NOW())
+		 RETURNING id`,
+		media.UserID, storageKey, media.SizeBytes, media.ContentType,
+	).Scan(&objectID); err != nil {
+		return PostMedia{}, err
+	}
+
+	pending, err := scanMedia(tx.QueryRow(
+		`INSERT INTO post_media (post_id, user_id, media_type, file_url, original_filename, content_type, size_bytes,
+		                         position, object_id, status, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 'pending', $10, NOW())
+		 RETURNING `+mediaColumns,
+		media.PostID, media.UserID, media.MediaType, fileURL(storageKey), media.OriginalFilename, media.ContentType,
+		media.SizeBytes, media.Position, objectID, expiresAt,
+	))
+	if err != nil {
+		return PostMedia{}, err
+	}
+
+	return pending, tx.Commit()
 }
 
-// READ: Get all media for a post (ordered by position)
+// READ: Get all media for a post (ordered by position), excluding media hidden by moderators
 func GetMediaForPost(db *sql.DB, postID int) ([]PostMedia, error) {
 	rows, err := db.Query(
-		`SELECT id, post_id, user_id, media_type, file_url, position, original_filename, created_at
+		`SELECT `+mediaColumns+`
 		 FROM post_media
-		 WHERE post_id=$1
+		 WHERE post_id=$1 AND status = 'active' AND moderation_state = 'visible'
 		 ORDER BY position ASC`,
 		postID,
 	)
@@ -47,8 +150,8 @@ func GetMediaForPost(db *sql.DB, postID int) ([]PostMedia, error) {
 
 	var mediaList []PostMedia
 	for rows.Next() {
-		var media PostMedia
-		if err := rows.Scan(&media.ID, &media.PostID, &media.UserID, &media.MediaType, &media.FileURL, &media.Position, &media.OriginalFilename, &media.CreatedAt); err != nil {
+		media, err := scanMedia(rows)
+		if err != nil {
 			return nil, err
 		}
 		mediaList = append(mediaList, media)
@@ -57,36 +160,139 @@ func GetMediaForPost(db *sql.DB, postID int) ([]PostMedia, error) {
 	return mediaList, rows.Err()
 }
 
-// READ: Count media items for a post
+// READ: Count media items for a post, including uploads still pending
 func CountMediaForPost(db *sql.DB, postID int) (int, error) {
 	var count int
 	err := db.QueryRow(
-		`SELECT COUNT(*) FROM post_media WHERE post_id=$1`,
+		`SELECT COUNT(*) FROM post_media WHERE post_id=$1 AND (status = 'active' OR expires_at > NOW())`,
 		postID,
 	).Scan(&count)
 	return count, err
 }
 
-// DELETE: Remove media by ID
-func DeleteMedia(db *sql.DB, mediaID int) error {
-	_, err := db.Exec(`DELETE FROM post_media WHERE id=$1`, mediaID)
-	return err
+// UPDATE: activate a pending upload whose file has been verified, hashed and
+// copied to storageKey, away from the key the client could upload to. If the
+// user already has an object with the same contents the media switches to it
+// instead. The storage keys of files made redundant (always including the
+// upload key) are returned for deletion. fileURL gives the public URL of a
+// stored file. Returns sql.ErrNoRows if it isn't pending or has already expired.
+func CompleteMedia(db *sql.DB, mediaID int, sha256, storageKey string, fileURL func(key string) string) (PostMedia, []string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return PostMedia{}, nil, err
+	}
+	defer tx.Rollback()
+
+	var userID, objectID int
+	var uploadKey string
+	if err := tx.QueryRow(
+		`SELECT m.user_id, m.object_id, o.storage_key
+		 FROM post_media m
+		 JOIN media_objects o ON o.id = m.object_id
+		 WHERE m.id=$1 AND m.status='pending' AND m.expires_at > NOW()
+		 FOR UPDATE OF m`,
+		mediaID,
+	).Scan(&userID, &objectID, &uploadKey); err != nil {
+		return PostMedia{}, nil, err
+	}
+
+	var unused []string
+	existing, err := scanMediaObject(tx.QueryRow(
+		`UPDATE media_objects SET ref_count = ref_count + 1
+		 WHERE user_id=$1 AND sha256=$2 AND ref_count > 0
+		 RETURNING `+mediaObjectColumns,
+		userID, sha256,
+	))
+	switch {
+	case err == sql.ErrNoRows:
+		if _, err := tx.Exec(
+			`UPDATE media_objects SET sha256=$1, storage_key=$2 WHERE id=$3`,
+			sha256, storageKey, objectID,
+		); err != nil {
+			return PostMedia{}, nil, err
+		}
+		if _, err := tx.Exec(`UPDATE post_media SET file_url=$1 WHERE id=$2`, fileURL(storageKey), mediaID); err != nil {
+			return PostMedia{}, nil, err
+		}
+		unused = []string{uploadKey}
+
+	case err != nil:
+		return PostMedia{}, nil, err
+
+	default:
+		// Same file uploaded before: share that object and drop both new copies
+		if _, err := tx.Exec(
+			`UPDATE post_media SET object_id=$1, file_url=$2 WHERE id=$3`,
+			existing.ID, fileURL(existing.StorageKey), mediaID,
+		); err != nil {
+			return PostMedia{}, nil, err
+		}
+		if unused, err = releaseMediaObjects(tx, []int{objectID}); err != nil {
+			return PostMedia{}, nil, err
+		}
+		unused = append(unused, storageKey)
+	}
+
+	media, err := scanMedia(tx.QueryRow(
+		`UPDATE post_media SET status='active', expires_at=NULL
+		 WHERE id=$1
+		 RETURNING `+mediaColumns,
+		mediaID,
+	))
+	if err != nil {
+		return PostMedia{}, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return PostMedia{}, nil, err
+	}
+	return media, unused, nil
 }
 
-// DELETE: Remove all media for a post (called when post is deleted)
-func DeleteMediaForPost(db *sql.DB, postID int) error {
-	_, err := db.Exec(`DELETE FROM post_media WHERE post_id=$1`, postID)
+// UPDATE: set a media item's moderation state ('visible' or 'hidden')
+func SetMediaModerationState(db *sql.DB, mediaID int, state string) error {
+	_, err := db.Exec(`UPDATE post_media SET moderation_state=$1 WHERE id=$2`, state, mediaID)
 	return err
 }
 
+// DELETE: Remove media by ID, returning the storage keys of files no longer
+// used by any media
+func DeleteMedia(db *sql.DB, mediaID int) ([]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	unused, err := deleteMediaWhere(tx, `id=$1`, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	return unused, tx.Commit()
+}
+
+// DELETE: discard pending uploads past their expiry, returning the storage
+// keys of their files so anything uploaded can be removed
+func DeleteExpiredPendingMedia(db *sql.DB) ([]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	unused, err := deleteMediaWhere(tx, `status='pending' AND expires_at <= NOW()`)
+	if err != nil {
+		return nil, err
+	}
+	return unused, tx.Commit()
+}
+
 // Helper: Get media by ID
 func GetMediaByID(db *sql.DB, mediaID int) (PostMedia, error) {
-	var media PostMedia
-	err := db.QueryRow(
-		`SELECT id, post_id, user_id, media_type, file_url, position, original_filename, created_at
+	return scanMedia(db.QueryRow(
+		`SELECT `+mediaColumns+`
 		 FROM post_media
 		 WHERE id=$1`,
 		mediaID,
-	).Scan(&media.ID, &media.PostID, &media.UserID, &media.MediaType, &media.FileURL, &media.Position, &media.OriginalFilename, &media.CreatedAt)
-	return media, err
+	))
 }
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MediaObject is a file in media storage. Identical uploads by one user share
+// an object; RefCount counts the media items using it.
+type MediaObject struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	StorageKey  string    `json:"storage_key"`
+	SHA256      string    `json:"sha256,omitempty"` // empty until a direct upload is completed
+	SizeBytes   int64     `json:"size_bytes"`
+	ContentType string    `json:"content_type"`
+	RefCount    int       `json:"ref_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Columns selected for a MediaObject, in scanMediaObject order
+const mediaObjectColumns = `id, user_id, storage_key, COALESCE(sha256, ''), size_bytes, content_type, ref_count, created_at`
+
+func scanMediaObject(row rowScanner) (MediaObject, error) {
+	var obj MediaObject
+	err := row.Scan(&obj.ID, &obj.UserID, &obj.StorageKey, &obj.SHA256, &obj.SizeBytes, &obj.ContentType, &obj.RefCount, &obj.CreatedAt)
+	return obj, err
+}
+
+// READ: a user's object with the given contents, if they uploaded it before
+func GetMediaObjectByHash(db *sql.DB, userID int, sha256 string) (MediaObject, error) {
+	return scanMediaObject(db.QueryRow(
+		`SELECT `+mediaObjectColumns+` FROM media_objects WHERE user_id=$1 AND sha256=$2`,
+		userID, sha256,
+	))
+}
+
+// READ: the object stored under key
+func GetMediaObjectByKey(db *sql.DB, key string) (MediaObject, error) {
+	return scanMediaObject(db.QueryRow(
+		`SELECT `+mediaObjectColumns+` FROM media_objects WHERE storage_key=$1`,
+		key,
+	))
+}
+
+// retainMediaObject records another reference to an existing object. The
+// count is bumped in place, so an object released meanwhile can't be brought
+// back after its file was deleted; sql.ErrNoRows means it is gone.
+func retainMediaObject(db DBTX, objectID int) (MediaObject, error) {
+	return scanMediaObject(db.QueryRow(
+		`UPDATE media_objects SET ref_count = ref_count + 1
+		 WHERE id=$1 AND ref_count > 0
+		 RETURNING `+mediaObjectColumns,
+		objectID,
+	))
+}
+
+// storeMediaObject records a reference to a newly stored file, creating its
+// object unless the user already has one with the same contents. The returned
+// object may be stored under a different key than obj.
+func storeMediaObject(db DBTX, obj MediaObject) (MediaObject, error) {
+	return scanMediaObject(db.QueryRow(
+		`INSERT INTO media_objects (user_id, storage_key, sha256, size_bytes, content_type, ref_count, created_at)
+		 VALUES ($1, $2, $3, $4, $5, 1, NOW())
+		 ON CONFLICT (user_id, sha256) DO UPDATE SET ref_count = media_objects.ref_count + 1
+		 RETURNING `+mediaObjectColumns,
+		obj.UserID, obj.StorageKey, obj.SHA256, obj.SizeBytes, obj.ContentType,
+	))
+}
+
+// releaseMediaObjects drops one reference per ID (IDs may repeat) and deletes
+// objects nobody uses any more, returning their storage keys. The referencing
+// post_media rows must already be gone.
+func releaseMediaObjects(db DBTX, objectIDs []int) ([]string, error) {
+	if len(objectIDs) == 0 {
+		return nil, nil
+	}
+
+	if _, err := db.Exec(
+		`UPDATE media_objects o SET ref_count = o.ref_count - r.refs
+		 FROM (SELECT id, COUNT(*) AS refs FROM unnest($1::int[]) AS id GROUP BY id) r
+		 WHERE o.id = r.id`,
+		pq.Array(objectIDs),
+	); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(
+		`DELETE FROM media_objects WHERE id = ANY($1::int[]) AND ref_count = 0
+		 RETURNING storage_key`,
+		pq.Array(objectIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// deleteMediaWhere deletes the post_media rows matching cond and releases
+// their objects, returning the storage keys of files that are no longer used
+func deleteMediaWhere(db DBTX, cond string, args ...interface{}) ([]string, error) {
+	rows, err := db.Query(`DELETE FROM post_media WHERE `+cond+` RETURNING object_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var objectIDs []int
+	for rows.Next() {
+		var id sql.NullInt64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		// Media from before objects were tracked keeps its file
+		if id.Valid {
+			objectIDs = append(objectIDs, int(id.Int64))
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return releaseMediaObjects(db, objectIDs)
+}
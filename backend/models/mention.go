@@ -0,0 +1,178 @@
+package models
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"tomo/backend/utils"
+)
+
+var (
+	// #hashtag must start the text or follow whitespace or an opening
+	// bracket/quote, like @mentions (see utils.MentionPattern), so #anchors
+	// in links don't match
+	hashtagPattern = regexp.MustCompile(`(?:^|[\s\[{"'])#([\p{L}\p{N}_-]+)`)
+
+	digitsPattern = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// Mention is a user mentioned in a post
+type Mention struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// ParseMentions returns the usernames @mentioned in Markdown content, in order
+// of first appearance and without duplicates
+func ParseMentions(content string) []string {
+	content = utils.CodePattern.ReplaceAllString(content, " ")
+
+	seen := map[string]bool{}
+	var usernames []string
+	for _, m := range utils.MentionPattern.FindAllStringSubmatch(content, -1) {
+		// "@alice." at the end of a sentence mentions alice
+		name := strings.TrimRight(m[1], ".")
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		usernames = append(usernames, name)
+	}
+	return usernames
+}
+
+// ParseHashtags returns the normalized #hashtags in Markdown content, in order
+// of first appearance and without duplicates. Hashtags that aren't valid tag
+// names (too long, or only digits like "#1") are skipped.
+func ParseHashtags(content string) []string {
+	content = utils.CodePattern.ReplaceAllString(content, " ")
+
+	seen := map[string]bool{}
+	var tags []string
+	for _, m := range hashtagPattern.FindAllStringSubmatch(content, -1) {
+		raw := strings.TrimRight(m[1], "-_")
+		if digitsPattern.MatchString(raw) {
+			continue
+		}
+		tag, err := NormalizeTag(raw)
+		if err != nil || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// UPDATE: make a post's mentions match usernames. Unknown usernames and the
+// author are ignored. Mentions that remain keep their notified state.
+func SetPostMentions(db *sql.DB, postID, authorID int, usernames []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`DELETE FROM post_mentions pm
+		 WHERE pm.post_id=$1
+		   AND NOT EXISTS (SELECT 1 FROM users u WHERE u.id = pm.user_id AND u.username = ANY($2))`,
+		postID, pq.Array(usernames),
+	); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO post_mentions (post_id, user_id)
+		 SELECT $1, u.id FROM users u
+		 WHERE u.username = ANY($2) AND u.id <> $3
+		 ON CONFLICT DO NOTHING`,
+		postID, pq.Array(usernames), authorID,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// READ: get the users mentioned in a post that viewerID may see, leaving out
+// suspended users and users the viewer has blocked or been blocked by
+func GetMentionsForPost(db *sql.DB, postID, viewerID int) ([]Mention, error) {
+	mentions, err := GetMentionsForPosts(db, []int{postID}, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if mentions[postID] == nil {
+		return []Mention{}, nil
+	}
+	return mentions[postID], nil
+}
+
+// READ: GetMentionsForPost for several posts in one query, keyed by post ID.
+// Posts without visible mentions are left out of the map.
+func GetMentionsForPosts(db *sql.DB, postIDs []int, viewerID int) (map[int][]Mention, error) {
+	rows, err := db.Query(
+		`SELECT pm.post_id, u.id, u.username
+		 FROM post_mentions pm
+		 JOIN users u ON u.id = pm.user_id
+		 WHERE pm.post_id = ANY($1)
+		   AND (u.id = $2 OR u.suspended_at IS NULL)
+		   AND NOT `+blockedBetween("u.id", "$2")+`
+		 ORDER BY u.username ASC`,
+		pq.Array(postIDs), viewerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := map[int][]Mention{}
+	for rows.Next() {
+		var postID int
+		var m Mention
+		if err := rows.Scan(&postID, &m.UserID, &m.Username); err != nil {
+			return nil, err
+		}
+		mentions[postID] = append(mentions[postID], m)
+	}
+	return mentions, rows.Err()
+}
+
+// READ: get the users mentioned in a post who haven't been notified yet
+func GetUnnotifiedMentions(db *sql.DB, postID int) ([]int, error) {
+	rows, err := db.Query(
+		`SELECT user_id FROM post_mentions WHERE post_id=$1 AND notified_at IS NULL`,
+		postID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, id)
+	}
+	return userIDs, rows.Err()
+}
+
+// UPDATE: mark a mention as notified. Returns false if it already was (or no
+// longer exists), so concurrent edits notify each user at most once.
+func ClaimMentionNotification(db *sql.DB, postID, userID int) (bool, error) {
+	res, err := db.Exec(
+		`UPDATE post_mentions SET notified_at=NOW() WHERE post_id=$1 AND user_id=$2 AND notified_at IS NULL`,
+		postID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
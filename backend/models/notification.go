@@ -0,0 +1,249 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// NotificationTypes lists every notification_type, in display order
+var NotificationTypes = []string{"follow", "reaction", "comment", "reply", "goal_completed", "mention"}
+
+// Notification is a single stored notification for a recipient
+type Notification struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`            // recipient
+	ActorID   *int       `json:"actor_id,omitempty"` // NULL for system notifications
+	Type      string     `json:"type"`
+	PostID    *int       `json:"post_id,omitempty"`
+	CommentID *int       `json:"comment_id,omitempty"`
+	GroupKey  string     `json:"group_key"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NotificationActor is the public profile of a user shown on a notification
+type NotificationActor struct {
+	ID          int    `json:"id"`
+	Username    string `json:"username,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	PictureURL  string `json:"picture_url,omitempty"`
+}
+
+// NotificationGroup collapses notifications that share a group key and read
+// state into one entry ("3 people commented on your post")
+type NotificationGroup struct {
+	ID         int                 `json:"id"` // newest notification in the group
+	Type       string              `json:"type"`
+	GroupKey   string              `json:"group_key"`
+	PostID     *int                `json:"post_id,omitempty"`
+	CommentID  *int                `json:"comment_id,omitempty"`
+	Count      int                 `json:"count"`
+	ActorCount int                 `json:"actor_count"`
+	Actors     []NotificationActor `json:"actors"` // up to 3 most recent
+	Unread     bool                `json:"unread"`
+	LatestAt   time.Time           `json:"latest_at"`
+}
+
+// CREATE: store a notification
+func CreateNotification(db *sql.DB, userID int, actorID *int, notifType string, postID, commentID *int, groupKey string) (Notification, error) {
+	var n Notification
+	err := db.QueryRow(
+		`INSERT INTO notifications (user_id, actor_id, type, post_id, comment_id, group_key, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		 RETURNING id, user_id, actor_id, type, post_id, comment_id, group_key, read_at, created_at`,
+		userID, actorID, notifType, postID, commentID, groupKey,
+	).Scan(&n.ID, &n.UserID, &n.ActorID, &n.Type, &n.PostID, &n.CommentID, &n.GroupKey, &n.ReadAt, &n.CreatedAt)
+	return n, err
+}
+
+// READ: get grouped notifications for a user, newest group first
+func GetNotificationGroups(db *sql.DB, userID int, limit, offset int) ([]NotificationGroup, error) {
+	rows, err := db.Query(
+		`SELECT MAX(id), type, group_key,
+		        (array_agg(post_id ORDER BY created_at DESC))[1],
+		        (array_agg(comment_id ORDER BY created_at DESC))[1],
+		        COUNT(*), COUNT(DISTINCT actor_id),
+		        array_remove(array_agg(actor_id ORDER BY created_at DESC), NULL),
+		        read_at IS NULL, MAX(created_at)
+		 FROM notifications
+		 WHERE user_id=$1
+		 GROUP BY type, group_key, read_at IS NULL
+		 ORDER BY MAX(created_at) DESC
+		 LIMIT $2 OFFSET $3`,
+		userID, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []NotificationGroup{}
+	var actorIDs [][]int64
+	for rows.Next() {
+		var g NotificationGroup
+		var actors pq.Int64Array
+		if err := rows.Scan(&g.ID, &g.Type, &g.GroupKey, &g.PostID, &g.CommentID, &g.Count, &g.ActorCount, &actors, &g.Unread, &g.LatestAt); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+		actorIDs = append(actorIDs, distinctFirst(actors, 3))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range groups {
+		actors, err := getNotificationActors(db, actorIDs[i])
+		if err != nil {
+			return nil, err
+		}
+		groups[i].Actors = actors
+	}
+
+	return groups, nil
+}
+
+// distinctFirst returns the first n distinct values of ids, preserving order
+func distinctFirst(ids []int64, n int) []int64 {
+	seen := make(map[int64]bool)
+	out := make([]int64, 0, n)
+	for _, id := range ids {
+		if len(out) == n {
+			break
+		}
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// Helper: load public profiles for actors, preserving the order of ids
+func getNotificationActors(db *sql.DB, ids []int64) ([]NotificationActor, error) {
+	actors := []NotificationActor{}
+	if len(ids) == 0 {
+		return actors, nil
+	}
+
+	rows, err := db.Query(
+		`SELECT id, COALESCE(username, ''), COALESCE(display_name, ''), COALESCE(picture_url, '')
+		 FROM users
+		 WHERE id = ANY($1)
+		 ORDER BY array_position($1, id::bigint)`,
+		pq.Int64Array(ids),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a NotificationActor
+		if err := rows.Scan(&a.ID, &a.Username, &a.DisplayName, &a.PictureURL); err != nil {
+			return nil, err
+		}
+		actors = append(actors, a)
+	}
+	return actors, rows.Err()
+}
+
+// READ: get a notification by ID
+func GetNotificationByID(db *sql.DB, notificationID int) (Notification, error) {
+	var n Notification
+	err := db.QueryRow(
+		`SELECT id, user_id, actor_id, type, post_id, comment_id, group_key, read_at, created_at
+		 FROM notifications
+		 WHERE id=$1`,
+		notificationID,
+	).Scan(&n.ID, &n.UserID, &n.ActorID, &n.Type, &n.PostID, &n.CommentID, &n.GroupKey, &n.ReadAt, &n.CreatedAt)
+	return n, err
+}
+
+// READ: count unread notification groups (what a badge should show)
+func CountUnreadNotificationGroups(db *sql.DB, userID int) (int, error) {
+	var count int
+	err := db.QueryRow(
+		`SELECT COUNT(DISTINCT (type, group_key))
+		 FROM notifications
+		 WHERE user_id=$1 AND read_at IS NULL`,
+		userID,
+	).Scan(&count)
+	return count, err
+}
+
+// UPDATE: mark every unread notification in a group as read
+func MarkNotificationGroupRead(db *sql.DB, userID int, notifType, groupKey string) error {
+	_, err := db.Exec(
+		`UPDATE notifications
+		 SET read_at=NOW()
+		 WHERE user_id=$1 AND type=$2 AND group_key=$3 AND read_at IS NULL`,
+		userID, notifType, groupKey,
+	)
+	return err
+}
+
+// UPDATE: mark all of a user's notifications as read
+func MarkAllNotificationsRead(db *sql.DB, userID int) error {
+	_, err := db.Exec(
+		`UPDATE notifications
+		 SET read_at=NOW()
+		 WHERE user_id=$1 AND read_at IS NULL`,
+		userID,
+	)
+	return err
+}
+
+// READ: get a user's notification preferences for every type.
+// Types without a stored preference are enabled.
+func GetNotificationPreferences(db *sql.DB, userID int) (map[string]bool, error) {
+	prefs := make(map[string]bool, len(NotificationTypes))
+	for _, t := range NotificationTypes {
+		prefs[t] = true
+	}
+
+	rows, err := db.Query(
+		`SELECT type, enabled FROM notification_preferences WHERE user_id=$1`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t string
+		var enabled bool
+		if err := rows.Scan(&t, &enabled); err != nil {
+			return nil, err
+		}
+		prefs[t] = enabled
+	}
+	return prefs, rows.Err()
+}
+
+// READ: check whether a user wants notifications of a type
+func NotificationEnabled(db *sql.DB, userID int, notifType string) (bool, error) {
+	var enabled bool
+	err := db.QueryRow(
+		`SELECT enabled FROM notification_preferences WHERE user_id=$1 AND type=$2`,
+		userID, notifType,
+	).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	return enabled, err
+}
+
+// UPDATE: set a user's preference for a notification type
+func SetNotificationPreference(db *sql.DB, userID int, notifType string, enabled bool) error {
+	_, err := db.Exec(
+		`INSERT INTO notification_preferences (user_id, type, enabled)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (user_id, type) DO UPDATE SET enabled=EXCLUDED.enabled`,
+		userID, notifType, enabled,
+	)
+	return err
+}
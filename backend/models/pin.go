@@ -0,0 +1,100 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// MaxPinnedPosts is how many posts a user can pin to their profile
+const MaxPinnedPosts = 3
+
+var (
+	ErrTooManyPins = errors.New("too many pinned posts")
+	ErrNotPinnable = errors.New("only posts shown on the profile can be pinned")
+)
+
+// profileConditions filters posts p down to what a profile shows to everyone:
+// published public posts not hidden by moderators. Whether the profile itself
+// is visible (suspensions, blocks) is checked separately.
+const profileConditions = `p.visibility = 'public'
+		   AND p.status = 'published'
+		   AND p.moderation_state = 'visible'`
+
+// UPDATE: pin a post to its author's profile. Pinning an already pinned post
+// is a no-op. Returns ErrNotPinnable unless the post is one the profile shows
+// (see profileConditions), and ErrTooManyPins if MaxPinnedPosts others are
+// pinned.
+func PinPost(db *sql.DB, userID, postID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the user so concurrent pins can't both pass the limit check
+	if _, err := tx.Exec(`SELECT 1 FROM users WHERE id=$1 FOR UPDATE`, userID); err != nil {
+		return err
+	}
+
+	var pinned int
+	if err := tx.QueryRow(
+		`SELECT COUNT(*) FROM posts WHERE user_id=$1 AND pinned_at IS NOT NULL AND id<>$2`,
+		userID, postID,
+	).Scan(&pinned); err != nil {
+		return err
+	}
+	if pinned >= MaxPinnedPosts {
+		return ErrTooManyPins
+	}
+
+	res, err := tx.Exec(
+		`UPDATE posts p SET pinned_at=COALESCE(p.pinned_at, NOW())
+		 WHERE p.id=$1 AND `+profileConditions,
+		postID,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotPinnable
+	}
+
+	return tx.Commit()
+}
+
+// UPDATE: unpin a post
+func UnpinPost(db *sql.DB, postID int) error {
+	_, err := db.Exec(`UPDATE posts SET pinned_at=NULL WHERE id=$1`, postID)
+	return err
+}
+
+// READ: a user's pinned posts as shown on their profile to viewerID, most
+// recently pinned first
+func GetPinnedPosts(db *sql.DB, userID, viewerID int) ([]PostWithDetails, error) {
+	return queryPostsWithDetails(db, viewerID,
+		`SELECT `+postColumns+`
+		 FROM posts p
+		 WHERE p.user_id=$1 AND p.pinned_at IS NOT NULL
+		   AND `+profileConditions+`
+		 ORDER BY p.pinned_at DESC`,
+		userID,
+	)
+}
+
+// READ: a user's profile timeline below the pinned posts, most recently
+// published first
+func GetProfilePosts(db *sql.DB, userID, viewerID int, limit, offset int) ([]PostWithDetails, error) {
+	return queryPostsWithDetails(db, viewerID,
+		`SELECT `+postColumns+`
+		 FROM posts p
+		 WHERE p.user_id=$1 AND p.pinned_at IS NULL
+		   AND `+profileConditions+`
+		 ORDER BY p.published_at DESC
+		 LIMIT $2 OFFSET $3`,
+		userID, limit, offset,
+	)
+}
@@ -3,122 +3,630 @@ package models
 import (
 	"database/sql"
 	"time"
+
+	"github.com/lib/pq"
+
+	"tomo/backend/utils"
 )
 
 // Post represents a reflection/journal entry
 type Post struct {
-	ID         int       `json:"id"`
-	UserID     int       `json:"user_id"`
-	SessionID  *int      `json:"session_id,omitempty"` // NULL for general posts
-	PostType   string    `json:"post_type"`            // 'session' or 'general'
-	Content    string    `json:"content,omitempty"`
-	Title      string    `json:"title,omitempty"`
-	MoodRating *int      `json:"mood_rating,omitempty"` // 1-5
-	Visibility string    `json:"visibility"`            // 'private' or 'public'
-	CreatedAt  time.Time `json:"created_at"`
-}
-
-// CREATE: insert a new post
-func CreatePost(db *sql.DB, userID int, sessionID *int, postType, content, title string, moodRating *int, visibility string) (Post, error) {
-	var post Post
+	ID                int        `json:"id"`
+	UserID            int        `json:"user_id"`
+	SessionID         *int       `json:"session_id,omitempty"`   // NULL for general posts
+	PostType          string     `json:"post_type"`              // 'session', 'general' or 'daily'
+	JournalDate       *string    `json:"journal_date,omitempty"` // YYYY-MM-DD, for daily entries only
+	ShareSlug         string     `json:"share_slug"`             // public identifier for /p/{slug} share links
+	Content           string     `json:"content,omitempty"`      // Markdown
+	ContentHTML       string     `json:"content_html,omitempty"` // content rendered to sanitized HTML
+	Title             string     `json:"title,omitempty"`
+	MoodRating        *int       `json:"mood_rating,omitempty"`  // 1-5
+	Visibility        string     `json:"visibility"`             // 'private', 'unlisted' or 'public'
+	CommentPermission string     `json:"comment_permission"`     // 'off', 'followers' or 'everyone'
+	TemplateID        *int       `json:"template_id,omitempty"`  // reflection template the post was written from
+	ModerationState   string     `json:"moderation_state"`       // 'visible' or 'hidden' (by a moderator)
+	Status            string     `json:"status"`                 // 'draft', 'scheduled' or 'published'
+	PublishedAt       *time.Time `json:"published_at,omitempty"` // NULL until published
+	PublishAt         *time.Time `json:"publish_at,omitempty"`   // when a scheduled post will be published
+	PinnedAt          *time.Time `json:"pinned_at,omitempty"`    // set while pinned to the author's profile
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         *time.Time `json:"updated_at,omitempty"` // last title/content/mood change
+	Edited            bool       `json:"edited"`               // changed since it was published
+}
+
+// ShareSlugLength is the length of post share slugs (62^10 possibilities)
+const ShareSlugLength = 10
+
+// postColumns is the column list every post query selects, in scanPost order
+const postColumns = `id, user_id, session_id, post_type, to_char(journal_date, 'YYYY-MM-DD'), share_slug, content, COALESCE(content_html, ''), title, mood_rating, visibility, comment_permission, template_id, moderation_state, status, published_at, publish_at, pinned_at, created_at, updated_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// withExtraColumns lets a scan helper read rows that select additional columns
+// after its own, e.g. scanPost(withExtraColumns{rows, []interface{}{&rank}})
+type withExtraColumns struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+func (w withExtraColumns) Scan(dest ...interface{}) error {
+	return w.row.Scan(append(dest, w.extra...)...)
+}
+
+// DBTX is satisfied by both *sql.DB and *sql.Tx, so helpers can run inside or
+// outside a transaction
+type DBTX interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
 
-	err := db.QueryRow(
-		`INSERT INTO posts (user_id, session_id, post_type, content, title, mood_rating, visibility, created_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
-		 RETURNING id, user_id, session_id, post_type, content, title, mood_rating, visibility, created_at`,
-		userID, sessionID, postType, content, title, moodRating, visibility,
-	).Scan(&post.ID, &post.UserID, &post.SessionID, &post.PostType, &post.Content, &post.Title, &post.MoodRating, &post.Visibility, &post.CreatedAt)
+// TagEdit describes how an update changes a post's tags. Replace is applied
+// first, then Remove, then Add.
+type TagEdit struct {
+	Replace *[]string // when set, the post's tags become exactly this list
+	Add     []string
+	Remove  []string
+	Prune   bool // delete tags that no longer have any posts after the edit
+}
 
+// scanPost reads a row selected with postColumns into a Post
+func scanPost(row rowScanner) (Post, error) {
+	var post Post
+	err := row.Scan(&post.ID, &post.UserID, &post.SessionID, &post.PostType, &post.JournalDate, &post.ShareSlug, &post.Content, &post.ContentHTML, &post.Title, &post.MoodRating, &post.Visibility, &post.CommentPermission, &post.TemplateID, &post.ModerationState, &post.Status, &post.PublishedAt, &post.PublishAt, &post.PinnedAt, &post.CreatedAt, &post.UpdatedAt)
+	post.Edited = post.Status == "published" && post.UpdatedAt != nil && post.PublishedAt != nil && post.UpdatedAt.After(*post.PublishedAt)
 	return post, err
 }
 
+// CanViewPost applies the visibility rules shared by every endpoint that
+// exposes a post by ID (or content hanging off it, such as comments). Unlisted
+// posts are only reachable through their share link, so by ID only their owner
+// can see them.
+func CanViewPost(db *sql.DB, post Post, viewerID int) (bool, error) {
+	if post.Visibility == "unlisted" && post.UserID != viewerID {
+		return false, nil
+	}
+	return CanViewSharedPost(db, post, viewerID)
+}
+
+// CanViewSharedPost applies the rules for a post opened through its share
+// link: those of CanViewPost, except that unlisted posts are visible to anyone
+func CanViewSharedPost(db *sql.DB, post Post, viewerID int) (bool, error) {
+	if post.UserID == viewerID {
+		return true, nil
+	}
+
+	// Only owner can view drafts, scheduled and private posts, and posts hidden by moderators
+	if post.Status != "published" || post.Visibility == "private" || post.ModerationState == "hidden" {
+		return false, nil
+	}
+
+	// Suspended authors and blocks (in either direction) hide posts
+	hidden, err := IsHiddenFrom(db, post.UserID, viewerID)
+	if err != nil {
+		return false, err
+	}
+	return !hidden, nil
+}
+
+// UPDATE: re-render up to limit posts after afterID whose cached HTML came
+// from an older renderer version, returning the last post ID seen (0 when none
+// are left). Reads serve the cached HTML as is, so this runs as a background job.
+func RefreshStaleContentHTML(db *sql.DB, opts utils.MarkdownOptions, afterID, limit int) (int, error) {
+	rows, err := db.Query(
+		`SELECT id, COALESCE(content, '')
+		 FROM posts
+		 WHERE content_html_version < $1 AND id > $2
+		 ORDER BY id
+		 LIMIT $3`,
+		utils.MarkdownRenderVersion, afterID, limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var stale []Post
+	for rows.Next() {
+		var p Post
+		if err := rows.Scan(&p.ID, &p.Content); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		stale = append(stale, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, p := range stale {
+		html, err := utils.RenderMarkdown(p.Content, opts)
+		if err != nil {
+			return 0, err
+		}
+		// The content check skips the write if the post was edited meanwhile
+		if _, err := db.Exec(
+			`UPDATE posts SET content_html=$1, content_html_version=$2
+			 WHERE id=$3 AND COALESCE(content, '')=$4 AND content_html_version < $2`,
+			html, utils.MarkdownRenderVersion, p.ID, p.Content,
+		); err != nil {
+			return 0, err
+		}
+	}
+	if len(stale) == 0 {
+		return 0, nil
+	}
+	return stale[len(stale)-1].ID, nil
+}
+
+// CREATE: insert a new post. Only the user-editable fields of p are used;
+// PublishAt is required for 'scheduled' posts. Content is rendered to HTML
+// with opts once here and cached.
+func CreatePost(db DBTX, p Post, opts utils.MarkdownOptions) (Post, error) {
+	if p.CommentPermission == "" {
+		p.CommentPermission = "everyone"
+	}
+	if p.Status == "" {
+		p.Status = "published"
+	}
+
+	html, err := utils.RenderMarkdown(p.Content, opts)
+	if err != nil {
+		return Post{}, err
+	}
+
+	// Slugs are random, so retry the rare collision with a fresh one. The
+	// collision is skipped rather than raised so this also works in a transaction.
+	for attempt := 1; ; attempt++ {
+		slug, err := utils.RandomSlug(ShareSlugLength)
+		if err != nil {
+			return Post{}, err
+		}
+
+		post, err := scanPost(db.QueryRow(
+			`INSERT INTO posts (user_id, session_id, post_type, content, content_html, content_html_version, title, mood_rating, visibility, comment_permission, template_id, status, published_at, publish_at, journal_date, share_slug, search_language, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12::post_status, CASE WHEN $12::post_status = 'published' THEN NOW() END,
+			         CASE WHEN $12::post_status = 'scheduled' THEN $13::timestamptz END, $14::date, $15,
+			         (SELECT search_language FROM users WHERE id=$1), NOW())
+			 ON CONFLICT (share_slug) DO NOTHING
+			 RETURNING `+postColumns,
+			p.UserID, p.SessionID, p.PostType, p.Content, html, utils.MarkdownRenderVersion, p.Title, p.MoodRating, p.Visibility, p.CommentPermission, p.TemplateID, p.Status, p.PublishAt, p.JournalDate, slug,
+		))
+		if err == sql.ErrNoRows && attempt < 3 {
+			continue
+		}
+		return post, err
+	}
+}
+
+// CREATE: insert a new post with its tags and template answers in one
+// transaction, so a failure never leaves a post without them
+func CreatePostWithTags(db *sql.DB, p Post, tags []string, answers []PostAnswer, opts utils.MarkdownOptions) (Post, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return Post{}, err
+	}
+	defer tx.Rollback()
+
+	post, err := CreatePost(tx, p, opts)
+	if err != nil {
+		return Post{}, err
+	}
+	if err := AddTagsToPost(tx, post.UserID, post.ID, tags); err != nil {
+		return Post{}, err
+	}
+	if len(answers) > 0 {
+		if err := SetPostAnswers(tx, post.ID, answers); err != nil {
+			return Post{}, err
+		}
+	}
+
+	return post, tx.Commit()
+}
+
+// READ: get a post by its share slug
+func GetPostBySlug(db *sql.DB, slug string) (Post, error) {
+	return scanPost(db.QueryRow(
+		`SELECT `+postColumns+`
+		 FROM posts
+		 WHERE share_slug=$1`,
+		slug,
+	))
+}
+
 // READ: get a post by ID
 func GetPostByID(db *sql.DB, postID int) (Post, error) {
-	var post Post
-	err := db.QueryRow(
-		`SELECT id, user_id, session_id, post_type, content, title, mood_rating, visibility, created_at
+	return scanPost(db.QueryRow(
+		`SELECT `+postColumns+`
 		 FROM posts
 		 WHERE id=$1`,
 		postID,
-	).Scan(&post.ID, &post.UserID, &post.SessionID, &post.PostType, &post.Content, &post.Title, &post.MoodRating, &post.Visibility, &post.CreatedAt)
-	return post, err
+	))
 }
 
-// READ: get all posts for a user (their personal journal view)
-// Returns posts with their tags
-func GetPostsWithDetailsByUserID(db *sql.DB, userID int, limit, offset int) ([]PostWithDetails, error) {
+// feedConditions filters posts p down to what belongs in the feed of the viewer
+// bound to viewerParam: published public posts, minus posts hidden by moderators,
+// posts by suspended users, and posts by users the viewer has blocked, been
+// blocked by, or muted
+func feedConditions(viewerParam string) string {
+	return `p.visibility = 'public'
+		   AND p.status = 'published'
+		   AND p.moderation_state = 'visible'
+		   AND NOT EXISTS (SELECT 1 FROM users su WHERE su.id = p.user_id AND su.suspended_at IS NOT NULL)
+		   AND NOT ` + blockedBetween("p.user_id", viewerParam) + `
+		   AND NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.muter_id = ` + viewerParam + ` AND m.muted_id = p.user_id)`
+}
+
+// READ: the users among userIDs whose feed includes a post (see feedConditions),
+// leaving out its author
+func GetFeedAudience(db *sql.DB, postID int, userIDs []int) ([]int, error) {
 	rows, err := db.Query(
-		`SELECT id, user_id, session_id, post_type, content, title, mood_rating, visibility, created_at
-		 FROM posts
-		 WHERE user_id=$1
-		 ORDER BY created_at DESC
-		 LIMIT $2 OFFSET $3`,
-		userID, limit, offset,
+		`SELECT v.id
+		 FROM posts p, unnest($2::int[]) AS v(id)
+		 WHERE p.id=$1 AND v.id <> p.user_id
+		   AND `+feedConditions("v.id"),
+		postID, pq.Array(userIDs),
 	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var posts []PostWithDetails // Change the slice type
+	var audience []int
 	for rows.Next() {
-		var post Post
-		if err := rows.Scan(&post.ID, &post.UserID, &post.SessionID, &post.PostType, &post.Content, &post.Title, &post.MoodRating, &post.Visibility, &post.CreatedAt); err != nil {
+		var id int
+		if err := rows.Scan(&id); err != nil {
 			return nil, err
 		}
+		audience = append(audience, id)
+	}
+	return audience, rows.Err()
+}
 
-		// Fetch tags for this post
-		tags, err := GetTagsForPost(db, post.ID)
+// queryPostsWithDetails runs a query selecting postColumns and loads each post's
+// details as seen by viewerID
+func queryPostsWithDetails(db *sql.DB, viewerID int, query string, args ...interface{}) ([]PostWithDetails, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []PostWithDetails{}
+	for rows.Next() {
+		post, err := scanPost(rows)
 		if err != nil {
 			return nil, err
 		}
+		posts = append(posts, PostWithDetails{Post: post})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return posts, loadPostDetails(db, posts, viewerID)
+}
+
+// READ: public posts for a user's feed, most recently published first (see feedConditions)
+func GetFeed(db *sql.DB, viewerID int, limit, offset int) ([]PostWithDetails, error) {
+	return queryPostsWithDetails(db, viewerID,
+		`SELECT `+postColumns+`
+		 FROM posts p
+		 WHERE `+feedConditions("$1")+`
+		 ORDER BY p.published_at DESC
+		 LIMIT $2 OFFSET $3`,
+		viewerID, limit, offset,
+	)
+}
+
+// READ: public posts from any user tagged with a (normalized) tag name, most
+// recently published first. The same posts are left out as in the feed.
+func GetPublicPostsByTag(db *sql.DB, viewerID int, tag string, limit, offset int) ([]PostWithDetails, error) {
+	return queryPostsWithDetails(db, viewerID,
+		`SELECT `+postColumns+`
+		 FROM posts p
+		 WHERE `+feedConditions("$1")+`
+		   AND EXISTS (
+		         SELECT 1 FROM post_tags pt
+		         JOIN tags t ON t.id = pt.tag_id
+		         WHERE pt.post_id = p.id AND t.name = $2
+		       )
+		 ORDER BY p.published_at DESC
+		 LIMIT $3 OFFSET $4`,
+		viewerID, tag, limit, offset,
+	)
+}
+
+// loadPostDetails fills in tags, media, comment count, answers and mentions for
+// each post. Comments and mentioned users are limited to those viewerID can see.
+// Answers and mentions are fetched for all the posts at once.
+func loadPostDetails(db *sql.DB, posts []PostWithDetails, viewerID int) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	postIDs := make([]int, len(posts))
+	for i, post := range posts {
+		postIDs[i] = post.ID
+	}
+	answers, err := GetAnswersForPosts(db, postIDs)
+	if err != nil {
+		return err
+	}
+	mentions, err := GetMentionsForPosts(db, postIDs, viewerID)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		// Fetch tags for this post
+		tags, err := GetTagsForPost(db, posts[i].ID)
+		if err != nil {
+			return err
+		}
 
 		// ADD MEDIA FETCH (Crucial for PostWithDetails)
-		media, err := GetMediaForPost(db, post.ID)
+		media, err := GetMediaForPost(db, posts[i].ID)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		// Populate PostWithDetails
-		posts = append(posts, PostWithDetails{
-			Post:  post,
-			Tags:  tags,
-			Media: media,
-		})
-	}
+		commentCount, err := CountCommentsForPost(db, posts[i].ID, viewerID)
+		if err != nil {
+			return err
+		}
 
-	return posts, rows.Err()
+		posts[i].Tags = tags
+		posts[i].Media = media
+		posts[i].CommentCount = commentCount
+		posts[i].Answers = answers[posts[i].ID]
+		posts[i].Mentions = mentions[posts[i].ID]
+	}
+	return nil
 }
 
 // READ: get post linked to a specific session
 func GetPostBySessionID(db *sql.DB, sessionID int) (Post, error) {
-	var post Post
-	err := db.QueryRow(
-		`SELECT id, user_id, session_id, post_type, content, title, mood_rating, visibility, created_at
+	return scanPost(db.QueryRow(
+		`SELECT `+postColumns+`
 		 FROM posts
 		 WHERE session_id=$1`,
 		sessionID,
-	).Scan(&post.ID, &post.UserID, &post.SessionID, &post.PostType, &post.Content, &post.Title, &post.MoodRating, &post.Visibility, &post.CreatedAt)
-	return post, err
+	))
 }
 
-// UPDATE: update a post's content. CANNOT UPDATE MEDIA
-func UpdatePost(db *sql.DB, postID int, content, title string, moodRating *int, visibility string) error {
-	_, err := db.Exec(
+// UPDATE: update a post's content and settings. CANNOT UPDATE MEDIA OR TAGS
+// (see UpdatePostWithTags). If a published post's title, content or mood
+// changes, the previous version is saved to post_revisions; run it in a
+// transaction so the two stay consistent. Content is rendered to HTML with opts.
+func UpdatePost(db DBTX, p Post, opts utils.MarkdownOptions) error {
+	if _, err := db.Exec(
+		`INSERT INTO post_revisions (post_id, title, content, mood_rating)
+		 SELECT id, title, content, mood_rating
+		 FROM posts
+		 WHERE id=$1 AND status='published'
+		   AND (title, content, mood_rating) IS DISTINCT FROM ($2::text, $3::text, $4::smallint)`,
+		p.ID, p.Title, p.Content, p.MoodRating,
+	); err != nil {
+		return err
+	}
+
+	html, err := utils.RenderMarkdown(p.Content, opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
 		`UPDATE posts
-		 SET content=$1, title=$2, mood_rating=$3, visibility=$4
-		 WHERE id=$5`,
-		content, title, moodRating, visibility, postID,
+		 SET content=$1, title=$2, mood_rating=$3, visibility=$4, comment_permission=$5,
+		     content_html=$7, content_html_version=$8,
+		     updated_at = CASE
+		         WHEN (content, title, mood_rating) IS DISTINCT FROM ($1::text, $2::text, $3::smallint) THEN NOW()
+		         ELSE updated_at
+		     END
+		 WHERE id=$6`,
+		p.Content, p.Title, p.MoodRating, p.Visibility, p.CommentPermission, p.ID, html, utils.MarkdownRenderVersion,
 	)
 	return err
 }
 
-// DELETE: remove a post by ID
-func DeletePost(db *sql.DB, postID int) error {
-	_, err := db.Exec(`DELETE FROM posts WHERE id=$1`, postID)
+// UPDATE: update a post, edit its tags and, unless answers is nil, replace its
+// template answers in one transaction, so a failed change never leaves the
+// post half-updated
+func UpdatePostWithTags(db *sql.DB, p Post, edit TagEdit, answers *[]PostAnswer, opts utils.MarkdownOptions) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := UpdatePost(tx, p, opts); err != nil {
+		return err
+	}
+
+	// Remember the post's current tags so they can be pruned afterwards
+	var previous pq.Int64Array
+	if err := tx.QueryRow(
+		`SELECT COALESCE(array_agg(tag_id), '{}') FROM post_tags WHERE post_id=$1`,
+		p.ID,
+	).Scan(&previous); err != nil {
+		return err
+	}
+
+	if edit.Replace != nil {
+		if _, err := tx.Exec(`DELETE FROM post_tags WHERE post_id=$1`, p.ID); err != nil {
+			return err
+		}
+		if err := AddTagsToPost(tx, p.UserID, p.ID, *edit.Replace); err != nil {
+			return err
+		}
+	}
+
+	if len(edit.Remove) > 0 {
+		names, err := NormalizeTags(edit.Remove)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(
+			`DELETE FROM post_tags pt
+			 USING tags t
+			 WHERE pt.tag_id = t.id AND pt.post_id=$1 AND t.name = ANY($2)`,
+			p.ID, pq.Array(names),
+		); err != nil {
+			return err
+		}
+	}
+
+	if err := AddTagsToPost(tx, p.UserID, p.ID, edit.Add); err != nil {
+		return err
+	}
+
+	if edit.Prune && len(previous) > 0 {
+		if _, err := tx.Exec(
+			`DELETE FROM tags t
+			 WHERE t.id = ANY($1) AND t.user_id=$2
+			   AND NOT EXISTS (SELECT 1 FROM post_tags pt WHERE pt.tag_id = t.id)`,
+			previous, p.UserID,
+		); err != nil {
+			return err
+		}
+	}
+
+	if answers != nil {
+		if err := SetPostAnswers(tx, p.ID, *answers); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UPDATE: publish a draft or scheduled post now. Returns sql.ErrNoRows if the
+// post is already published.
+func PublishPost(db *sql.DB, postID int) error {
+	return execOnePost(db,
+		`UPDATE posts SET status='published', published_at=NOW(), publish_at=NULL
+		 WHERE id=$1 AND status IN ('draft', 'scheduled')`,
+		postID,
+	)
+}
+
+// UPDATE: schedule a draft, or reschedule a scheduled post, to be published at
+// publishAt. Returns sql.ErrNoRows if the post is already published.
+func SchedulePost(db *sql.DB, postID int, publishAt time.Time) error {
+	return execOnePost(db,
+		`UPDATE posts SET status='scheduled', publish_at=$2
+		 WHERE id=$1 AND status IN ('draft', 'scheduled')`,
+		postID, publishAt,
+	)
+}
+
+// UPDATE: turn a scheduled post back into a draft. Returns sql.ErrNoRows if the
+// post is not scheduled.
+func UnschedulePost(db *sql.DB, postID int) error {
+	return execOnePost(db,
+		`UPDATE posts SET status='draft', publish_at=NULL WHERE id=$1 AND status='scheduled'`,
+		postID,
+	)
+}
+
+// publishableCondition is true for posts p with something to publish: a
+// non-blank title or content, or template answers
+const publishableCondition = `(coalesce(p.title, '') ~ '\S'
+	OR coalesce(p.content, '') ~ '\S'
+	OR EXISTS (SELECT 1 FROM post_answers a WHERE a.post_id = p.id))`
+
+// UPDATE: publish every scheduled post whose time has come, returning them.
+// Due posts with nothing to publish are turned back into drafts and returned
+// separately.
+func PublishDuePosts(db *sql.DB) (published, reverted []Post, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	if published, err = queryPosts(tx,
+		`UPDATE posts p SET status='published', published_at=NOW(), publish_at=NULL
+		 WHERE p.status='scheduled' AND p.publish_at <= NOW() AND `+publishableCondition+`
+		 RETURNING `+postColumns,
+	); err != nil {
+		return nil, nil, err
+	}
+	if reverted, err = queryPosts(tx,
+		`UPDATE posts p SET status='draft', publish_at=NULL
+		 WHERE p.status='scheduled' AND p.publish_at <= NOW() AND NOT `+publishableCondition+`
+		 RETURNING `+postColumns,
+	); err != nil {
+		return nil, nil, err
+	}
+
+	return published, reverted, tx.Commit()
+}
+
+// queryPosts runs a query selecting postColumns and scans every row
+func queryPosts(db DBTX, query string, args ...interface{}) ([]Post, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []Post
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// execOnePost runs a statement that should change one post, returning
+// sql.ErrNoRows if it changed none
+func execOnePost(db *sql.DB, query string, args ...interface{}) error {
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UPDATE: set a post's moderation state ('visible' or 'hidden')
+func SetPostModerationState(db *sql.DB, postID int, state string) error {
+	_, err := db.Exec(`UPDATE posts SET moderation_state=$1 WHERE id=$2`, state, postID)
 	return err
 }
 
+// DELETE: remove a post by ID, returning the storage keys of media files no
+// longer used by any post
+func DeletePost(db *sql.DB, postID int) ([]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Media would cascade, but releasing it tells us which files are now unused
+	unused, err := deleteMediaWhere(tx, `post_id=$1`, postID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM posts WHERE id=$1`, postID); err != nil {
+		return nil, err
+	}
+	return unused, tx.Commit()
+}
+
 // Helper: get tags for a post
 func GetTagsForPost(db *sql.DB, postID int) ([]string, error) {
 	rows, err := db.Query(
@@ -145,8 +653,14 @@ func GetTagsForPost(db *sql.DB, postID int) ([]string, error) {
 	return tags, rows.Err()
 }
 
-// Helper: add tags to a post
-func AddTagsToPost(db *sql.DB, userID, postID int, tagNames []string) error {
+// Helper: add tags to a post. Names are normalized (see NormalizeTag) before
+// they are stored, so tags never differ only by case or spacing.
+func AddTagsToPost(db DBTX, userID, postID int, tagNames []string) error {
+	tagNames, err := NormalizeTags(tagNames)
+	if err != nil {
+		return err
+	}
+
 	for _, tagName := range tagNames {
 		// Get or create tag
 		var tagID int
@@ -175,33 +689,26 @@ func AddTagsToPost(db *sql.DB, userID, postID int, tagNames []string) error {
 	return nil
 }
 
-// PostWithDetails includes post, tags, and media
+// PostWithDetails includes post, tags, media, and comment count
 type PostWithDetails struct {
 	Post
-	Tags  []string    `json:"tags,omitempty"`
-	Media []PostMedia `json:"media,omitempty"`
+	Tags         []string     `json:"tags,omitempty"`
+	Media        []PostMedia  `json:"media,omitempty"`
+	CommentCount int          `json:"comment_count"`
+	Answers      []PostAnswer `json:"answers,omitempty"`  // for posts written from a template
+	Mentions     []Mention    `json:"mentions,omitempty"` // users @mentioned in the content
 }
 
-// Helper: get complete post with tags and media
-func GetPostWithDetails(db *sql.DB, postID int) (PostWithDetails, error) {
+// Helper: get complete post with tags, media and template answers
+func GetPostWithDetails(db *sql.DB, postID, viewerID int) (PostWithDetails, error) {
 	post, err := GetPostByID(db, postID)
 	if err != nil {
 		return PostWithDetails{}, err
 	}
 
-	tags, err := GetTagsForPost(db, postID)
-	if err != nil {
-		return PostWithDetails{}, err
-	}
-
-	media, err := GetMediaForPost(db, postID)
-	if err != nil {
+	posts := []PostWithDetails{{Post: post}}
+	if err := loadPostDetails(db, posts, viewerID); err != nil {
 		return PostWithDetails{}, err
 	}
-
-	return PostWithDetails{
-		Post:  post,
-		Tags:  tags,
-		Media: media,
-	}, nil
+	return posts[0], nil
 }
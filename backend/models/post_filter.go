@@ -0,0 +1,155 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PostFilter narrows down a user's posts. Zero values mean "no filter".
+type PostFilter struct {
+	Tags       []string // normalized tag names
+	MatchAll   bool     // require every tag instead of any of them
+	PostType   string
+	MoodMin    int
+	MoodMax    int
+	Visibility string
+	Status     string     // 'draft', 'scheduled' or 'published'
+	From       *time.Time // created_at >= From
+	To         *time.Time // created_at < To
+	Cursor     string     // from a previous page's NextCursor
+	Limit      int
+}
+
+// PostPage is one page of posts plus the cursor for the next page, if any
+type PostPage struct {
+	Posts      []PostWithDetails `json:"posts"`
+	Count      int               `json:"count"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// EncodePostCursor makes an opaque cursor pointing just after a post in
+// (created_at DESC, id DESC) order
+func EncodePostCursor(createdAt time.Time, id int) string {
+	raw := fmt.Sprintf("%d:%d", createdAt.UnixNano(), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodePostCursor reverses EncodePostCursor
+func DecodePostCursor(cursor string) (time.Time, int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	nanos, idStr, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	return time.Unix(0, n), id, nil
+}
+
+// READ: get a page of a user's posts matching a filter, newest first (their
+// personal journal view)
+func GetPostsWithDetailsByUserID(db *sql.DB, userID int, f PostFilter) (PostPage, error) {
+	where := []string{"p.user_id = $1"}
+	args := []interface{}{userID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if len(f.Tags) > 0 {
+		// Resolve names to the user's tag IDs first so idx_post_tags_tag_id drives the lookup
+		tagMatch := `p.id IN (
+		     SELECT pt.post_id
+		     FROM post_tags pt
+		     JOIN tags t ON t.id = pt.tag_id
+		     WHERE t.user_id = $1 AND t.name = ANY(` + arg(pq.Array(f.Tags)) + `)`
+		if f.MatchAll {
+			tagMatch += `
+		     GROUP BY pt.post_id
+		     HAVING COUNT(DISTINCT pt.tag_id) = ` + arg(len(f.Tags))
+		}
+		where = append(where, tagMatch+")")
+	}
+	if f.PostType != "" {
+		where = append(where, "p.post_type = "+arg(f.PostType))
+	}
+	if f.MoodMin > 0 {
+		where = append(where, "p.mood_rating >= "+arg(f.MoodMin))
+	}
+	if f.MoodMax > 0 {
+		where = append(where, "p.mood_rating <= "+arg(f.MoodMax))
+	}
+	if f.Visibility != "" {
+		where = append(where, "p.visibility = "+arg(f.Visibility))
+	}
+	if f.Status != "" {
+		where = append(where, "p.status = "+arg(f.Status))
+	}
+	if f.From != nil {
+		where = append(where, "p.created_at >= "+arg(*f.From))
+	}
+	if f.To != nil {
+		where = append(where, "p.created_at < "+arg(*f.To))
+	}
+	if f.Cursor != "" {
+		createdAt, id, err := DecodePostCursor(f.Cursor)
+		if err != nil {
+			return PostPage{}, err
+		}
+		where = append(where, "(p.created_at, p.id) < ("+arg(createdAt)+", "+arg(id)+")")
+	}
+
+	// Fetch one extra row to learn whether there is a next page
+	rows, err := db.Query(
+		`SELECT `+postColumns+`
+		 FROM posts p
+		 WHERE `+strings.Join(where, "\n		   AND ")+`
+		 ORDER BY p.created_at DESC, p.id DESC
+		 LIMIT `+arg(f.Limit+1),
+		args...,
+	)
+	if err != nil {
+		return PostPage{}, err
+	}
+	defer rows.Close()
+
+	posts := []PostWithDetails{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return PostPage{}, err
+		}
+		posts = append(posts, PostWithDetails{Post: post})
+	}
+	if err := rows.Err(); err != nil {
+		return PostPage{}, err
+	}
+
+	page := PostPage{Posts: posts}
+	if len(posts) > f.Limit {
+		page.Posts = posts[:f.Limit]
+		last := page.Posts[f.Limit-1]
+		page.NextCursor = EncodePostCursor(last.CreatedAt, last.ID)
+	}
+	page.Count = len(page.Posts)
+
+	return page, loadPostDetails(db, page.Posts, userID)
+}
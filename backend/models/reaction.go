@@ -0,0 +1,79 @@
+package models
+
+import "database/sql"
+
+// ReactionKinds are the reactions a user can leave on a post
+var ReactionKinds = []string{"heart", "clap", "fire", "hug", "lightbulb"}
+
+// ValidReactionKind reports whether kind is one of ReactionKinds
+func ValidReactionKind(kind string) bool {
+	for _, k := range ReactionKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// CREATE/UPDATE: set a user's reaction to a post. Returns true when the user
+// had not reacted to the post before (changing the kind returns false).
+func SetPostReaction(db *sql.DB, postID, userID int, kind string) (bool, error) {
+	var inserted bool
+	err := db.QueryRow(
+		`INSERT INTO post_reactions (post_id, user_id, kind, created_at)
+		 VALUES ($1, $2, $3, NOW())
+		 ON CONFLICT (post_id, user_id) DO UPDATE SET kind = EXCLUDED.kind
+		 RETURNING (xmax = 0)`,
+		postID, userID, kind,
+	).Scan(&inserted)
+	return inserted, err
+}
+
+// DELETE: remove a user's reaction to a post
+func DeletePostReaction(db *sql.DB, postID, userID int) error {
+	_, err := db.Exec(
+		`DELETE FROM post_reactions WHERE post_id=$1 AND user_id=$2`,
+		postID, userID,
+	)
+	return err
+}
+
+// PostReactions summarizes the reactions on a post for one viewer
+type PostReactions struct {
+	Counts map[string]int `json:"counts"` // by kind
+	Mine   string         `json:"mine,omitempty"`
+}
+
+// READ: reaction counts for a post, leaving out suspended users and users
+// blocked by (or blocking) the viewer, plus the viewer's own reaction
+func GetPostReactions(db *sql.DB, postID, viewerID int) (PostReactions, error) {
+	rows, err := db.Query(
+		`SELECT r.kind, COUNT(*), BOOL_OR(r.user_id = $2)
+		 FROM post_reactions r
+		 JOIN users u ON u.id = r.user_id
+		 WHERE r.post_id=$1
+		   AND u.suspended_at IS NULL
+		   AND NOT `+blockedBetween("r.user_id", "$2")+`
+		 GROUP BY r.kind`,
+		postID, viewerID,
+	)
+	if err != nil {
+		return PostReactions{}, err
+	}
+	defer rows.Close()
+
+	reactions := PostReactions{Counts: map[string]int{}}
+	for rows.Next() {
+		var kind string
+		var count int
+		var mine bool
+		if err := rows.Scan(&kind, &count, &mine); err != nil {
+			return PostReactions{}, err
+		}
+		reactions.Counts[kind] = count
+		if mine {
+			reactions.Mine = kind
+		}
+	}
+	return reactions, rows.Err()
+}
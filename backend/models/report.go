@@ -0,0 +1,105 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ReportTargets lists what can be reported
+var ReportTargets = []string{"post", "comment", "media", "user"}
+
+// ReportReasons lists why something can be reported
+var ReportReasons = []string{"spam", "harassment", "hate", "self_harm", "sexual", "violence", "other"}
+
+// Report is a user's report about a post, comment, media item or profile
+type Report struct {
+	ID             int        `json:"id"`
+	ReporterID     *int       `json:"reporter_id,omitempty"` // NULL once the reporter deletes their account
+	TargetType     string     `json:"target_type"`           // 'post', 'comment', 'media' or 'user'
+	TargetID       int        `json:"target_id"`
+	Reason         string     `json:"reason"`
+	Details        string     `json:"details,omitempty"`
+	Status         string     `json:"status"` // 'open', 'resolved' or 'dismissed'
+	ResolvedBy     *int       `json:"resolved_by,omitempty"`
+	ResolutionNote string     `json:"resolution_note,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+const reportColumns = `id, reporter_id, target_type, target_id, reason, COALESCE(details, ''), status, resolved_by, COALESCE(resolution_note, ''), resolved_at, created_at`
+
+func scanReport(row rowScanner) (Report, error) {
+	var r Report
+	err := row.Scan(&r.ID, &r.ReporterID, &r.TargetType, &r.TargetID, &r.Reason, &r.Details, &r.Status, &r.ResolvedBy, &r.ResolutionNote, &r.ResolvedAt, &r.CreatedAt)
+	return r, err
+}
+
+// CREATE: file a report. Returns sql.ErrNoRows if the reporter already has an
+// open report on the same target.
+func CreateReport(db *sql.DB, reporterID int, targetType string, targetID int, reason, details string) (Report, error) {
+	return scanReport(db.QueryRow(
+		`INSERT INTO reports (reporter_id, target_type, target_id, reason, details, status, created_at)
+		 VALUES ($1, $2, $3, $4, $5, 'open', NOW())
+		 ON CONFLICT (reporter_id, target_type, target_id) WHERE status = 'open' DO NOTHING
+		 RETURNING `+reportColumns,
+		reporterID, targetType, targetID, reason, details,
+	))
+}
+
+// READ: get a report by ID
+func GetReportByID(db *sql.DB, reportID int) (Report, error) {
+	return scanReport(db.QueryRow(
+		`SELECT `+reportColumns+`
+		 FROM reports
+		 WHERE id=$1`,
+		reportID,
+	))
+}
+
+// READ: list reports with a given status. Open reports come oldest first so
+// the queue is worked in order; closed ones newest first.
+func GetReports(db *sql.DB, status string, limit, offset int) ([]Report, error) {
+	order := "created_at DESC"
+	if status == "open" {
+		order = "created_at ASC"
+	}
+
+	rows, err := db.Query(
+		`SELECT `+reportColumns+`
+		 FROM reports
+		 WHERE status=$1
+		 ORDER BY `+order+`
+		 LIMIT $2 OFFSET $3`,
+		status, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []Report{}
+	for rows.Next() {
+		r, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+	return reports, rows.Err()
+}
+
+// UPDATE: close every open report on a target with the same outcome, so a
+// piece of content reported by many users is handled once. Returns the number
+// of reports closed.
+func ResolveReportsForTarget(db *sql.DB, targetType string, targetID int, status string, resolverID int, note string) (int64, error) {
+	res, err := db.Exec(
+		`UPDATE reports
+		 SET status=$1, resolved_by=$2, resolution_note=$3, resolved_at=NOW()
+		 WHERE target_type=$4 AND target_id=$5 AND status='open'`,
+		status, resolverID, note, targetType, targetID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
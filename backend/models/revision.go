@@ -0,0 +1,59 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PostRevision is a previous version of a published post
+type PostRevision struct {
+	ID         int       `json:"id"`
+	PostID     int       `json:"post_id"`
+	Title      string    `json:"title,omitempty"`
+	Content    string    `json:"content,omitempty"`
+	MoodRating *int      `json:"mood_rating,omitempty"`
+	CreatedAt  time.Time `json:"created_at"` // when this version was replaced
+}
+
+const revisionColumns = `id, post_id, COALESCE(title, ''), COALESCE(content, ''), mood_rating, created_at`
+
+func scanRevision(row rowScanner) (PostRevision, error) {
+	var rev PostRevision
+	err := row.Scan(&rev.ID, &rev.PostID, &rev.Title, &rev.Content, &rev.MoodRating, &rev.CreatedAt)
+	return rev, err
+}
+
+// READ: get a post's previous versions, newest first
+func GetRevisionsForPost(db *sql.DB, postID int) ([]PostRevision, error) {
+	rows, err := db.Query(
+		`SELECT `+revisionColumns+`
+		 FROM post_revisions
+		 WHERE post_id=$1
+		 ORDER BY created_at DESC, id DESC`,
+		postID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []PostRevision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+// READ: get a revision by ID
+func GetRevisionByID(db *sql.DB, revisionID int) (PostRevision, error) {
+	return scanRevision(db.QueryRow(
+		`SELECT `+revisionColumns+`
+		 FROM post_revisions
+		 WHERE id=$1`,
+		revisionID,
+	))
+}
@@ -0,0 +1,213 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// FocusRoom is a co-focus (body-doubling) room with a shared timer
+type FocusRoom struct {
+	ID                   int        `json:"id"`
+	OwnerID              int        `json:"owner_id"`
+	Name                 string     `json:"name"`
+	InviteCode           string     `json:"invite_code"`
+	TimerStartedAt       *time.Time `json:"timer_started_at,omitempty"`       // NULL when no timer is running
+	TimerDurationMinutes *int       `json:"timer_duration_minutes,omitempty"` // NULL when no timer is running
+	CreatedAt            time.Time  `json:"created_at"`
+}
+
+// RoomMember is a user who has joined a room
+type RoomMember struct {
+	UserID      int       `json:"user_id"`
+	Username    string    `json:"username,omitempty"`
+	DisplayName string    `json:"display_name,omitempty"`
+	PictureURL  string    `json:"picture_url,omitempty"`
+	JoinedAt    time.Time `json:"joined_at"`
+}
+
+const roomColumns = `id, owner_id, name, invite_code, timer_started_at, timer_duration_minutes, created_at`
+
+func scanRoom(row rowScanner) (FocusRoom, error) {
+	var room FocusRoom
+	err := row.Scan(&room.ID, &room.OwnerID, &room.Name, &room.InviteCode, &room.TimerStartedAt, &room.TimerDurationMinutes, &room.CreatedAt)
+	return room, err
+}
+
+// CREATE: insert a new room; the owner joins it automatically
+func CreateRoom(db *sql.DB, ownerID int, name, inviteCode string) (FocusRoom, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return FocusRoom{}, err
+	}
+	defer tx.Rollback()
+
+	room, err := scanRoom(tx.QueryRow(
+		`INSERT INTO focus_rooms (owner_id, name, invite_code, created_at)
+		 VALUES ($1, $2, $3, NOW())
+		 RETURNING `+roomColumns,
+		ownerID, name, inviteCode,
+	))
+	if err != nil {
+		return FocusRoom{}, err
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO focus_room_members (room_id, user_id, joined_at)
+		 VALUES ($1, $2, NOW())`,
+		room.ID, ownerID,
+	); err != nil {
+		return FocusRoom{}, err
+	}
+
+	return room, tx.Commit()
+}
+
+// READ: get a room by ID
+func GetRoomByID(db *sql.DB, roomID int) (FocusRoom, error) {
+	return scanRoom(db.QueryRow(
+		`SELECT `+roomColumns+`
+		 FROM focus_rooms
+		 WHERE id=$1`,
+		roomID,
+	))
+}
+
+// READ: get a room by invite code
+func GetRoomByInviteCode(db *sql.DB, code string) (FocusRoom, error) {
+	return scanRoom(db.QueryRow(
+		`SELECT `+roomColumns+`
+		 FROM focus_rooms
+		 WHERE invite_code=$1`,
+		code,
+	))
+}
+
+// READ: get all rooms a user has joined
+func GetRoomsForUser(db *sql.DB, userID int) ([]FocusRoom, error) {
+	rows, err := db.Query(
+		`SELECT r.id, r.owner_id, r.name, r.invite_code, r.timer_started_at, r.timer_duration_minutes, r.created_at
+		 FROM focus_rooms r
+		 JOIN focus_room_members m ON m.room_id = r.id
+		 WHERE m.user_id=$1
+		 ORDER BY m.joined_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rooms := []FocusRoom{}
+	for rows.Next() {
+		room, err := scanRoom(rows)
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, rows.Err()
+}
+
+// READ: get a room's members with their public profiles
+func GetRoomMembers(db *sql.DB, roomID int) ([]RoomMember, error) {
+	rows, err := db.Query(
+		`SELECT u.id, COALESCE(u.username, ''), COALESCE(u.display_name, ''), COALESCE(u.picture_url, ''), m.joined_at
+		 FROM focus_room_members m
+		 JOIN users u ON u.id = m.user_id
+		 WHERE m.room_id=$1
+		 ORDER BY m.joined_at ASC`,
+		roomID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []RoomMember{}
+	for rows.Next() {
+		var m RoomMember
+		if err := rows.Scan(&m.UserID, &m.Username, &m.DisplayName, &m.PictureURL, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// READ: check whether a user has joined a room
+func IsRoomMember(db *sql.DB, roomID, userID int) (bool, error) {
+	var exists bool
+	err := db.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM focus_room_members WHERE room_id=$1 AND user_id=$2)`,
+		roomID, userID,
+	).Scan(&exists)
+	return exists, err
+}
+
+// READ: count a room's members
+func CountRoomMembers(db *sql.DB, roomID int) (int, error) {
+	var count int
+	err := db.QueryRow(
+		`SELECT COUNT(*) FROM focus_room_members WHERE room_id=$1`,
+		roomID,
+	).Scan(&count)
+	return count, err
+}
+
+// CREATE: add a member to a room (no-op if already a member)
+func AddRoomMember(db *sql.DB, roomID, userID int) error {
+	_, err := db.Exec(
+		`INSERT INTO focus_room_members (room_id, user_id, joined_at)
+		 VALUES ($1, $2, NOW())
+		 ON CONFLICT DO NOTHING`,
+		roomID, userID,
+	)
+	return err
+}
+
+// DELETE: remove a member from a room
+func RemoveRoomMember(db *sql.DB, roomID, userID int) error {
+	_, err := db.Exec(
+		`DELETE FROM focus_room_members WHERE room_id=$1 AND user_id=$2`,
+		roomID, userID,
+	)
+	return err
+}
+
+// UPDATE: replace a room's invite code (old codes stop working)
+func UpdateRoomInviteCode(db *sql.DB, roomID int, code string) error {
+	_, err := db.Exec(
+		`UPDATE focus_rooms SET invite_code=$1 WHERE id=$2`,
+		code, roomID,
+	)
+	return err
+}
+
+// UPDATE: start (or, with nil values, clear) the room's shared timer
+func SetRoomTimer(db *sql.DB, roomID int, startedAt *time.Time, durationMinutes *int) error {
+	_, err := db.Exec(
+		`UPDATE focus_rooms
+		 SET timer_started_at=$1, timer_duration_minutes=$2
+		 WHERE id=$3`,
+		startedAt, durationMinutes, roomID,
+	)
+	return err
+}
+
+// UPDATE: clear the room's shared timer if it is still the one started at
+// startedAt, so a late clear can't wipe out a newer timer
+func ClearRoomTimer(db *sql.DB, roomID int, startedAt time.Time) error {
+	_, err := db.Exec(
+		`UPDATE focus_rooms
+		 SET timer_started_at=NULL, timer_duration_minutes=NULL
+		 WHERE id=$1 AND timer_started_at=$2`,
+		roomID, startedAt,
+	)
+	return err
+}
+
+// DELETE: remove a room (sessions recorded in it keep existing, untagged)
+func DeleteRoom(db *sql.DB, roomID int) error {
+	_, err := db.Exec(`DELETE FROM focus_rooms WHERE id=$1`, roomID)
+	return err
+}
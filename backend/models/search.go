@@ -0,0 +1,114 @@
+package models
+
+import (
+	"database/sql"
+	"html"
+	"strings"
+)
+
+// SearchLanguages are the Postgres text search configurations a user may pick
+// for their posts. 'simple' does no stemming or stop words, for languages
+// without a dedicated configuration.
+var SearchLanguages = []string{
+	"simple", "arabic", "danish", "dutch", "english", "finnish", "french", "german",
+	"hungarian", "indonesian", "irish", "italian", "lithuanian", "nepali", "norwegian",
+	"portuguese", "romanian", "russian", "spanish", "swedish", "tamil", "turkish",
+}
+
+// Sentinels ts_headline wraps matched words in. They are Unicode private-use
+// characters, which HTML escaping leaves alone, and are stripped from titles
+// and content before highlighting, so after escaping any that remain can only
+// be ts_headline's. HighlightSnippet turns them into <mark> tags.
+const (
+	highlightStart = "\uE000"
+	highlightStop  = "\uE001"
+)
+
+// SearchResult is a post matching a search, with its rank and highlighted
+// title and content snippet. Highlights are HTML: matched words are wrapped
+// in <mark> and everything else is escaped.
+type SearchResult struct {
+	PostWithDetails
+	Rank           float64 `json:"rank"`
+	TitleHighlight string  `json:"title_highlight,omitempty"`
+	Snippet        string  `json:"snippet"`
+}
+
+// HighlightSnippet escapes a ts_headline result for use as HTML, turning the
+// sentinels around matched words into <mark> tags
+func HighlightSnippet(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, highlightStart, "<mark>")
+	return strings.ReplaceAll(s, highlightStop, "</mark>")
+}
+
+// READ: full-text search over post titles and content, best matches first. The
+// query uses web search syntax ("quoted phrases", -excluded, or) and is parsed
+// with the viewer's search language. Results are the viewer's own posts
+// (drafts included) plus published public posts they are allowed to see.
+func SearchPosts(db *sql.DB, viewerID int, query string, limit, offset int) ([]SearchResult, error) {
+	markers := "StartSel=" + highlightStart + ", StopSel=" + highlightStop
+	rows, err := db.Query(
+		`WITH q AS (
+		     SELECT websearch_to_tsquery(u.search_language, $2) AS query
+		     FROM users u
+		     WHERE u.id = $1
+		 )
+		 SELECT `+postColumns+`,
+		        ts_rank_cd(p.search_vector, q.query) AS rank,
+		        ts_headline(p.search_language, translate(coalesce(p.title, ''), $7, ''), q.query, $5),
+		        ts_headline(p.search_language, translate(coalesce(p.content, ''), $7, ''), q.query, $6)
+		 FROM posts p, q
+		 WHERE p.search_vector @@ q.query
+		   AND (
+		         p.user_id = $1
+		         OR (p.visibility = 'public'
+		             AND p.status = 'published'
+		             AND p.moderation_state = 'visible'
+		             AND NOT EXISTS (SELECT 1 FROM users su WHERE su.id = p.user_id AND su.suspended_at IS NOT NULL)
+		             AND NOT `+blockedBetween("p.user_id", "$1")+`)
+		       )
+		 ORDER BY rank DESC, p.created_at DESC
+		 LIMIT $3 OFFSET $4`,
+		viewerID, query, limit, offset,
+		markers+", HighlightAll=true",
+		markers+`, MaxFragments=2, MinWords=10, MaxWords=30, FragmentDelimiter=" … "`,
+		highlightStart+highlightStop,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []PostWithDetails{}
+	var ranks []float64
+	var titles, snippets []string
+	for rows.Next() {
+		var rank float64
+		var title, snippet string
+		post, err := scanPost(withExtraColumns{rows, []interface{}{&rank, &title, &snippet}})
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, PostWithDetails{Post: post})
+		ranks = append(ranks, rank)
+		titles = append(titles, title)
+		snippets = append(snippets, snippet)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := loadPostDetails(db, posts, viewerID); err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, len(posts))
+	for i, post := range posts {
+		results[i] = SearchResult{PostWithDetails: post, Rank: ranks[i], Snippet: HighlightSnippet(snippets[i])}
+		if post.Title != "" {
+			results[i].TitleHighlight = HighlightSnippet(titles[i])
+		}
+	}
+	return results, nil
+}
@@ -12,42 +12,45 @@ type FocusSession struct {
 	StartTime       time.Time `json:"start_time"`
 	EndTime         time.Time `json:"end_time"`
 	DurationMinutes int       `json:"duration_minutes"`
+	RoomID          *int      `json:"room_id,omitempty"` // set when completed in a focus room
 	CreatedAt       time.Time `json:"created_at"`
 }
 
-// CREATE: insert a new focus session
-func CreateSession(db *sql.DB, userID int, startTime, endTime time.Time) (FocusSession, error) {
+const sessionColumns = `id, user_id, start_time, end_time, duration_minutes, room_id, created_at`
+
+func scanSession(row rowScanner) (FocusSession, error) {
 	var session FocusSession
+	err := row.Scan(&session.ID, &session.UserID, &session.StartTime, &session.EndTime, &session.DurationMinutes, &session.RoomID, &session.CreatedAt)
+	return session, err
+}
 
+// CREATE: insert a new focus session. roomID is nil outside focus rooms.
+func CreateSession(db *sql.DB, userID int, roomID *int, startTime, endTime time.Time) (FocusSession, error) {
 	// Calculate duration in minutes
 	duration := int(endTime.Sub(startTime).Minutes())
 
-	err := db.QueryRow(
-		`INSERT INTO focus_sessions (user_id, start_time, end_time, duration_minutes, created_at)
-		 VALUES ($1, $2, $3, $4, NOW())
-		 RETURNING id, user_id, start_time, end_time, duration_minutes, created_at`,
-		userID, startTime, endTime, duration,
-	).Scan(&session.ID, &session.UserID, &session.StartTime, &session.EndTime, &session.DurationMinutes, &session.CreatedAt)
-
-	return session, err
+	return scanSession(db.QueryRow(
+		`INSERT INTO focus_sessions (user_id, room_id, start_time, end_time, duration_minutes, created_at)
+		 VALUES ($1, $2, $3, $4, $5, NOW())
+		 RETURNING `+sessionColumns,
+		userID, roomID, startTime, endTime, duration,
+	))
 }
 
 // READ: get a session by ID
 func GetSessionByID(db *sql.DB, sessionID int) (FocusSession, error) {
-	var session FocusSession
-	err := db.QueryRow(
-		`SELECT id, user_id, start_time, end_time, duration_minutes, created_at
+	return scanSession(db.QueryRow(
+		`SELECT `+sessionColumns+`
 		 FROM focus_sessions
 		 WHERE id=$1`,
 		sessionID,
-	).Scan(&session.ID, &session.UserID, &session.StartTime, &session.EndTime, &session.DurationMinutes, &session.CreatedAt)
-	return session, err
+	))
 }
 
 // READ: get all sessions for a user (paginated)
 func GetSessionsByUserID(db *sql.DB, userID int, limit, offset int) ([]FocusSession, error) {
 	rows, err := db.Query(
-		`SELECT id, user_id, start_time, end_time, duration_minutes, created_at
+		`SELECT `+sessionColumns+`
 		 FROM focus_sessions
 		 WHERE user_id=$1
 		 ORDER BY start_time DESC
@@ -61,8 +64,8 @@ func GetSessionsByUserID(db *sql.DB, userID int, limit, offset int) ([]FocusSess
 
 	var sessions []FocusSession
 	for rows.Next() {
-		var session FocusSession
-		if err := rows.Scan(&session.ID, &session.UserID, &session.StartTime, &session.EndTime, &session.DurationMinutes, &session.CreatedAt); err != nil {
+		session, err := scanSession(rows)
+		if err != nil {
 			return nil, err
 		}
 		sessions = append(sessions, session)
@@ -94,8 +97,23 @@ func GetUserSessionStats(db *sql.DB, userID int) (map[string]interface{}, error)
 	}, nil
 }
 
-// DELETE: remove a session by ID
-func DeleteSession(db *sql.DB, sessionID int) error {
-	_, err := db.Exec(`DELETE FROM focus_sessions WHERE id=$1`, sessionID)
-	return err
+// DELETE: remove a session by ID along with its posts, returning the storage
+// keys of media files no longer used by any post
+func DeleteSession(db *sql.DB, sessionID int) ([]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The session's posts and their media would cascade, but releasing the
+	// media tells us which files are now unused
+	unused, err := deleteMediaWhere(tx, `post_id IN (SELECT id FROM posts WHERE session_id=$1)`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM focus_sessions WHERE id=$1`, sessionID); err != nil {
+		return nil, err
+	}
+	return unused, tx.Commit()
 }
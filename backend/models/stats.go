@@ -0,0 +1,39 @@
+package models
+
+import "database/sql"
+
+// SystemStats is a snapshot of usage across the whole system
+type SystemStats struct {
+	Users            int `json:"users"`
+	SuspendedUsers   int `json:"suspended_users"`
+	ActiveUsers7d    int `json:"active_users_7d"` // users with a focus session in the last 7 days
+	Sessions         int `json:"sessions"`
+	FocusMinutes     int `json:"focus_minutes"`
+	Posts            int `json:"posts"`
+	PublicPosts      int `json:"public_posts"`
+	Comments         int `json:"comments"`
+	OpenReports      int `json:"open_reports"`
+	StudyGroups      int `json:"study_groups"`
+	ActiveChallenges int `json:"active_challenges"`
+}
+
+// READ: count users, activity and content across the system
+func GetSystemStats(db *sql.DB) (SystemStats, error) {
+	var s SystemStats
+	err := db.QueryRow(
+		`SELECT
+		     (SELECT COUNT(*) FROM users),
+		     (SELECT COUNT(*) FROM users WHERE suspended_at IS NOT NULL),
+		     (SELECT COUNT(DISTINCT user_id) FROM focus_sessions WHERE start_time >= NOW() - INTERVAL '7 days'),
+		     (SELECT COUNT(*) FROM focus_sessions),
+		     (SELECT COALESCE(SUM(duration_minutes), 0) FROM focus_sessions),
+		     (SELECT COUNT(*) FROM posts WHERE status = 'published'),
+		     (SELECT COUNT(*) FROM posts WHERE status = 'published' AND visibility = 'public'),
+		     (SELECT COUNT(*) FROM comments),
+		     (SELECT COUNT(*) FROM reports WHERE status = 'open'),
+		     (SELECT COUNT(*) FROM study_groups),
+		     (SELECT COUNT(*) FROM challenges WHERE starts_at <= NOW() AND ends_at > NOW())`,
+	).Scan(&s.Users, &s.SuspendedUsers, &s.ActiveUsers7d, &s.Sessions, &s.FocusMinutes, &s.Posts,
+		&s.PublicPosts, &s.Comments, &s.OpenReports, &s.StudyGroups, &s.ActiveChallenges)
+	return s, err
+}
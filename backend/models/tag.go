@@ -0,0 +1,253 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxTagLength is the longest tag name allowed, in characters
+const MaxTagLength = 32
+
+var (
+	ErrEmptyTag      = errors.New("tag cannot be empty")
+	ErrTagTooLong    = errors.New("tag must be 32 characters or less")
+	ErrTagCharacters = errors.New("tags may only contain letters, numbers, '-' and '_'")
+	ErrTagExists     = errors.New("a tag with that name already exists")
+)
+
+// Tag is one of a user's tags with the number of posts using it
+type Tag struct {
+	ID        int    `json:"id"`
+	UserID    int    `json:"user_id"`
+	Name      string `json:"name"`
+	PostCount int    `json:"post_count"`
+}
+
+// NormalizeTag trims and case-folds a tag name, drops a leading '#', and joins
+// words with '-', so "  Deep Work " and "#deep-work" are the same tag.
+func NormalizeTag(name string) (string, error) {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "#")
+	name = strings.ToLower(strings.Join(strings.Fields(name), "-"))
+
+	if name == "" {
+		return "", ErrEmptyTag
+	}
+	if utf8.RuneCountInString(name) > MaxTagLength {
+		return "", ErrTagTooLong
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return "", ErrTagCharacters
+		}
+	}
+	return name, nil
+}
+
+// NormalizeTags normalizes each name and drops duplicates, keeping the first
+// occurrence's position
+func NormalizeTags(names []string) ([]string, error) {
+	seen := make(map[string]bool, len(names))
+	result := make([]string, 0, len(names))
+	for _, n := range names {
+		tag, err := NormalizeTag(n)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	return result, nil
+}
+
+// READ: get a user's tags with usage counts, sorted by name or by count (most used first)
+func GetTagsForUser(db *sql.DB, userID int, sortBy string) ([]Tag, error) {
+	order := "t.name ASC"
+	if sortBy == "count" {
+		order = "post_count DESC, t.name ASC"
+	}
+
+	rows, err := db.Query(
+		`SELECT t.id, t.user_id, t.name, COUNT(pt.post_id) AS post_count
+		 FROM tags t
+		 LEFT JOIN post_tags pt ON pt.tag_id = t.id
+		 WHERE t.user_id=$1
+		 GROUP BY t.id
+		 ORDER BY `+order,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.PostCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// READ: get a tag with its usage count
+func GetTagByID(db *sql.DB, tagID int) (Tag, error) {
+	var t Tag
+	err := db.QueryRow(
+		`SELECT t.id, t.user_id, t.name, (SELECT COUNT(*) FROM post_tags pt WHERE pt.tag_id = t.id)
+		 FROM tags t
+		 WHERE t.id=$1`,
+		tagID,
+	).Scan(&t.ID, &t.UserID, &t.Name, &t.PostCount)
+	return t, err
+}
+
+// READ: get a user's tag by (normalized) name
+func GetTagByName(db *sql.DB, userID int, name string) (Tag, error) {
+	var t Tag
+	err := db.QueryRow(
+		`SELECT t.id, t.user_id, t.name, (SELECT COUNT(*) FROM post_tags pt WHERE pt.tag_id = t.id)
+		 FROM tags t
+		 WHERE t.user_id=$1 AND t.name=$2`,
+		userID, name,
+	).Scan(&t.ID, &t.UserID, &t.Name, &t.PostCount)
+	return t, err
+}
+
+// UPDATE: rename a tag. The new name must already be normalized. Returns
+// ErrTagExists if the user already has a tag with that name.
+func RenameTag(db *sql.DB, tagID int, name string) error {
+	_, err := db.Exec(`UPDATE tags SET name=$1 WHERE id=$2`, name, tagID)
+	if err != nil && strings.Contains(err.Error(), "duplicate key") {
+		return ErrTagExists
+	}
+	return err
+}
+
+// UPDATE: move every post from one tag to another and delete the source tag.
+// Posts that already have both tags keep a single link to the target.
+func MergeTags(db *sql.DB, sourceID, targetID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := mergeTags(tx, sourceID, targetID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func mergeTags(db DBTX, sourceID, targetID int) error {
+	if _, err := db.Exec(
+		`INSERT INTO post_tags (post_id, tag_id)
+		 SELECT post_id, $2 FROM post_tags WHERE tag_id=$1
+		 ON CONFLICT DO NOTHING`,
+		sourceID, targetID,
+	); err != nil {
+		return err
+	}
+
+	// post_tags rows for the source cascade delete
+	_, err := db.Exec(`DELETE FROM tags WHERE id=$1`, sourceID)
+	return err
+}
+
+// TagCleanup reports what NormalizeExistingTags changed
+type TagCleanup struct {
+	Renamed int      // tags whose name was rewritten in normalized form
+	Merged  int      // tags folded into another tag with the same normalized name
+	Invalid []string // names that cannot be normalized, left untouched
+}
+
+// UPDATE: one-off cleanup for tags created before names were normalized.
+// Every tag is renamed to its normalized form; when several of a user's tags
+// normalize to the same name they are merged into one, preferring the tag that
+// already has that name, then the oldest. Runs in a single transaction.
+func NormalizeExistingTags(db *sql.DB) (TagCleanup, error) {
+	var result TagCleanup
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, user_id, name FROM tags ORDER BY user_id, id FOR UPDATE`)
+	if err != nil {
+		return result, err
+	}
+	type group struct {
+		userID int
+		name   string
+		tags   []Tag
+	}
+	var groups []*group
+	byKey := make(map[string]*group)
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name); err != nil {
+			rows.Close()
+			return result, err
+		}
+		name, err := NormalizeTag(t.Name)
+		if err != nil {
+			result.Invalid = append(result.Invalid, t.Name)
+			continue
+		}
+		key := strconv.Itoa(t.UserID) + "/" + name
+		g := byKey[key]
+		if g == nil {
+			g = &group{userID: t.UserID, name: name}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.tags = append(g.tags, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	for _, g := range groups {
+		target := g.tags[0]
+		for _, t := range g.tags {
+			if t.Name == g.name {
+				target = t
+				break
+			}
+		}
+		for _, t := range g.tags {
+			if t.ID == target.ID {
+				continue
+			}
+			if err := mergeTags(tx, t.ID, target.ID); err != nil {
+				return result, err
+			}
+			result.Merged++
+		}
+		if target.Name != g.name {
+			if _, err := tx.Exec(`UPDATE tags SET name=$1 WHERE id=$2`, g.name, target.ID); err != nil {
+				return result, err
+			}
+			result.Renamed++
+		}
+	}
+
+	return result, tx.Commit()
+}
+
+// DELETE: remove a tag (it is detached from every post)
+func DeleteTag(db *sql.DB, tagID int) error {
+	_, err := db.Exec(`DELETE FROM tags WHERE id=$1`, tagID)
+	return err
+}
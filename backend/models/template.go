@@ -0,0 +1,309 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// QuestionTypes are the ways a template question can be answered
+var QuestionTypes = []string{"text", "scale", "yes_no"}
+
+// MaxAnswerLength caps text answers, in bytes
+const MaxAnswerLength = 5000
+
+var ErrUnknownQuestion = errors.New("answer does not match a question in the template")
+
+// TemplateQuestion is one prompt in a reflection template
+type TemplateQuestion struct {
+	ID         int    `json:"id"`
+	TemplateID int    `json:"template_id"`
+	Position   int    `json:"position"`
+	Prompt     string `json:"prompt"`
+	Type       string `json:"type"` // 'text', 'scale' (1-5) or 'yes_no'
+}
+
+// ReflectionTemplate is a set of questions a post can be written from. Built-in
+// templates have no owner.
+type ReflectionTemplate struct {
+	ID          int                `json:"id"`
+	UserID      *int               `json:"user_id,omitempty"`
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	BuiltIn     bool               `json:"built_in"`
+	Questions   []TemplateQuestion `json:"questions"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+// PostAnswer is a post's answer to one template question. Exactly one of Text,
+// Scale and YesNo is set, matching Type.
+type PostAnswer struct {
+	QuestionID *int    `json:"question_id"` // NULL once the template is deleted
+	Prompt     string  `json:"prompt"`
+	Type       string  `json:"type"`
+	Text       *string `json:"text,omitempty"`
+	Scale      *int    `json:"scale,omitempty"`
+	YesNo      *bool   `json:"yes_no,omitempty"`
+}
+
+// VisibleTo reports whether userID may use the template
+func (t ReflectionTemplate) VisibleTo(userID int) bool {
+	return t.UserID == nil || *t.UserID == userID
+}
+
+// ValidateAnswers checks answers against the template's questions and fills in
+// each answer's prompt and type. Questions may be left unanswered.
+func ValidateAnswers(t ReflectionTemplate, answers []PostAnswer) ([]PostAnswer, error) {
+	questions := make(map[int]TemplateQuestion, len(t.Questions))
+	for _, q := range t.Questions {
+		questions[q.ID] = q
+	}
+
+	seen := make(map[int]bool, len(answers))
+	result := make([]PostAnswer, 0, len(answers))
+	for _, a := range answers {
+		if a.QuestionID == nil {
+			return nil, ErrUnknownQuestion
+		}
+		q, ok := questions[*a.QuestionID]
+		if !ok {
+			return nil, ErrUnknownQuestion
+		}
+		if seen[q.ID] {
+			return nil, fmt.Errorf("question %d is answered more than once", q.ID)
+		}
+		seen[q.ID] = true
+
+		var set int
+		for _, present := range []bool{a.Text != nil, a.Scale != nil, a.YesNo != nil} {
+			if present {
+				set++
+			}
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("answer to question %d must set exactly one of text, scale or yes_no", q.ID)
+		}
+
+		switch q.Type {
+		case "text":
+			if a.Text == nil {
+				return nil, fmt.Errorf("question %d needs a text answer", q.ID)
+			}
+			text := strings.TrimSpace(*a.Text)
+			if text == "" {
+				// Skipped question
+				continue
+			}
+			if len(text) > MaxAnswerLength {
+				return nil, fmt.Errorf("answer to question %d must be %d characters or less", q.ID, MaxAnswerLength)
+			}
+			a.Text = &text
+		case "scale":
+			if a.Scale == nil || *a.Scale < 1 || *a.Scale > 5 {
+				return nil, fmt.Errorf("question %d needs a scale answer between 1 and 5", q.ID)
+			}
+		case "yes_no":
+			if a.YesNo == nil {
+				return nil, fmt.Errorf("question %d needs a yes_no answer", q.ID)
+			}
+		}
+
+		a.Prompt = q.Prompt
+		a.Type = q.Type
+		result = append(result, a)
+	}
+	return result, nil
+}
+
+const templateColumns = `id, user_id, name, COALESCE(description, ''), created_at`
+
+func scanTemplate(row rowScanner) (ReflectionTemplate, error) {
+	var t ReflectionTemplate
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Description, &t.CreatedAt)
+	t.BuiltIn = t.UserID == nil
+	t.Questions = []TemplateQuestion{}
+	return t, err
+}
+
+// CREATE: insert a template with its questions (in the given order)
+func CreateTemplate(db *sql.DB, t ReflectionTemplate) (ReflectionTemplate, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return ReflectionTemplate{}, err
+	}
+	defer tx.Rollback()
+
+	created, err := scanTemplate(tx.QueryRow(
+		`INSERT INTO reflection_templates (user_id, name, description, created_at)
+		 VALUES ($1, $2, $3, NOW())
+		 RETURNING `+templateColumns,
+		t.UserID, t.Name, t.Description,
+	))
+	if err != nil {
+		return ReflectionTemplate{}, err
+	}
+
+	for i, q := range t.Questions {
+		q.TemplateID = created.ID
+		q.Position = i
+		if err := tx.QueryRow(
+			`INSERT INTO template_questions (template_id, position, prompt, question_type)
+			 VALUES ($1, $2, $3, $4)
+			 RETURNING id`,
+			q.TemplateID, q.Position, q.Prompt, q.Type,
+		).Scan(&q.ID); err != nil {
+			return ReflectionTemplate{}, err
+		}
+		created.Questions = append(created.Questions, q)
+	}
+
+	return created, tx.Commit()
+}
+
+// READ: get a template with its questions
+func GetTemplateByID(db *sql.DB, templateID int) (ReflectionTemplate, error) {
+	t, err := scanTemplate(db.QueryRow(
+		`SELECT `+templateColumns+`
+		 FROM reflection_templates
+		 WHERE id=$1`,
+		templateID,
+	))
+	if err != nil {
+		return ReflectionTemplate{}, err
+	}
+
+	templates := []ReflectionTemplate{t}
+	if err := loadTemplateQuestions(db, templates); err != nil {
+		return ReflectionTemplate{}, err
+	}
+	return templates[0], nil
+}
+
+// READ: get the built-in templates followed by the user's own, with questions
+func GetTemplatesForUser(db *sql.DB, userID int) ([]ReflectionTemplate, error) {
+	rows, err := db.Query(
+		`SELECT `+templateColumns+`
+		 FROM reflection_templates
+		 WHERE user_id IS NULL OR user_id=$1
+		 ORDER BY user_id NULLS FIRST, name ASC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []ReflectionTemplate{}
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return templates, loadTemplateQuestions(db, templates)
+}
+
+// loadTemplateQuestions fills in Questions for each template
+func loadTemplateQuestions(db *sql.DB, templates []ReflectionTemplate) error {
+	for i := range templates {
+		rows, err := db.Query(
+			`SELECT id, template_id, position, prompt, question_type
+			 FROM template_questions
+			 WHERE template_id=$1
+			 ORDER BY position ASC`,
+			templates[i].ID,
+		)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var q TemplateQuestion
+			if err := rows.Scan(&q.ID, &q.TemplateID, &q.Position, &q.Prompt, &q.Type); err != nil {
+				rows.Close()
+				return err
+			}
+			templates[i].Questions = append(templates[i].Questions, q)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DELETE: remove a template. Posts keep their answers (with the question text).
+func DeleteTemplate(db *sql.DB, templateID int) error {
+	_, err := db.Exec(`DELETE FROM reflection_templates WHERE id=$1`, templateID)
+	return err
+}
+
+// UPDATE: replace a post's answers. Answers must come from ValidateAnswers.
+// Run it in a transaction (see CreatePostWithTags and UpdatePostWithTags) so
+// the old answers are never removed without the new ones being saved.
+func SetPostAnswers(db DBTX, postID int, answers []PostAnswer) error {
+	if _, err := db.Exec(`DELETE FROM post_answers WHERE post_id=$1`, postID); err != nil {
+		return err
+	}
+
+	for _, a := range answers {
+		if _, err := db.Exec(
+			`INSERT INTO post_answers (post_id, question_id, prompt, question_type, text_answer, scale_answer, yes_no_answer)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			postID, a.QuestionID, a.Prompt, a.Type, a.Text, a.Scale, a.YesNo,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// READ: get a post's answers in question order
+func GetAnswersForPost(db *sql.DB, postID int) ([]PostAnswer, error) {
+	answers, err := GetAnswersForPosts(db, []int{postID})
+	if err != nil {
+		return nil, err
+	}
+	if answers[postID] == nil {
+		return []PostAnswer{}, nil
+	}
+	return answers[postID], nil
+}
+
+// READ: GetAnswersForPost for several posts in one query, keyed by post ID.
+// Posts without answers are left out of the map.
+func GetAnswersForPosts(db *sql.DB, postIDs []int) (map[int][]PostAnswer, error) {
+	rows, err := db.Query(
+		`SELECT a.post_id, a.question_id, a.prompt, a.question_type, a.text_answer, a.scale_answer, a.yes_no_answer
+		 FROM post_answers a
+		 LEFT JOIN template_questions q ON q.id = a.question_id
+		 WHERE a.post_id = ANY($1)
+		 ORDER BY q.position ASC NULLS LAST, a.id ASC`,
+		pq.Array(postIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	answers := map[int][]PostAnswer{}
+	for rows.Next() {
+		var postID int
+		var a PostAnswer
+		if err := rows.Scan(&postID, &a.QuestionID, &a.Prompt, &a.Type, &a.Text, &a.Scale, &a.YesNo); err != nil {
+			return nil, err
+		}
+		answers[postID] = append(answers[postID], a)
+	}
+	return answers, rows.Err()
+}
@@ -7,74 +7,75 @@ import (
 
 // User represents a user in the database
 type User struct {
-	ID          int       `json:"id"`
-	Email       string    `json:"email"`
-	Username    string    `json:"username,omitempty"`
-	GoogleID    string    `json:"google_id"`
-	DisplayName string    `json:"display_name,omitempty"`
-	PictureURL  string    `json:"picture_url,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID             int        `json:"id"`
+	Email          string     `json:"email"`
+	Username       string     `json:"username,omitempty"`
+	GoogleID       string     `json:"google_id"`
+	DisplayName    string     `json:"display_name,omitempty"`
+	PictureURL     string     `json:"picture_url,omitempty"`
+	Role           string     `json:"role"`                   // 'user', 'moderator' or 'admin'
+	SuspendedAt    *time.Time `json:"suspended_at,omitempty"` // set while an admin has suspended the account
+	SearchLanguage string     `json:"search_language"`        // text search configuration, e.g. 'english'
+	Timezone       string     `json:"timezone"`               // IANA name, e.g. 'Europe/Berlin'
+	CreatedAt      time.Time  `json:"created_at"`
 }
 
-// CREATE: inserts a new blank user with only Google ID and email
-func CreateUser(db *sql.DB, googleID, email string) (User, error) {
+const userColumns = `id, email, username, google_id, display_name, picture_url, role, suspended_at, search_language, timezone, created_at`
+
+func scanUser(row rowScanner) (User, error) {
 	var user User
+	err := row.Scan(&user.ID, &user.Email, &user.Username, &user.GoogleID, &user.DisplayName, &user.PictureURL, &user.Role, &user.SuspendedAt, &user.SearchLanguage, &user.Timezone, &user.CreatedAt)
+	return user, err
+}
 
-	err := db.QueryRow(
+// CREATE: inserts a new blank user with only Google ID and email
+func CreateUser(db *sql.DB, googleID, email string) (User, error) {
+	return scanUser(db.QueryRow(
 		`INSERT INTO users (email, google_id, created_at)
 		 VALUES ($1, $2, NOW())
-		 RETURNING id, email, username, google_id, display_name, picture_url, created_at`,
+		 RETURNING `+userColumns,
 		email, googleID,
-	).Scan(&user.ID, &user.Email, &user.Username, &user.GoogleID, &user.DisplayName, &user.PictureURL, &user.CreatedAt)
-	return user, err
+	))
 }
 
 // READ: fetch a user by email
 func GetUserByEmail(db *sql.DB, email string) (User, error) {
-	var user User
-	err := db.QueryRow(
-		`SELECT id, email, username, google_id, display_name, picture_url, created_at
+	return scanUser(db.QueryRow(
+		`SELECT `+userColumns+`
 		 FROM users
 		 WHERE email=$1`,
 		email,
-	).Scan(&user.ID, &user.Email, &user.Username, &user.GoogleID, &user.DisplayName, &user.PictureURL, &user.CreatedAt)
-	return user, err
+	))
 }
 
 // READ: fetch a user by Google ID
 func GetUserByGoogleID(db *sql.DB, googleID string) (User, error) {
-	var user User
-	err := db.QueryRow(
-		`SELECT id, email, username, google_id, display_name, picture_url, created_at
+	return scanUser(db.QueryRow(
+		`SELECT `+userColumns+`
 		 FROM users
 		 WHERE google_id=$1`,
 		googleID,
-	).Scan(&user.ID, &user.Email, &user.Username, &user.GoogleID, &user.DisplayName, &user.PictureURL, &user.CreatedAt)
-	return user, err
+	))
 }
 
 // READ: fetch a user by ID
 func GetUserByID(db *sql.DB, id int) (User, error) {
-	var user User
-	err := db.QueryRow(
-		`SELECT id, email, username, google_id, display_name, picture_url, created_at
+	return scanUser(db.QueryRow(
+		`SELECT `+userColumns+`
 		 FROM users
 		 WHERE id=$1`,
 		id,
-	).Scan(&user.ID, &user.Email, &user.Username, &user.GoogleID, &user.DisplayName, &user.PictureURL, &user.CreatedAt)
-	return user, err
+	))
 }
 
 // READ: fetch a user by username
 func GetUserByUsername(db *sql.DB, username string) (User, error) {
-	var user User
-	err := db.QueryRow(
-		`SELECT id, email, username, google_id, display_name, picture_url, created_at
+	return scanUser(db.QueryRow(
+		`SELECT `+userColumns+`
 		 FROM users
 		 WHERE username=$1`,
 		username,
-	).Scan(&user.ID, &user.Email, &user.Username, &user.GoogleID, &user.DisplayName, &user.PictureURL, &user.CreatedAt)
-	return user, err
+	))
 }
 
 // READ: check if a username already exists
@@ -98,6 +99,31 @@ func UpdateProfile(db *sql.DB, id int, username, displayName, pictureURL string)
 	return err
 }
 
+// UPDATE: change the text search language for a user and re-index their posts
+func SetSearchLanguage(db *sql.DB, id int, language string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE users SET search_language=$1 WHERE id=$2`, language, id); err != nil {
+		return err
+	}
+	// search_vector is generated, so this rebuilds it
+	if _, err := tx.Exec(`UPDATE posts SET search_language=$1 WHERE user_id=$2`, language, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UPDATE: change the timezone journal dates and daily views use
+func SetTimezone(db *sql.DB, id int, timezone string) error {
+	_, err := db.Exec(`UPDATE users SET timezone=$1 WHERE id=$2`, timezone, id)
+	return err
+}
+
 // UPDATE: update user's email
 func UpdateUserEmail(db *sql.DB, id int, newEmail string) error {
 	_, err := db.Exec(
@@ -142,8 +168,106 @@ func UpdateDisplayName(db *sql.DB, id int, displayName string) error {
 	return err
 }
 
-// DELETE: remove user by ID
-func DeleteUser(db *sql.DB, id int) error {
-	_, err := db.Exec(`DELETE FROM users WHERE id=$1`, id)
+// UPDATE: suspend or unsuspend a user (suspended=false clears the suspension)
+func SetUserSuspended(db *sql.DB, id int, suspended bool) error {
+	_, err := db.Exec(
+		`UPDATE users
+		 SET suspended_at = CASE WHEN $1 THEN COALESCE(suspended_at, NOW()) ELSE NULL END
+		 WHERE id=$2`,
+		suspended, id,
+	)
 	return err
 }
+
+// UPDATE: change a user's role ('user', 'moderator' or 'admin')
+func SetUserRole(db *sql.DB, id int, role string) error {
+	_, err := db.Exec(`UPDATE users SET role=$1 WHERE id=$2`, role, id)
+	return err
+}
+
+// READ: find users whose email or username starts with query (case-insensitive)
+func FindUsers(db *sql.DB, query string, limit int) ([]User, error) {
+	rows, err := db.Query(
+		`SELECT `+userColumns+`
+		 FROM users
+		 WHERE email ILIKE $1 || '%' OR username ILIKE $1 || '%'
+		 ORDER BY id ASC
+		 LIMIT $2`,
+		query, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// READ: list users with a role, e.g. every admin
+func GetUsersByRole(db *sql.DB, role string) ([]User, error) {
+	rows, err := db.Query(
+		`SELECT `+userColumns+`
+		 FROM users
+		 WHERE role=$1
+		 ORDER BY id ASC`,
+		role,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// READ: check whether a user's content should be hidden from a viewer, because
+// the user is suspended or one of them has blocked the other
+func IsHiddenFrom(db *sql.DB, userID, viewerID int) (bool, error) {
+	if userID == viewerID {
+		return false, nil
+	}
+	var hidden bool
+	err := db.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM users WHERE id=$1 AND suspended_at IS NOT NULL)
+		     OR `+blockedBetween("$1", "$2"),
+		userID, viewerID,
+	).Scan(&hidden)
+	return hidden, err
+}
+
+// DELETE: remove user by ID along with everything they own, returning the
+// storage keys of their media files
+func DeleteUser(db *sql.DB, id int) ([]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Media and its objects would cascade, but releasing them tells us which
+	// files to delete
+	unused, err := deleteMediaWhere(tx, `user_id=$1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM users WHERE id=$1`, id); err != nil {
+		return nil, err
+	}
+	return unused, tx.Commit()
+}
@@ -0,0 +1,222 @@
+// Package notifications turns domain events into stored, per-user
+// notifications. Adding a new notification means adding a producer here and
+// subscribing it in Register; handlers only publish events.
+package notifications
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"tomo/backend/events"
+	"tomo/backend/models"
+)
+
+// producer maps an event to zero or more notifications
+type producer func(db *sql.DB, e events.Event) ([]pending, error)
+
+// pending is a notification a producer wants delivered
+type pending struct {
+	RecipientID int
+	Type        string // notification_type
+	PostID      *int
+	CommentID   *int
+	GroupKey    string // notifications sharing a key are grouped together
+}
+
+var producers = map[events.Type]producer{
+	events.CommentCreated: commentProducer,
+	events.UserFollowed:   followProducer,
+	events.PostReacted:    reactionProducer,
+	events.GoalCompleted:  goalProducer,
+	events.PostMentioned:  mentionProducer,
+	events.PostPublished:  publishedProducer,
+}
+
+// Register subscribes every notification producer to the bus. Each stored
+// notification is announced with a NotificationCreated event.
+func Register(bus *events.Bus, db *sql.DB) {
+	for eventType, produce := range producers {
+		bus.Subscribe(eventType, func(e events.Event) {
+			deliver(bus, db, e, produce)
+		})
+	}
+}
+
+// deliver stores the notifications a producer returns, skipping self-notifications,
+// actors blocked by (or blocking) the recipient, and types the recipient has switched off
+func deliver(bus *events.Bus, db *sql.DB, e events.Event, produce producer) {
+	list, err := produce(db, e)
+	if err != nil {
+		log.Printf("notifications: failed to handle %s: %v", e.Type, err)
+		return
+	}
+
+	var actorID *int
+	if e.ActorID != 0 {
+		actorID = &e.ActorID
+	}
+
+	for _, p := range list {
+		if p.RecipientID == 0 || p.RecipientID == e.ActorID {
+			continue
+		}
+
+		if e.ActorID != 0 {
+			blocked, err := models.IsBlockedEitherWay(db, p.RecipientID, e.ActorID)
+			if err != nil {
+				log.Printf("notifications: failed to check blocks for user %d: %v", p.RecipientID, err)
+				continue
+			}
+			if blocked {
+				continue
+			}
+		}
+
+		enabled, err := models.NotificationEnabled(db, p.RecipientID, p.Type)
+		if err != nil {
+			log.Printf("notifications: failed to read preferences for user %d: %v", p.RecipientID, err)
+			continue
+		}
+		if !enabled {
+			continue
+		}
+
+		n, err := models.CreateNotification(db, p.RecipientID, actorID, p.Type, p.PostID, p.CommentID, p.GroupKey)
+		if err != nil {
+			log.Printf("notifications: failed to store %s for user %d: %v", p.Type, p.RecipientID, err)
+			continue
+		}
+
+		bus.Publish(events.Event{
+			Type:     events.NotificationCreated,
+			ActorID:  e.ActorID,
+			UserID:   n.UserID,
+			TargetID: n.ID,
+		})
+	}
+}
+
+// commentProducer notifies the post owner about comments, and the parent
+// comment's author about replies
+func commentProducer(db *sql.DB, e events.Event) ([]pending, error) {
+	comment, err := models.GetCommentByID(db, e.CommentID)
+	if err != nil {
+		return nil, err
+	}
+	post, err := models.GetPostByID(db, comment.PostID)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []pending
+	replyTo := 0
+	if comment.ParentID != nil {
+		parent, err := models.GetCommentByID(db, *comment.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		replyTo = parent.UserID
+		list = append(list, pending{
+			RecipientID: parent.UserID,
+			Type:        "reply",
+			PostID:      &post.ID,
+			CommentID:   &comment.ID,
+			GroupKey:    fmt.Sprintf("comment:%d", parent.ID),
+		})
+	}
+
+	// The post owner already hears about replies to their own comments
+	if post.UserID != replyTo {
+		list = append(list, pending{
+			RecipientID: post.UserID,
+			Type:        "comment",
+			PostID:      &post.ID,
+			CommentID:   &comment.ID,
+			GroupKey:    fmt.Sprintf("post:%d", post.ID),
+		})
+	}
+
+	return list, nil
+}
+
+// followProducer notifies a user about new followers
+func followProducer(db *sql.DB, e events.Event) ([]pending, error) {
+	return []pending{{
+		RecipientID: e.UserID,
+		Type:        "follow",
+		GroupKey:    "followers",
+	}}, nil
+}
+
+// reactionProducer notifies the post owner about reactions
+func reactionProducer(db *sql.DB, e events.Event) ([]pending, error) {
+	post, err := models.GetPostByID(db, e.PostID)
+	if err != nil {
+		return nil, err
+	}
+	return []pending{{
+		RecipientID: post.UserID,
+		Type:        "reaction",
+		PostID:      &post.ID,
+		GroupKey:    fmt.Sprintf("post:%d", post.ID),
+	}}, nil
+}
+
+// goalProducer notifies a user that a goal was completed
+func goalProducer(db *sql.DB, e events.Event) ([]pending, error) {
+	return []pending{{
+		RecipientID: e.UserID,
+		Type:        "goal_completed",
+		GroupKey:    fmt.Sprintf("goal:%d", e.TargetID),
+	}}, nil
+}
+
+// mentionProducer notifies a user that they were mentioned in a post
+func mentionProducer(db *sql.DB, e events.Event) ([]pending, error) {
+	return []pending{{
+		RecipientID: e.UserID,
+		Type:        "mention",
+		PostID:      &e.PostID,
+		GroupKey:    fmt.Sprintf("mention:%d", e.PostID),
+	}}, nil
+}
+
+// publishedProducer notifies the users mentioned in a post that was just
+// published, now that they can see it. Each mention is only ever notified once.
+func publishedProducer(db *sql.DB, e events.Event) ([]pending, error) {
+	post, err := models.GetPostByID(db, e.PostID)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs, err := models.GetUnnotifiedMentions(db, post.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []pending
+	for _, userID := range userIDs {
+		visible, err := models.CanViewPost(db, post, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !visible {
+			continue
+		}
+
+		claimed, err := models.ClaimMentionNotification(db, post.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if claimed {
+			list = append(list, pending{
+				RecipientID: userID,
+				Type:        "mention",
+				PostID:      &post.ID,
+				GroupKey:    fmt.Sprintf("mention:%d", post.ID),
+			})
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,232 @@
+// Package realtime pushes events to every connected client of a user.
+//
+// Broker is the extension point: Hub fans out within a single process, and a
+// Postgres LISTEN/NOTIFY backed Broker can replace it when the API runs on
+// multiple instances without changing any publisher or the /events handler.
+package realtime
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const (
+	// historySize is how many recent messages per user are kept for Last-Event-ID resume
+	historySize = 100
+	// DefaultHistoryMaxAge is how long messages are kept for resume. Older
+	// messages are dropped, and so is the history of users with none left,
+	// so users who never reconnect don't keep memory allocated.
+	DefaultHistoryMaxAge = 10 * time.Minute
+	// clientBuffer is how many undelivered messages a slow client may queue
+	// before it is disconnected (it will resume with Last-Event-ID)
+	clientBuffer = 32
+)
+
+// Message is a single event delivered to a user's clients
+type Message struct {
+	ID    int64           `json:"id"`
+	Event string          `json:"event"` // e.g. "notification", "session.created"
+	Data  json.RawMessage `json:"data"`
+
+	at time.Time // when it was published, for expiring history
+}
+
+// Broker delivers messages to all of a user's connected clients
+type Broker interface {
+	// Publish sends an event with a JSON-encodable payload to a user
+	Publish(userID int, event string, data interface{}) error
+	// Subscribe registers a new client. Messages newer than lastEventID that
+	// are still buffered are returned in Subscription.Replay.
+	Subscribe(userID int, lastEventID int64) *Subscription
+	// Users lists the users with at least one client connected to this
+	// broker, for events that fan out beyond a single user (feed updates)
+	Users() []int
+}
+
+// Subscription is one connected client
+type Subscription struct {
+	// C receives live messages. It is closed when the subscription ends,
+	// including when the client falls too far behind.
+	C <-chan Message
+	// Replay holds buffered messages the client missed since lastEventID
+	Replay []Message
+	// Gap is true when messages after lastEventID have already been dropped,
+	// meaning some events were lost and the client should refetch its state
+	Gap bool
+
+	close func()
+}
+
+// Close unsubscribes the client. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.close()
+}
+
+// Hub is an in-process Broker
+type Hub struct {
+	// HistoryMaxAge is how long messages stay available for resume
+	HistoryMaxAge time.Duration
+
+	mu        sync.Mutex
+	startID   int64
+	nextID    int64
+	clients   map[int]map[chan Message]struct{}
+	history   map[int]*userHistory
+	evicted   int64 // newest message ID dropped along with a whole user's history
+	lastSweep time.Time
+}
+
+// userHistory is a user's recent messages
+type userHistory struct {
+	msgs    []Message
+	dropped int64 // newest message ID no longer in msgs; older IDs can't be replayed
+}
+
+func NewHub() *Hub {
+	// Seed IDs from the clock so they keep increasing across restarts
+	now := time.Now()
+	start := now.UnixMicro()
+	return &Hub{
+		HistoryMaxAge: DefaultHistoryMaxAge,
+		startID:       start,
+		nextID:        start,
+		clients:       make(map[int]map[chan Message]struct{}),
+		history:       make(map[int]*userHistory),
+		lastSweep:     now,
+	}
+}
+
+func (h *Hub) Publish(userID int, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	h.nextID++
+	msg := Message{ID: h.nextID, Event: event, Data: payload, at: now}
+
+	hist, ok := h.history[userID]
+	if !ok {
+		// Anything this user was sent before may have been evicted
+		hist = &userHistory{dropped: h.evicted}
+		h.history[userID] = hist
+	}
+	hist.msgs = append(hist.msgs, msg)
+	if len(hist.msgs) > historySize {
+		hist.dropped = hist.msgs[len(hist.msgs)-historySize-1].ID
+		hist.msgs = hist.msgs[len(hist.msgs)-historySize:]
+	}
+
+	// Expire old history at most once per HistoryMaxAge
+	if now.Sub(h.lastSweep) >= h.HistoryMaxAge {
+		h.sweepLocked(now)
+	}
+
+	for ch := range h.clients[userID] {
+		select {
+		case ch <- msg:
+		default:
+			// Client is not keeping up; drop it so it reconnects and resumes
+			h.removeLocked(userID, ch)
+		}
+	}
+
+	return nil
+}
+
+func (h *Hub) Subscribe(userID int, lastEventID int64) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan Message, clientBuffer)
+	if h.clients[userID] == nil {
+		h.clients[userID] = make(map[chan Message]struct{})
+	}
+	h.clients[userID][ch] = struct{}{}
+
+	sub := &Subscription{C: ch}
+	if lastEventID > 0 {
+		hist, ok := h.history[userID]
+		if !ok {
+			hist = &userHistory{dropped: h.evicted}
+		}
+		hist.expire(time.Now().Add(-h.HistoryMaxAge))
+		// Events were lost if they came from a previous process or have
+		// already been dropped from the history
+		if lastEventID < h.startID || lastEventID < hist.dropped {
+			sub.Gap = true
+		}
+		for _, msg := range hist.msgs {
+			if msg.ID > lastEventID {
+				sub.Replay = append(sub.Replay, msg)
+			}
+		}
+	}
+
+	var once sync.Once
+	sub.close = func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			h.removeLocked(userID, ch)
+		})
+	}
+
+	return sub
+}
+
+func (h *Hub) Users() []int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	users := make([]int, 0, len(h.clients))
+	for userID := range h.clients {
+		users = append(users, userID)
+	}
+	return users
+}
+
+// sweepLocked expires old messages and forgets users with none left; h.mu must be held
+func (h *Hub) sweepLocked(now time.Time) {
+	h.lastSweep = now
+	cutoff := now.Add(-h.HistoryMaxAge)
+	for userID, hist := range h.history {
+		hist.expire(cutoff)
+		if len(hist.msgs) == 0 {
+			if hist.dropped > h.evicted {
+				h.evicted = hist.dropped
+			}
+			delete(h.history, userID)
+		}
+	}
+}
+
+// expire drops messages published before cutoff
+func (hist *userHistory) expire(cutoff time.Time) {
+	n := 0
+	for n < len(hist.msgs) && hist.msgs[n].at.Before(cutoff) {
+		n++
+	}
+	if n > 0 {
+		hist.dropped = hist.msgs[n-1].ID
+		hist.msgs = hist.msgs[n:]
+	}
+}
+
+// removeLocked drops a client; h.mu must be held
+func (h *Hub) removeLocked(userID int, ch chan Message) {
+	clients := h.clients[userID]
+	if _, ok := clients[ch]; !ok {
+		return
+	}
+	delete(clients, ch)
+	close(ch)
+	if len(clients) == 0 {
+		delete(h.clients, userID)
+	}
+}
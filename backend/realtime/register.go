@@ -0,0 +1,101 @@
+package realtime
+
+import (
+	"database/sql"
+	"log"
+
+	"tomo/backend/events"
+	"tomo/backend/models"
+)
+
+// Register forwards domain events to the clients of the users they concern
+func Register(bus *events.Bus, broker Broker, db *sql.DB) {
+	// A new notification: send it with the fresh unread count for badges
+	bus.Subscribe(events.NotificationCreated, func(e events.Event) {
+		n, err := models.GetNotificationByID(db, e.TargetID)
+		if err != nil {
+			log.Printf("realtime: failed to load notification %d: %v", e.TargetID, err)
+			return
+		}
+		unread, err := models.CountUnreadNotificationGroups(db, n.UserID)
+		if err != nil {
+			log.Printf("realtime: failed to count notifications for user %d: %v", n.UserID, err)
+			return
+		}
+		publish(broker, n.UserID, "notification", map[string]interface{}{
+			"notification": n,
+			"unread_count": unread,
+		})
+	})
+
+	// Focus sessions are synced to the owner's other devices
+	bus.Subscribe(events.SessionCreated, func(e events.Event) {
+		session, err := models.GetSessionByID(db, e.TargetID)
+		if err != nil {
+			log.Printf("realtime: failed to load session %d: %v", e.TargetID, err)
+			return
+		}
+		publish(broker, session.UserID, string(e.Type), session)
+	})
+	bus.Subscribe(events.SessionDeleted, func(e events.Event) {
+		publish(broker, e.ActorID, string(e.Type), map[string]int{"id": e.TargetID})
+	})
+
+	// Post changes are synced to the author's other devices
+	for _, t := range []events.Type{events.PostCreated, events.PostUpdated, events.PostPublished} {
+		bus.Subscribe(t, func(e events.Event) {
+			post, err := models.GetPostWithDetails(db, e.PostID, e.ActorID)
+			if err != nil {
+				log.Printf("realtime: failed to load post %d: %v", e.PostID, err)
+				return
+			}
+			publish(broker, post.UserID, string(e.Type), post)
+		})
+	}
+	bus.Subscribe(events.PostDeleted, func(e events.Event) {
+		publish(broker, e.ActorID, string(e.Type), map[string]int{"id": e.PostID})
+	})
+
+	// Newly published public posts: tell connected users whose feed shows
+	// the post so they can refresh it
+	for _, t := range []events.Type{events.PostCreated, events.PostPublished} {
+		bus.Subscribe(t, func(e events.Event) {
+			users := broker.Users()
+			if len(users) == 0 {
+				return
+			}
+			audience, err := models.GetFeedAudience(db, e.PostID, users)
+			if err != nil {
+				log.Printf("realtime: failed to find feed audience for post %d: %v", e.PostID, err)
+				return
+			}
+			for _, userID := range audience {
+				publish(broker, userID, "feed.post", map[string]int{"id": e.PostID, "user_id": e.ActorID})
+			}
+		})
+	}
+
+	// A room's shared timer started or stopped: update every member's
+	// active timer, including members who aren't in the room right now
+	bus.Subscribe(events.RoomTimerChanged, func(e events.Event) {
+		room, err := models.GetRoomByID(db, e.TargetID)
+		if err != nil {
+			log.Printf("realtime: failed to load room %d: %v", e.TargetID, err)
+			return
+		}
+		members, err := models.GetRoomMembers(db, room.ID)
+		if err != nil {
+			log.Printf("realtime: failed to load members of room %d: %v", room.ID, err)
+			return
+		}
+		for _, m := range members {
+			publish(broker, m.UserID, "room.timer", room)
+		}
+	})
+}
+
+func publish(broker Broker, userID int, event string, data interface{}) {
+	if err := broker.Publish(userID, event, data); err != nil {
+		log.Printf("realtime: failed to publish %s to user %d: %v", event, userID, err)
+	}
+}
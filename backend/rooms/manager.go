@@ -0,0 +1,520 @@
+// Package rooms runs the live side of co-focus rooms: WebSocket connections,
+// presence, chat and the shared timer. Rooms, members and the timer are stored
+// through models; presence and chat only live in memory.
+package rooms
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"tomo/backend/events"
+	"tomo/backend/models"
+)
+
+const (
+	MaxChatLength      = 500
+	ChatHistorySize    = 50
+	MaxTimerMinutes    = 240
+	clientSendBuffer   = 32
+	minRecordedMinutes = 1
+)
+
+// Participant is a user currently connected to a room
+type Participant struct {
+	UserID      int       `json:"user_id"`
+	Username    string    `json:"username,omitempty"`
+	DisplayName string    `json:"display_name,omitempty"`
+	PictureURL  string    `json:"picture_url,omitempty"`
+	Status      string    `json:"status"` // 'idle', 'focusing' or 'break'
+	Since       time.Time `json:"since"`  // when the user connected
+
+	connections int
+}
+
+// ChatMessage is a message in a room's short-lived chat
+type ChatMessage struct {
+	UserID int       `json:"user_id"`
+	Text   string    `json:"text"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// Timer is a room's running shared timer
+type Timer struct {
+	StartedAt       time.Time `json:"started_at"`
+	DurationMinutes int       `json:"duration_minutes"`
+	EndsAt          time.Time `json:"ends_at"`
+}
+
+// inbound is a message sent by a client:
+//
+//	{"type": "chat", "text": "..."}
+//	{"type": "status", "status": "focusing" | "break" | "idle"}
+//	{"type": "timer.start", "duration_minutes": 25}   (owner only)
+//	{"type": "timer.stop"}                            (owner only)
+type inbound struct {
+	Type            string `json:"type"`
+	Text            string `json:"text,omitempty"`
+	Status          string `json:"status,omitempty"`
+	DurationMinutes int    `json:"duration_minutes,omitempty"`
+}
+
+// outbound is a message sent to clients. Type is one of "state", "presence",
+// "chat", "timer", "session.recorded" or "error".
+type outbound map[string]interface{}
+
+type client struct {
+	userID int
+	conn   *websocket.Conn
+	send   chan outbound
+}
+
+type room struct {
+	id           int
+	ownerID      int
+	clients      map[*client]struct{}
+	participants map[int]*Participant
+	chat         []ChatMessage
+	timer        *Timer
+	timerFunc    *time.Timer
+	timerPending bool // a new timer is being saved
+	closed       bool // set by CloseRoom; a closed room never arms a timer again
+}
+
+// Manager holds every room that has connected clients or a running timer
+type Manager struct {
+	DB     *sql.DB
+	Events *events.Bus
+
+	mu    sync.Mutex
+	rooms map[int]*room
+}
+
+func NewManager(db *sql.DB, bus *events.Bus) *Manager {
+	return &Manager{DB: db, Events: bus, rooms: make(map[int]*room)}
+}
+
+// Serve runs a member's WebSocket connection until it closes
+func (m *Manager) Serve(ws *websocket.Conn, roomModel models.FocusRoom, user models.User) {
+	// The HTTP server's read/write timeouts still apply to the hijacked connection
+	ws.SetDeadline(time.Time{})
+
+	// The timer ran out while no one was connected (e.g. across a restart)
+	if started, minutes := roomModel.TimerStartedAt, roomModel.TimerDurationMinutes; started != nil && minutes != nil &&
+		!time.Now().Before(started.Add(time.Duration(*minutes)*time.Minute)) {
+		if err := models.ClearRoomTimer(m.DB, roomModel.ID, *started); err != nil {
+			log.Printf("rooms: failed to clear expired timer for room %d: %v", roomModel.ID, err)
+		}
+	}
+
+	c := &client{userID: user.ID, conn: ws, send: make(chan outbound, clientSendBuffer)}
+	go c.writeLoop()
+
+	m.mu.Lock()
+	r := m.loadLocked(roomModel)
+	r.clients[c] = struct{}{}
+	p, ok := r.participants[user.ID]
+	if !ok {
+		p = &Participant{
+			UserID:      user.ID,
+			Username:    user.Username,
+			DisplayName: user.DisplayName,
+			PictureURL:  user.PictureURL,
+			Status:      "idle",
+			Since:       time.Now(),
+		}
+		if r.timer != nil {
+			p.Status = "focusing"
+		}
+		r.participants[user.ID] = p
+	}
+	p.connections++
+	c.trySend(outbound{
+		"type":         "state",
+		"room_id":      r.id,
+		"participants": participantList(r),
+		"timer":        r.timer,
+		"chat":         r.chat,
+	})
+	broadcastLocked(r, outbound{"type": "presence", "participants": participantList(r)})
+	m.mu.Unlock()
+
+	for {
+		var in inbound
+		if err := websocket.JSON.Receive(ws, &in); err != nil {
+			break
+		}
+		m.handle(r, c, in)
+	}
+
+	m.mu.Lock()
+	m.disconnectLocked(r, c)
+	m.mu.Unlock()
+}
+
+// Participants returns who is currently connected to a room
+func (m *Manager) Participants(roomID int) []Participant {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.rooms[roomID]
+	if !ok {
+		return []Participant{}
+	}
+	return participantList(r)
+}
+
+// Kick disconnects all of a user's connections to a room (e.g. after leaving)
+func (m *Manager) Kick(roomID, userID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.rooms[roomID]
+	if !ok {
+		return
+	}
+	for c := range r.clients {
+		if c.userID == userID {
+			m.disconnectLocked(r, c)
+		}
+	}
+}
+
+// CloseRoom disconnects everyone and stops the timer (e.g. after deletion)
+func (m *Manager) CloseRoom(roomID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.rooms[roomID]
+	if !ok {
+		return
+	}
+	r.closed = true
+	if r.timerFunc != nil {
+		r.timerFunc.Stop()
+	}
+	r.timer = nil
+	for c := range r.clients {
+		m.disconnectLocked(r, c)
+	}
+	delete(m.rooms, roomID)
+}
+
+// loadLocked returns the in-memory room, restoring a running timer from the
+// database. Expired timers are left for Serve to clear.
+func (m *Manager) loadLocked(roomModel models.FocusRoom) *room {
+	if r, ok := m.rooms[roomModel.ID]; ok {
+		return r
+	}
+
+	r := &room{
+		id:           roomModel.ID,
+		ownerID:      roomModel.OwnerID,
+		clients:      make(map[*client]struct{}),
+		participants: make(map[int]*Participant),
+	}
+	m.rooms[r.id] = r
+
+	if roomModel.TimerStartedAt != nil && roomModel.TimerDurationMinutes != nil {
+		endsAt := roomModel.TimerStartedAt.Add(time.Duration(*roomModel.TimerDurationMinutes) * time.Minute)
+		if time.Now().Before(endsAt) {
+			m.startTimerLocked(r, *roomModel.TimerStartedAt, *roomModel.TimerDurationMinutes)
+		}
+	}
+
+	return r
+}
+
+// disconnectLocked removes a client, updating presence and unloading idle rooms
+func (m *Manager) disconnectLocked(r *room, c *client) {
+	if _, ok := r.clients[c]; !ok {
+		return
+	}
+	delete(r.clients, c)
+	close(c.send)
+
+	if p, ok := r.participants[c.userID]; ok {
+		p.connections--
+		if p.connections == 0 {
+			delete(r.participants, c.userID)
+			broadcastLocked(r, outbound{"type": "presence", "participants": participantList(r)})
+		}
+	}
+
+	m.unloadIfIdleLocked(r)
+}
+
+func (m *Manager) handle(r *room, c *client, in inbound) {
+	switch in.Type {
+	case "chat":
+		text := strings.TrimSpace(in.Text)
+		if text == "" || len(text) > MaxChatLength {
+			m.reply(r, c, outbound{"type": "error", "message": "chat messages must be 1-500 characters"})
+			return
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if !activeLocked(r, c) {
+			return
+		}
+		msg := ChatMessage{UserID: c.userID, Text: text, SentAt: time.Now()}
+		r.chat = append(r.chat, msg)
+		if len(r.chat) > ChatHistorySize {
+			r.chat = r.chat[len(r.chat)-ChatHistorySize:]
+		}
+		broadcastLocked(r, outbound{"type": "chat", "message": msg})
+
+	case "status":
+		if in.Status != "idle" && in.Status != "focusing" && in.Status != "break" {
+			m.reply(r, c, outbound{"type": "error", "message": "status must be 'idle', 'focusing' or 'break'"})
+			return
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if !activeLocked(r, c) {
+			return
+		}
+		if p, ok := r.participants[c.userID]; ok {
+			p.Status = in.Status
+			broadcastLocked(r, outbound{"type": "presence", "participants": participantList(r)})
+		}
+
+	case "timer.start":
+		if c.userID != r.ownerID {
+			m.reply(r, c, outbound{"type": "error", "message": "only the room owner can control the timer"})
+			return
+		}
+		if in.DurationMinutes < 1 || in.DurationMinutes > MaxTimerMinutes {
+			m.reply(r, c, outbound{"type": "error", "message": "duration_minutes must be between 1 and 240"})
+			return
+		}
+		m.startTimer(r, c, in.DurationMinutes)
+
+	case "timer.stop":
+		if c.userID != r.ownerID {
+			m.reply(r, c, outbound{"type": "error", "message": "only the room owner can control the timer"})
+			return
+		}
+		m.stopTimer(r, c)
+
+	default:
+		m.reply(r, c, outbound{"type": "error", "message": "unknown message type"})
+	}
+}
+
+// startTimer saves and arms a new shared timer. The timer is saved first so
+// it survives a restart, without holding the manager lock during the write;
+// timerPending keeps a second start (or an unload) out in the meantime.
+func (m *Manager) startTimer(r *room, c *client, durationMinutes int) {
+	m.mu.Lock()
+	if !activeLocked(r, c) {
+		m.mu.Unlock()
+		return
+	}
+	if r.timer != nil || r.timerPending {
+		c.trySend(outbound{"type": "error", "message": "a timer is already running"})
+		m.mu.Unlock()
+		return
+	}
+	r.timerPending = true
+	m.mu.Unlock()
+
+	// Postgres keeps microseconds; truncating lets ClearRoomTimer match it exactly
+	startedAt := time.Now().Truncate(time.Microsecond)
+	err := models.SetRoomTimer(m.DB, r.id, &startedAt, &durationMinutes)
+
+	m.mu.Lock()
+	r.timerPending = false
+	if err != nil {
+		log.Printf("rooms: failed to start timer for room %d: %v", r.id, err)
+		if activeLocked(r, c) {
+			c.trySend(outbound{"type": "error", "message": "failed to start timer"})
+		}
+		m.unloadIfIdleLocked(r)
+		m.mu.Unlock()
+		return
+	}
+	if r.closed {
+		// Deleted while the timer was being saved, so there is nothing to run it for
+		m.mu.Unlock()
+		return
+	}
+	m.startTimerLocked(r, startedAt, durationMinutes)
+	for _, p := range r.participants {
+		p.Status = "focusing"
+	}
+	broadcastLocked(r, outbound{"type": "timer", "timer": r.timer})
+	broadcastLocked(r, outbound{"type": "presence", "participants": participantList(r)})
+	m.mu.Unlock()
+
+	m.Events.Publish(events.Event{Type: events.RoomTimerChanged, ActorID: c.userID, TargetID: r.id})
+}
+
+// stopTimer cancels the running timer. A stopped timer is not a completed
+// session, so nothing is recorded.
+func (m *Manager) stopTimer(r *room, c *client) {
+	m.mu.Lock()
+	if !activeLocked(r, c) || r.timer == nil {
+		m.mu.Unlock()
+		return
+	}
+	startedAt := r.timer.StartedAt
+	r.timerFunc.Stop()
+	m.clearTimerLocked(r)
+	m.mu.Unlock()
+
+	if err := models.ClearRoomTimer(m.DB, r.id, startedAt); err != nil {
+		log.Printf("rooms: failed to stop timer for room %d: %v", r.id, err)
+	}
+	m.Events.Publish(events.Event{Type: events.RoomTimerChanged, ActorID: c.userID, TargetID: r.id})
+}
+
+// reply sends msg to a single client if it is still connected
+func (m *Manager) reply(r *room, c *client, msg outbound) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if activeLocked(r, c) {
+		c.trySend(msg)
+	}
+}
+
+// activeLocked reports whether c is still connected to an open room. Kick and
+// CloseRoom close a client's send channel, so nothing may be sent to it after.
+func activeLocked(r *room, c *client) bool {
+	_, ok := r.clients[c]
+	return ok && !r.closed
+}
+
+// unloadIfIdleLocked forgets a room with no clients and no timer
+func (m *Manager) unloadIfIdleLocked(r *room) {
+	if len(r.clients) == 0 && r.timer == nil && !r.timerPending && m.rooms[r.id] == r {
+		delete(m.rooms, r.id)
+	}
+}
+
+// startTimerLocked arms the in-memory timer for a room
+func (m *Manager) startTimerLocked(r *room, startedAt time.Time, durationMinutes int) {
+	endsAt := startedAt.Add(time.Duration(durationMinutes) * time.Minute)
+	r.timer = &Timer{StartedAt: startedAt, DurationMinutes: durationMinutes, EndsAt: endsAt}
+	r.timerFunc = time.AfterFunc(time.Until(endsAt), func() {
+		m.completeTimer(r, startedAt)
+	})
+}
+
+// clearTimerLocked resets the timer and everyone's status, then tells the room
+func (m *Manager) clearTimerLocked(r *room) {
+	r.timer = nil
+	r.timerFunc = nil
+	for _, p := range r.participants {
+		p.Status = "idle"
+	}
+	broadcastLocked(r, outbound{"type": "timer", "timer": nil})
+	broadcastLocked(r, outbound{"type": "presence", "participants": participantList(r)})
+	m.unloadIfIdleLocked(r)
+}
+
+// completeTimer records a room-tagged focus session for everyone still
+// connected when the shared timer ends
+func (m *Manager) completeTimer(r *room, startedAt time.Time) {
+	m.mu.Lock()
+	if r.closed || r.timer == nil || !r.timer.StartedAt.Equal(startedAt) {
+		// Stopped, replaced or deleted in the meantime
+		m.mu.Unlock()
+		return
+	}
+	endedAt := time.Now()
+	type attendee struct {
+		userID int
+		start  time.Time
+	}
+	var attendees []attendee
+	for _, p := range r.participants {
+		start := startedAt
+		if p.Since.After(start) {
+			start = p.Since
+		}
+		attendees = append(attendees, attendee{userID: p.UserID, start: start})
+	}
+	m.clearTimerLocked(r)
+	m.mu.Unlock()
+
+	if err := models.ClearRoomTimer(m.DB, r.id, startedAt); err != nil {
+		log.Printf("rooms: failed to clear timer for room %d: %v", r.id, err)
+	}
+	m.Events.Publish(events.Event{Type: events.RoomTimerChanged, TargetID: r.id})
+
+	roomID := r.id
+	for _, a := range attendees {
+		if endedAt.Sub(a.start) < minRecordedMinutes*time.Minute {
+			continue
+		}
+
+		// Sessions from a room deleted in the meantime are kept, untagged
+		m.mu.Lock()
+		roomRef := &roomID
+		if r.closed {
+			roomRef = nil
+		}
+		m.mu.Unlock()
+
+		session, err := models.CreateSession(m.DB, a.userID, roomRef, a.start, endedAt)
+		if err != nil {
+			log.Printf("rooms: failed to record session for user %d in room %d: %v", a.userID, roomID, err)
+			continue
+		}
+		m.Events.Publish(events.Event{Type: events.SessionCreated, ActorID: a.userID, TargetID: session.ID})
+
+		m.mu.Lock()
+		for c := range r.clients {
+			if c.userID == a.userID {
+				c.trySend(outbound{"type": "session.recorded", "session": session})
+			}
+		}
+		m.mu.Unlock()
+	}
+}
+
+func participantList(r *room) []Participant {
+	list := make([]Participant, 0, len(r.participants))
+	for _, p := range r.participants {
+		list = append(list, *p)
+	}
+	return list
+}
+
+// broadcastLocked sends msg to every client in the room
+func broadcastLocked(r *room, msg outbound) {
+	for c := range r.clients {
+		c.trySend(msg)
+	}
+}
+
+// trySend queues msg without blocking; the caller must hold the manager lock
+// and know c is still connected. A client whose buffer is full is too slow to
+// keep up, so its connection is closed and it has to reconnect.
+func (c *client) trySend(msg outbound) {
+	select {
+	case c.send <- msg:
+	default:
+		c.conn.Close()
+	}
+}
+
+// writeLoop writes queued messages until the send channel is closed
+func (c *client) writeLoop() {
+	for msg := range c.send {
+		if err := websocket.JSON.Send(c.conn, msg); err != nil {
+			break
+		}
+	}
+	c.conn.Close()
+	// Drain so senders never block on a dead connection
+	for range c.send {
+	}
+}
@@ -4,30 +4,90 @@ import (
 	"database/sql"
 	"net/http"
 
+	"tomo/backend/events"
+	"tomo/backend/groups"
 	"tomo/backend/handlers"
 	"tomo/backend/middleware"
+	"tomo/backend/notifications"
+	"tomo/backend/realtime"
+	"tomo/backend/rooms"
+	"tomo/backend/storage"
 )
 
-// NewRouter sets all routes and returns ServeMux
-func NewRouter(db *sql.DB) *http.ServeMux {
+// NewRouter sets all routes and returns ServeMux. Handlers publish domain
+// events to bus, which is shared with background jobs, and keep media files
+// in store.
+func NewRouter(db *sql.DB, bus *events.Bus, store storage.Storage) *http.ServeMux {
 	mux := http.NewServeMux()
 
+	// Every authenticated request is checked against the current user record
+	middleware.UseDB(db)
+
+	// Domain events published by handlers and consumed by subsystems
+	notifications.Register(bus, db)
+	groups.Register(bus, db)
+
+	// Per-process fan-out to connected clients
+	hub := realtime.NewHub()
+	realtime.Register(bus, hub, db)
+
+	// Live state of co-focus rooms
+	roomManager := rooms.NewManager(db, bus)
+
 	// Initialize handlers with shared db connection
 	authHandler := &handlers.AuthHandler{DB: db}
-	userHandler := &handlers.UserHandler{DB: db}
-	sessionHandler := &handlers.SessionHandler{DB: db}
-	postHandler := &handlers.PostHandler{DB: db}
-	mediaHandler := &handlers.MediaHandler{DB: db}
+	userHandler := &handlers.UserHandler{DB: db, Events: bus, Storage: store}
+	sessionHandler := &handlers.SessionHandler{DB: db, Events: bus, Storage: store}
+	postHandler := &handlers.PostHandler{DB: db, Events: bus, Storage: store}
+	mediaHandler := &handlers.MediaHandler{DB: db, Storage: store}
+	commentHandler := &handlers.CommentHandler{DB: db, Events: bus}
+	notificationHandler := &handlers.NotificationHandler{DB: db}
+	eventStreamHandler := &handlers.EventStreamHandler{Broker: hub}
+	roomHandler := &handlers.RoomHandler{DB: db, Rooms: roomManager}
+	groupHandler := &handlers.GroupHandler{DB: db}
+	challengeHandler := &handlers.ChallengeHandler{DB: db}
+	reportHandler := &handlers.ReportHandler{DB: db}
+	moderationHandler := &handlers.ModerationHandler{DB: db}
+	adminHandler := &handlers.AdminHandler{DB: db}
+	tagHandler := &handlers.TagHandler{DB: db}
+	templateHandler := &handlers.TemplateHandler{DB: db}
+	collectionHandler := &handlers.CollectionHandler{DB: db}
 
 	// --- PUBLIC ROUTES ---
 	mux.HandleFunc("POST /auth/google", authHandler.GoogleAuth)
-	mux.HandleFunc("GET /users/{username}", userHandler.GetUserByUsername)
+	mux.Handle("GET /users/{username}", middleware.OptionalAuthMiddleware(http.HandlerFunc(userHandler.GetUserByUsername)))
+	mux.Handle("GET /users/{username}/posts", middleware.OptionalAuthMiddleware(http.HandlerFunc(postHandler.GetUserPosts)))
+	mux.Handle("GET /users/{username}/collections", middleware.OptionalAuthMiddleware(http.HandlerFunc(collectionHandler.GetUserCollections)))
+	mux.Handle("GET /collections/{id}", middleware.OptionalAuthMiddleware(http.HandlerFunc(collectionHandler.GetCollection)))
+	mux.HandleFunc("GET /p/{slug}", postHandler.GetSharePage)
+
+	// Files in local storage, including presigned uploads (authorized by signature)
+	if local, ok := store.(*storage.Local); ok {
+		mux.Handle(storage.LocalRoutePrefix, http.StripPrefix(storage.LocalRoutePrefix, local))
+	}
 
 	// --- PROTECTED ROUTES (require auth) ---
 	// User routes
 	mux.Handle("GET /me", middleware.AuthMiddleware(http.HandlerFunc(userHandler.GetMe)))
 	mux.Handle("PATCH /me", middleware.AuthMiddleware(http.HandlerFunc(userHandler.UpdateMe)))
 	mux.Handle("DELETE /me", middleware.AuthMiddleware(http.HandlerFunc(userHandler.DeleteMe)))
+	mux.Handle("GET /me/notification-preferences", middleware.AuthMiddleware(http.HandlerFunc(notificationHandler.GetPreferences)))
+	mux.Handle("PATCH /me/notification-preferences", middleware.AuthMiddleware(http.HandlerFunc(notificationHandler.UpdatePreferences)))
+	mux.Handle("GET /me/posts", middleware.AuthMiddleware(http.HandlerFunc(postHandler.GetMyPosts)))
+	mux.Handle("GET /me/drafts", middleware.AuthMiddleware(http.HandlerFunc(postHandler.GetMyDrafts)))
+	mux.Handle("GET /me/scheduled", middleware.AuthMiddleware(http.HandlerFunc(postHandler.GetMyScheduledPosts)))
+	mux.Handle("GET /me/collections", middleware.AuthMiddleware(http.HandlerFunc(collectionHandler.GetMyCollections)))
+	mux.Handle("GET /me/blocks", middleware.AuthMiddleware(http.HandlerFunc(userHandler.GetBlockedUsers)))
+	mux.Handle("GET /me/mutes", middleware.AuthMiddleware(http.HandlerFunc(userHandler.GetMutedUsers)))
+	mux.Handle("POST /users/{username}/block", middleware.AuthMiddleware(http.HandlerFunc(userHandler.BlockUser)))
+	mux.Handle("DELETE /users/{username}/block", middleware.AuthMiddleware(http.HandlerFunc(userHandler.UnblockUser)))
+	mux.Handle("POST /users/{username}/mute", middleware.AuthMiddleware(http.HandlerFunc(userHandler.MuteUser)))
+	mux.Handle("DELETE /users/{username}/mute", middleware.AuthMiddleware(http.HandlerFunc(userHandler.UnmuteUser)))
+	mux.Handle("GET /me/followers", middleware.AuthMiddleware(http.HandlerFunc(userHandler.GetFollowers)))
+	mux.Handle("GET /me/following", middleware.AuthMiddleware(http.HandlerFunc(userHandler.GetFollowing)))
+	mux.Handle("POST /users/{username}/follow", middleware.AuthMiddleware(http.HandlerFunc(userHandler.FollowUser)))
+	mux.Handle("DELETE /users/{username}/follow", middleware.AuthMiddleware(http.HandlerFunc(userHandler.UnfollowUser)))
+	mux.Handle("GET /me/challenges", middleware.AuthMiddleware(http.HandlerFunc(challengeHandler.GetMyChallenges)))
 
 	// Session routes
 	mux.Handle("POST /sessions", middleware.AuthMiddleware(http.HandlerFunc(sessionHandler.CreateSession)))
@@ -36,17 +96,125 @@ func NewRouter(db *sql.DB) *http.ServeMux {
 	mux.Handle("DELETE /sessions/{id}", middleware.AuthMiddleware(http.HandlerFunc(sessionHandler.DeleteSession)))
 
 	// Post routes
+	mux.Handle("GET /feed", middleware.AuthMiddleware(http.HandlerFunc(postHandler.GetFeed)))
+	mux.Handle("GET /hashtags/{tag}/posts", middleware.AuthMiddleware(http.HandlerFunc(postHandler.GetHashtagPosts)))
+	mux.Handle("GET /search/posts", middleware.AuthMiddleware(http.HandlerFunc(postHandler.SearchPosts)))
 	mux.Handle("POST /posts", middleware.AuthMiddleware(http.HandlerFunc(postHandler.CreatePost)))
-	mux.Handle("GET /posts/user/{id}", middleware.AuthMiddleware(http.HandlerFunc(postHandler.GetUserPosts)))
 	mux.Handle("GET /posts/{id}", middleware.AuthMiddleware(http.HandlerFunc(postHandler.GetPost)))
 	mux.Handle("PATCH /posts/{id}", middleware.AuthMiddleware(http.HandlerFunc(postHandler.UpdatePost)))
+	mux.Handle("POST /posts/{id}/publish", middleware.AuthMiddleware(http.HandlerFunc(postHandler.PublishPost)))
+	mux.Handle("PUT /posts/{id}/schedule", middleware.AuthMiddleware(http.HandlerFunc(postHandler.SchedulePost)))
+	mux.Handle("DELETE /posts/{id}/schedule", middleware.AuthMiddleware(http.HandlerFunc(postHandler.UnschedulePost)))
+	mux.Handle("POST /posts/{id}/pin", middleware.AuthMiddleware(http.HandlerFunc(postHandler.PinPost)))
+	mux.Handle("DELETE /posts/{id}/pin", middleware.AuthMiddleware(http.HandlerFunc(postHandler.UnpinPost)))
+	mux.Handle("GET /posts/{id}/revisions", middleware.AuthMiddleware(http.HandlerFunc(postHandler.GetPostRevisions)))
+	mux.Handle("POST /posts/{id}/revisions/{revisionId}/restore", middleware.AuthMiddleware(http.HandlerFunc(postHandler.RestorePostRevision)))
+	mux.Handle("GET /posts/{id}/reactions", middleware.AuthMiddleware(http.HandlerFunc(postHandler.GetReactions)))
+	mux.Handle("PUT /posts/{id}/reaction", middleware.AuthMiddleware(http.HandlerFunc(postHandler.SetReaction)))
+	mux.Handle("DELETE /posts/{id}/reaction", middleware.AuthMiddleware(http.HandlerFunc(postHandler.DeleteReaction)))
 	mux.Handle("DELETE /posts/{id}", middleware.AuthMiddleware(http.HandlerFunc(postHandler.DeletePost)))
 
+	// Collection routes (GET /collections/{id} is public, above)
+	mux.Handle("POST /collections", middleware.AuthMiddleware(http.HandlerFunc(collectionHandler.CreateCollection)))
+	mux.Handle("PATCH /collections/{id}", middleware.AuthMiddleware(http.HandlerFunc(collectionHandler.UpdateCollection)))
+	mux.Handle("DELETE /collections/{id}", middleware.AuthMiddleware(http.HandlerFunc(collectionHandler.DeleteCollection)))
+	mux.Handle("POST /collections/{id}/posts", middleware.AuthMiddleware(http.HandlerFunc(collectionHandler.AddCollectionPost)))
+	mux.Handle("PUT /collections/{id}/posts", middleware.AuthMiddleware(http.HandlerFunc(collectionHandler.ReorderCollection)))
+	mux.Handle("DELETE /collections/{id}/posts/{postId}", middleware.AuthMiddleware(http.HandlerFunc(collectionHandler.RemoveCollectionPost)))
+
+	// Journal routes (one 'daily' post per calendar day)
+	mux.Handle("GET /journal", middleware.AuthMiddleware(http.HandlerFunc(postHandler.GetJournal)))
+	mux.Handle("GET /journal/{date}", middleware.AuthMiddleware(http.HandlerFunc(postHandler.GetJournalEntry)))
+	mux.Handle("PUT /journal/{date}", middleware.AuthMiddleware(http.HandlerFunc(postHandler.PutJournalEntry)))
+
 	// Media routes
 	mux.Handle("POST /posts/{id}/media", middleware.AuthMiddleware(http.HandlerFunc(mediaHandler.AddMediaToPost)))
 	mux.Handle("POST /posts/{id}/media/upload", middleware.AuthMiddleware(http.HandlerFunc(mediaHandler.UploadMediaFile)))
+	mux.Handle("POST /posts/{id}/media/presign", middleware.AuthMiddleware(http.HandlerFunc(mediaHandler.PresignMediaUpload)))
+	mux.Handle("POST /media/{id}/complete", middleware.AuthMiddleware(http.HandlerFunc(mediaHandler.CompleteMediaUpload)))
 	mux.Handle("GET /posts/{id}/media", middleware.AuthMiddleware(http.HandlerFunc(mediaHandler.GetPostMedia)))
 	mux.Handle("DELETE /media/{id}", middleware.AuthMiddleware(http.HandlerFunc(mediaHandler.DeleteMedia)))
 
+	// Tag routes
+	mux.Handle("GET /tags", middleware.AuthMiddleware(http.HandlerFunc(tagHandler.GetMyTags)))
+	mux.Handle("PATCH /tags/{id}", middleware.AuthMiddleware(http.HandlerFunc(tagHandler.RenameTag)))
+	mux.Handle("POST /tags/{id}/merge", middleware.AuthMiddleware(http.HandlerFunc(tagHandler.MergeTag)))
+	mux.Handle("DELETE /tags/{id}", middleware.AuthMiddleware(http.HandlerFunc(tagHandler.DeleteTag)))
+
+	// Reflection template routes
+	mux.Handle("GET /templates", middleware.AuthMiddleware(http.HandlerFunc(templateHandler.GetTemplates)))
+	mux.Handle("POST /templates", middleware.AuthMiddleware(http.HandlerFunc(templateHandler.CreateTemplate)))
+	mux.Handle("GET /templates/{id}", middleware.AuthMiddleware(http.HandlerFunc(templateHandler.GetTemplate)))
+	mux.Handle("DELETE /templates/{id}", middleware.AuthMiddleware(http.HandlerFunc(templateHandler.DeleteTemplate)))
+	mux.Handle("GET /templates/{id}/insights", middleware.AuthMiddleware(http.HandlerFunc(templateHandler.GetTemplateInsights)))
+
+	// Comment routes
+	mux.Handle("GET /posts/{id}/comments", middleware.AuthMiddleware(http.HandlerFunc(commentHandler.GetPostComments)))
+	mux.Handle("POST /posts/{id}/comments", middleware.AuthMiddleware(http.HandlerFunc(commentHandler.CreateComment)))
+	mux.Handle("PATCH /comments/{id}", middleware.AuthMiddleware(http.HandlerFunc(commentHandler.UpdateComment)))
+	mux.Handle("DELETE /comments/{id}", middleware.AuthMiddleware(http.HandlerFunc(commentHandler.DeleteComment)))
+
+	// Notification routes
+	mux.Handle("GET /notifications", middleware.AuthMiddleware(http.HandlerFunc(notificationHandler.GetNotifications)))
+	mux.Handle("GET /notifications/unread-count", middleware.AuthMiddleware(http.HandlerFunc(notificationHandler.GetUnreadCount)))
+	mux.Handle("POST /notifications/{id}/read", middleware.AuthMiddleware(http.HandlerFunc(notificationHandler.MarkRead)))
+	mux.Handle("POST /notifications/read-all", middleware.AuthMiddleware(http.HandlerFunc(notificationHandler.MarkAllRead)))
+
+	// Real-time event stream (Server-Sent Events)
+	mux.Handle("POST /events/ticket", middleware.AuthMiddleware(http.HandlerFunc(eventStreamHandler.IssueTicket)))
+	mux.Handle("GET /events", middleware.StreamAuthMiddleware(http.HandlerFunc(eventStreamHandler.Stream)))
+
+	// Co-focus room routes
+	mux.Handle("POST /rooms", middleware.AuthMiddleware(http.HandlerFunc(roomHandler.CreateRoom)))
+	mux.Handle("GET /rooms", middleware.AuthMiddleware(http.HandlerFunc(roomHandler.GetMyRooms)))
+	mux.Handle("GET /rooms/{id}", middleware.AuthMiddleware(http.HandlerFunc(roomHandler.GetRoom)))
+	mux.Handle("POST /rooms/join", middleware.AuthMiddleware(http.HandlerFunc(roomHandler.JoinRoom)))
+	mux.Handle("POST /rooms/{id}/invite-code", middleware.AuthMiddleware(http.HandlerFunc(roomHandler.RegenerateInviteCode)))
+	mux.Handle("POST /rooms/{id}/leave", middleware.AuthMiddleware(http.HandlerFunc(roomHandler.LeaveRoom)))
+	mux.Handle("DELETE /rooms/{id}", middleware.AuthMiddleware(http.HandlerFunc(roomHandler.DeleteRoom)))
+	mux.Handle("GET /rooms/{id}/ws", middleware.StreamAuthMiddleware(http.HandlerFunc(roomHandler.Connect)))
+
+	// Study group routes
+	mux.Handle("POST /groups", middleware.AuthMiddleware(http.HandlerFunc(groupHandler.CreateGroup)))
+	mux.Handle("GET /groups", middleware.AuthMiddleware(http.HandlerFunc(groupHandler.GetMyGroups)))
+	mux.Handle("POST /groups/join", middleware.AuthMiddleware(http.HandlerFunc(groupHandler.JoinGroup)))
+	mux.Handle("GET /groups/{id}", middleware.AuthMiddleware(http.HandlerFunc(groupHandler.GetGroup)))
+	mux.Handle("PATCH /groups/{id}", middleware.AuthMiddleware(http.HandlerFunc(groupHandler.UpdateGroup)))
+	mux.Handle("DELETE /groups/{id}", middleware.AuthMiddleware(http.HandlerFunc(groupHandler.DeleteGroup)))
+	mux.Handle("POST /groups/{id}/invite-code", middleware.AuthMiddleware(http.HandlerFunc(groupHandler.RegenerateInviteCode)))
+	mux.Handle("PATCH /groups/{id}/me", middleware.AuthMiddleware(http.HandlerFunc(groupHandler.UpdateMySharing)))
+	mux.Handle("GET /groups/{id}/leaderboard", middleware.AuthMiddleware(http.HandlerFunc(groupHandler.GetLeaderboard)))
+	mux.Handle("PATCH /groups/{id}/members/{userId}", middleware.AuthMiddleware(http.HandlerFunc(groupHandler.UpdateMember)))
+	mux.Handle("DELETE /groups/{id}/members/{userId}", middleware.AuthMiddleware(http.HandlerFunc(groupHandler.RemoveMember)))
+	mux.Handle("GET /groups/{id}/members/{userId}/sessions", middleware.AuthMiddleware(http.HandlerFunc(groupHandler.GetMemberSessions)))
+
+	// Focus challenge routes
+	mux.Handle("POST /challenges", middleware.AuthMiddleware(http.HandlerFunc(challengeHandler.CreateChallenge)))
+	mux.Handle("GET /challenges", middleware.AuthMiddleware(http.HandlerFunc(challengeHandler.GetChallenges)))
+	mux.Handle("GET /challenges/{id}", middleware.AuthMiddleware(http.HandlerFunc(challengeHandler.GetChallenge)))
+	mux.Handle("DELETE /challenges/{id}", middleware.AuthMiddleware(http.HandlerFunc(challengeHandler.DeleteChallenge)))
+	mux.Handle("POST /challenges/{id}/join", middleware.AuthMiddleware(http.HandlerFunc(challengeHandler.JoinChallenge)))
+	mux.Handle("POST /challenges/{id}/leave", middleware.AuthMiddleware(http.HandlerFunc(challengeHandler.LeaveChallenge)))
+
+	// Report routes
+	mux.Handle("POST /reports", middleware.AuthMiddleware(http.HandlerFunc(reportHandler.CreateReport)))
+
+	// --- STAFF ROUTES (require auth + role) ---
+	moderator := middleware.RequireRole("moderator", "admin")
+	admin := middleware.RequireRole("admin")
+
+	// Moderation queue (moderators and admins)
+	mux.Handle("GET /admin/reports", middleware.AuthMiddleware(moderator(http.HandlerFunc(moderationHandler.GetReports))))
+	mux.Handle("POST /admin/reports/{id}/resolve", middleware.AuthMiddleware(moderator(http.HandlerFunc(moderationHandler.ResolveReport))))
+	mux.Handle("POST /admin/content/{type}/{id}/hide", middleware.AuthMiddleware(moderator(http.HandlerFunc(moderationHandler.HideContent))))
+	mux.Handle("POST /admin/content/{type}/{id}/unhide", middleware.AuthMiddleware(moderator(http.HandlerFunc(moderationHandler.UnhideContent))))
+
+	// User management and stats (admins only)
+	mux.Handle("GET /admin/users", middleware.AuthMiddleware(admin(http.HandlerFunc(adminHandler.FindUsers))))
+	mux.Handle("GET /admin/users/{id}", middleware.AuthMiddleware(admin(http.HandlerFunc(adminHandler.GetUser))))
+	mux.Handle("POST /admin/users/{id}/suspend", middleware.AuthMiddleware(admin(http.HandlerFunc(adminHandler.SuspendUser))))
+	mux.Handle("POST /admin/users/{id}/unsuspend", middleware.AuthMiddleware(admin(http.HandlerFunc(adminHandler.UnsuspendUser))))
+	mux.Handle("GET /admin/stats", middleware.AuthMiddleware(admin(http.HandlerFunc(adminHandler.GetStats))))
+
 	return mux
 }
@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalRoutePrefix is where the API serves files kept in local storage
+const LocalRoutePrefix = "/files/"
+
+// metaDir holds each file's ObjectInfo. ValidKey rejects dot segments, so no
+// key can collide with it.
+const metaDir = ".meta"
+
+// Local stores files in a directory and serves them over HTTP (see
+// ServeHTTP), including presigned uploads, so nothing else is needed to run
+// the API locally
+type Local struct {
+	dir        string
+	baseURL    string
+	signingKey []byte
+}
+
+// NewLocal stores files under dir. baseURL is where ServeHTTP is mounted,
+// e.g. "http://localhost:8080/files/"; signingKey signs upload URLs.
+func NewLocal(dir, baseURL string, signingKey []byte) (*Local, error) {
+	if err := os.MkdirAll(filepath.Join(dir, metaDir), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &Local{
+		dir:        dir,
+		baseURL:    strings.TrimRight(baseURL, "/") + "/",
+		signingKey: signingKey,
+	}, nil
+}
+
+func (l *Local) BaseURL() string {
+	return l.baseURL
+}
+
+func (l *Local) path(key string) string {
+	return filepath.Join(l.dir, filepath.FromSlash(key))
+}
+
+func (l *Local) metaPath(key string) string {
+	return filepath.Join(l.dir, metaDir, filepath.FromSlash(key)+".json")
+}
+
+// Put writes the file to a temporary name first, so readers never see a
+// partial upload
+func (l *Local) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	if err := ValidKey(key); err != nil {
+		return err
+	}
+	path := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	// One extra byte tells us if the body is longer than promised
+	n, err := io.Copy(tmp, io.LimitReader(body, size+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if n != size {
+		return fmt.Errorf("storage: expected %d bytes, got %d", size, n)
+	}
+
+	meta, err := json.Marshal(ObjectInfo{Size: size, ContentType: contentType})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.metaPath(key)), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(l.metaPath(key), meta, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	info, err := l.Stat(ctx, key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	f, err := os.Open(l.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	return f, info, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if err := ValidKey(key); err != nil {
+		return err
+	}
+	for _, path := range []string{l.path(key), l.metaPath(key)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	if err := ValidKey(key); err != nil {
+		return ObjectInfo{}, err
+	}
+	if _, err := os.Stat(l.path(key)); errors.Is(err, fs.ErrNotExist) {
+		return ObjectInfo{}, ErrNotFound
+	} else if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	raw, err := os.ReadFile(l.metaPath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	var info ObjectInfo
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return ObjectInfo{}, err
+	}
+	return info, nil
+}
+
+// Presign returns a URL to PUT the file to ServeHTTP. The signature covers the
+// key, content type, size and expiry.
+func (l *Local) Presign(ctx context.Context, key, contentType string, size int64, expires time.Duration) (PresignedUpload, error) {
+	if err := ValidKey(key); err != nil {
+		return PresignedUpload{}, err
+	}
+	expiresAt := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	sizeStr := strconv.FormatInt(size, 10)
+
+	q := url.Values{}
+	q.Set("expires", expiresAt)
+	q.Set("size", sizeStr)
+	q.Set("signature", l.sign(key, contentType, sizeStr, expiresAt))
+
+	return PresignedUpload{
+		URL:     l.baseURL + key + "?" + q.Encode(),
+		Method:  http.MethodPut,
+		Headers: map[string]string{"Content-Type": contentType},
+	}, nil
+}
+
+func (l *Local) sign(key, contentType, size, expires string) string {
+	mac := hmac.New(sha256.New, l.signingKey)
+	mac.Write([]byte(key + "\n" + contentType + "\n" + size + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeHTTP serves GET for stored files and PUT for presigned uploads. Mount
+// it with the LocalRoutePrefix stripped.
+func (l *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Path
+	if ValidKey(key) != nil {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		f, info, err := l.Get(r.Context(), key)
+		if err == ErrNotFound {
+			http.Error(w, "file not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to read file", http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+
+		w.Header().Set("Content-Type", info.ContentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		http.ServeContent(w, r, "", time.Time{}, f.(io.ReadSeeker))
+
+	case http.MethodPut:
+		q := r.URL.Query()
+		expires, size := q.Get("expires"), q.Get("size")
+		contentType := r.Header.Get("Content-Type")
+		want := l.sign(key, contentType, size, expires)
+		if !hmac.Equal([]byte(q.Get("signature")), []byte(want)) {
+			http.Error(w, "invalid signature", http.StatusForbidden)
+			return
+		}
+		expiresAt, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || time.Now().Unix() > expiresAt {
+			http.Error(w, "upload URL expired", http.StatusForbidden)
+			return
+		}
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || r.ContentLength != n {
+			http.Error(w, "Content-Length does not match the signed size", http.StatusBadRequest)
+			return
+		}
+
+		if err := l.Put(r.Context(), key, http.MaxBytesReader(w, r.Body, n), n, contentType); err != nil {
+			http.Error(w, "failed to store file", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
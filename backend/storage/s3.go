@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3Config selects a bucket on AWS S3 or an S3-compatible service
+type S3Config struct {
+	Bucket    string
+	Region    string // "auto" for R2
+	Endpoint  string // e.g. "http://localhost:9000" for MinIO; empty for AWS
+	PublicURL string // where files are served from, if not the endpoint (e.g. an R2 public domain)
+	PathStyle bool   // address the bucket as endpoint/bucket instead of bucket.endpoint (MinIO)
+}
+
+// S3 stores files in an S3 bucket
+type S3 struct {
+	client  *s3.Client
+	bucket  string
+	baseURL string
+	acl     types.ObjectCannedACL
+}
+
+// NewS3 connects to the bucket in cfg. Credentials come from the default AWS
+// chain (environment, shared config, instance role).
+func NewS3(ctx context.Context, cfg S3Config) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3_BUCKET_NAME environment variable not set")
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("AWS_REGION environment variable not set")
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(cfg.Region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.PathStyle
+	})
+
+	store := &S3{client: client, bucket: cfg.Bucket}
+	switch {
+	case cfg.PublicURL != "":
+		store.baseURL = strings.TrimRight(cfg.PublicURL, "/") + "/"
+	case cfg.Endpoint != "" && cfg.PathStyle:
+		store.baseURL = strings.TrimRight(cfg.Endpoint, "/") + "/" + cfg.Bucket + "/"
+	case cfg.Endpoint != "":
+		return nil, fmt.Errorf("S3_PUBLIC_URL must be set when using S3_ENDPOINT without path-style addressing")
+	default:
+		store.baseURL = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/", cfg.Bucket, cfg.Region)
+		// On AWS files are made public per object; S3-compatible services
+		// usually expose a bucket through a policy or public domain instead
+		store.acl = types.ObjectCannedACLPublicRead
+	}
+
+	return store, nil
+}
+
+func (s *S3) BaseURL() string {
+	return s.baseURL
+}
+
+// Put uploads the file, giving up after 20 seconds
+func (s *S3) Put(parentCtx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	ctx, cancel := context.WithTimeout(parentCtx, 20*time.Second)
+	defer cancel()
+
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+		ContentType:   aws.String(contentType),
+		ACL:           s.acl,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put S3 object: %w", err)
+	}
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, ObjectInfo{}, fmt.Errorf("failed to get S3 object: %w", err)
+	}
+	return out.Body, ObjectInfo{Size: aws.ToInt64(out.ContentLength), ContentType: aws.ToString(out.ContentType)}, nil
+}
+
+func (s *S3) Delete(parentCtx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(parentCtx, 20*time.Second)
+	defer cancel()
+
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete S3 object: %w", err)
+	}
+	return nil
+}
+
+// Presign signs a PUT; S3 rejects uploads that don't match the signed size
+// and content type
+func (s *S3) Presign(ctx context.Context, key, contentType string, size int64, expires time.Duration) (PresignedUpload, error) {
+	req, err := s3.NewPresignClient(s.client).PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(size),
+		ACL:           s.acl,
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return PresignedUpload{}, fmt.Errorf("failed to presign S3 upload: %w", err)
+	}
+
+	// Host is set by the client's HTTP library from the URL
+	headers := make(map[string]string, len(req.SignedHeader))
+	for name, values := range req.SignedHeader {
+		if strings.EqualFold(name, "Host") || len(values) == 0 {
+			continue
+		}
+		headers[name] = values[0]
+	}
+
+	return PresignedUpload{URL: req.URL, Method: req.Method, Headers: headers}, nil
+}
+
+func (s *S3) Stat(parentCtx context.Context, key string) (ObjectInfo, error) {
+	ctx, cancel := context.WithTimeout(parentCtx, 20*time.Second)
+	defer cancel()
+
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to head S3 object: %w", err)
+	}
+	return ObjectInfo{Size: aws.ToInt64(out.ContentLength), ContentType: aws.ToString(out.ContentType)}, nil
+}
@@ -0,0 +1,142 @@
+// Package storage keeps uploaded media files. Files live in S3 (or any
+// S3-compatible service such as MinIO or R2) or, for local development and
+// tests, on the filesystem and served by the API itself.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when nothing is stored under a key
+var ErrNotFound = errors.New("storage: object not found")
+
+// ErrInvalidKey is returned for keys that could escape the storage root
+var ErrInvalidKey = errors.New("storage: invalid key")
+
+// ObjectInfo describes a stored file
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+}
+
+// PresignedUpload is a signed request a client can use to upload one file
+// straight to storage. Headers must be sent exactly as given.
+type PresignedUpload struct {
+	URL     string            `json:"upload_url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+}
+
+// Storage stores files under slash-separated keys like "uploads/12/abc.jpg".
+// Every stored file is publicly readable at BaseURL() + key.
+type Storage interface {
+	// Put stores exactly size bytes of body under key
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get opens the file stored under key; the caller closes it
+	Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
+	// Delete removes the file under key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// Presign signs an upload of exactly size bytes of contentType to key,
+	// valid for expires
+	Presign(ctx context.Context, key, contentType string, size int64, expires time.Duration) (PresignedUpload, error)
+	// Stat reports whether a file exists under key, returning ErrNotFound if not
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// BaseURL is the public URL prefix of every stored file, ending in "/"
+	BaseURL() string
+}
+
+// URL returns the public URL of the file under key
+func URL(s Storage, key string) string {
+	return s.BaseURL() + key
+}
+
+// KeyFromURL returns the key of a file URL from s, or false if the URL points
+// somewhere else
+func KeyFromURL(s Storage, fileURL string) (string, bool) {
+	key, ok := strings.CutPrefix(fileURL, s.BaseURL())
+	if !ok || ValidKey(key) != nil {
+		return "", false
+	}
+	return key, true
+}
+
+// ValidKey rejects keys that are empty, absolute, or contain empty, "." or
+// ".."-style segments (any segment starting with a dot)
+func ValidKey(key string) error {
+	if key == "" || len(key) > 1024 || strings.ContainsAny(key, "\\\x00") {
+		return ErrInvalidKey
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || strings.HasPrefix(seg, ".") {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
+
+// FromEnv builds the storage backend selected by STORAGE_BACKEND:
+//
+//   - "s3": S3_BUCKET_NAME and AWS_REGION, plus S3_ENDPOINT for S3-compatible
+//     services, S3_PUBLIC_URL if files are served from another domain and
+//     S3_FORCE_PATH_STYLE=true for MinIO. Credentials come from the usual AWS
+//     environment variables or config files.
+//   - "local": files under LOCAL_STORAGE_DIR (default "uploads"), served at
+//     PUBLIC_BASE_URL/files/. Uploads are signed with STORAGE_SIGNING_KEY.
+//
+// Without STORAGE_BACKEND, S3 is used if S3_BUCKET_NAME is set and local
+// storage otherwise.
+func FromEnv(ctx context.Context) (Storage, error) {
+	backend := strings.TrimSpace(os.Getenv("STORAGE_BACKEND"))
+	if backend == "" {
+		backend = "local"
+		if os.Getenv("S3_BUCKET_NAME") != "" {
+			backend = "s3"
+		}
+	}
+
+	switch backend {
+	case "s3":
+		return NewS3(ctx, S3Config{
+			Bucket:    strings.TrimSpace(os.Getenv("S3_BUCKET_NAME")),
+			Region:    strings.TrimSpace(os.Getenv("AWS_REGION")),
+			Endpoint:  strings.TrimSpace(os.Getenv("S3_ENDPOINT")),
+			PublicURL: strings.TrimSpace(os.Getenv("S3_PUBLIC_URL")),
+			PathStyle: os.Getenv("S3_FORCE_PATH_STYLE") == "true",
+		})
+
+	case "local":
+		dir := strings.TrimSpace(os.Getenv("LOCAL_STORAGE_DIR"))
+		if dir == "" {
+			dir = "uploads"
+		}
+		base := strings.TrimRight(strings.TrimSpace(os.Getenv("PUBLIC_BASE_URL")), "/")
+		if base == "" {
+			port := strings.TrimSpace(os.Getenv("PORT"))
+			if port == "" {
+				port = "8080"
+			}
+			base = "http://localhost:" + port
+		}
+		signingKey := []byte(os.Getenv("STORAGE_SIGNING_KEY"))
+		if len(signingKey) == 0 {
+			// Fine for development: upload URLs just stop working after a restart
+			log.Println("STORAGE_SIGNING_KEY not set; using a random key for local upload URLs")
+			signingKey = make([]byte, 32)
+			if _, err := rand.Read(signingKey); err != nil {
+				return nil, err
+			}
+		}
+		return NewLocal(dir, base+LocalRoutePrefix, signingKey)
+
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q (want \"s3\" or \"local\")", backend)
+	}
+}
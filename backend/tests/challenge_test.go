@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"tomo/backend/handlers"
+	"tomo/backend/models"
+)
+
+func day(n int) time.Time {
+	return time.Date(2025, time.March, n, 0, 0, 0, 0, time.UTC)
+}
+
+func TestChallengeScore(t *testing.T) {
+	days := []models.DailyTotal{
+		{Day: day(1), Minutes: 30},
+		{Day: day(2), Minutes: 60},
+		{Day: day(3), Minutes: 10}, // below threshold breaks the streak
+		{Day: day(4), Minutes: 45},
+		{Day: day(5), Minutes: 90},
+		{Day: day(6), Minutes: 25},
+		{Day: day(8), Minutes: 50}, // gap on day 7 breaks the streak
+	}
+
+	tests := []struct {
+		metric string
+		want   int
+	}{
+		{"total_minutes", 310},
+		{"days_over_threshold", 6},
+		{"longest_streak", 3},
+	}
+	for _, tt := range tests {
+		if got := models.ChallengeScore(tt.metric, 25, days); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.metric, got, tt.want)
+		}
+	}
+
+	if got := models.ChallengeScore("longest_streak", 1, nil); got != 0 {
+		t.Errorf("empty streak: got %d, want 0", got)
+	}
+}
+
+func TestRankStandingsSharesTies(t *testing.T) {
+	standings := []models.Standing{
+		{UserID: 1, Score: 100},
+		{UserID: 2, Score: 300},
+		{UserID: 3, Score: 100},
+		{UserID: 4, Score: 0},
+	}
+	models.RankStandings(standings)
+
+	want := []struct{ userID, rank int }{{2, 1}, {1, 2}, {3, 2}, {4, 4}}
+	for i, w := range want {
+		if standings[i].UserID != w.userID || standings[i].Rank != w.rank {
+			t.Errorf("position %d: got user %d rank %d, want user %d rank %d",
+				i, standings[i].UserID, standings[i].Rank, w.userID, w.rank)
+		}
+	}
+}
+
+func TestFinalizeChallengeStoresStandings(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+	bob := CreateTestUser(t, db, "bob")
+
+	start := time.Now().Add(-48 * time.Hour).UTC().Truncate(time.Hour)
+	c, err := models.CreateChallenge(db, models.Challenge{
+		CreatorID: alice,
+		Title:     "focus week",
+		Metric:    "total_minutes",
+		StartsAt:  start,
+		EndsAt:    time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, userID := range []int{alice, bob} {
+		if err := models.JoinChallenge(db, c.ID, userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := models.CreateSession(db, bob, nil, start.Add(time.Hour), start.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.CreateSession(db, alice, nil, start.Add(time.Hour), start.Add(90*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := models.FinalizeChallenge(db, c.ID); err != nil {
+		t.Fatal(err)
+	}
+	// A second call is a no-op
+	if err := models.FinalizeChallenge(db, c.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	standings, err := models.GetFinalStandings(db, c.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(standings) != 2 || standings[0].UserID != bob || standings[0].Score != 60 || standings[1].Score != 30 {
+		t.Errorf("final standings = %+v, want bob (60) then alice (30)", standings)
+	}
+}
+
+func TestLeaveEndedChallenge(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+
+	// Ended but not finalized yet: leaving would drop alice from the standings
+	c, err := models.CreateChallenge(db, models.Challenge{
+		CreatorID: alice,
+		Title:     "focus week",
+		Metric:    "total_minutes",
+		StartsAt:  time.Now().Add(-48 * time.Hour),
+		EndsAt:    time.Now().Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.JoinChallenge(db, c.ID, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &handlers.ChallengeHandler{DB: db}
+	w := httptest.NewRecorder()
+	h.LeaveChallenge(w, AuthedRequest("POST", "/challenges/x/leave", "", alice, "id", strconv.Itoa(c.ID)))
+	if w.Code != http.StatusConflict {
+		t.Errorf("leave after the end: %d, want 409", w.Code)
+	}
+	if joined, _ := models.IsChallengeParticipant(db, c.ID, alice); !joined {
+		t.Error("alice left an ended challenge")
+	}
+}
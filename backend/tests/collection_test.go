@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"tomo/backend/handlers"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+func TestValidateCollectionOrder(t *testing.T) {
+	current := []int{4, 7, 9}
+
+	valid := [][]int{
+		{4, 7, 9},
+		{9, 4, 7},
+	}
+	for _, order := range valid {
+		if err := models.ValidateCollectionOrder(current, order); err != nil {
+			t.Errorf("ValidateCollectionOrder(%v) = %v, want nil", order, err)
+		}
+	}
+
+	invalid := [][]int{
+		{4, 7},       // missing a post
+		{4, 7, 9, 1}, // extra post
+		{4, 4, 9},    // duplicate
+		{4, 7, 8},    // post not in the collection
+		nil,
+	}
+	for _, order := range invalid {
+		if err := models.ValidateCollectionOrder(current, order); err != models.ErrCollectionOrder {
+			t.Errorf("ValidateCollectionOrder(%v) = %v, want ErrCollectionOrder", order, err)
+		}
+	}
+
+	if err := models.ValidateCollectionOrder(nil, []int{}); err != nil {
+		t.Errorf("empty collection: got %v, want nil", err)
+	}
+}
+
+func TestPinPostRules(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+
+	newPost := func(visibility, status string) models.Post {
+		t.Helper()
+		post, err := models.CreatePost(db, models.Post{UserID: alice, PostType: "general", Content: "hi", Visibility: visibility, Status: status}, utils.MarkdownOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return post
+	}
+
+	for _, p := range []models.Post{newPost("private", "published"), newPost("unlisted", "published"), newPost("public", "draft")} {
+		if err := models.PinPost(db, alice, p.ID); err != models.ErrNotPinnable {
+			t.Errorf("pin %s %s post: err = %v, want ErrNotPinnable", p.Visibility, p.Status, err)
+		}
+	}
+
+	var pinned []models.Post
+	for i := 0; i < models.MaxPinnedPosts; i++ {
+		p := newPost("public", "published")
+		if err := models.PinPost(db, alice, p.ID); err != nil {
+			t.Fatalf("pin %d: %v", i+1, err)
+		}
+		pinned = append(pinned, p)
+	}
+	// Pinning an already pinned post doesn't count against the limit
+	if err := models.PinPost(db, alice, pinned[0].ID); err != nil {
+		t.Errorf("re-pinning a pinned post: %v", err)
+	}
+
+	extra := newPost("public", "published")
+	h := &handlers.PostHandler{DB: db}
+	id := strconv.Itoa(extra.ID)
+	w := httptest.NewRecorder()
+	h.PinPost(w, AuthedRequest(http.MethodPost, "/posts/"+id+"/pin", "", alice, "id", id))
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), strconv.Itoa(models.MaxPinnedPosts)) {
+		t.Errorf("pin over the limit: status %d %q, want 409 naming the limit", w.Code, w.Body)
+	}
+
+	posts, err := models.GetPinnedPosts(db, alice, 0)
+	if err != nil || len(posts) != models.MaxPinnedPosts {
+		t.Errorf("GetPinnedPosts = %d posts, %v; want %d", len(posts), err, models.MaxPinnedPosts)
+	}
+}
+
+func TestCollectionVisibility(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+	bob := CreateTestUser(t, db, "bob")
+	carol := CreateTestUser(t, db, "carol")
+
+	public, _ := models.CreatePost(db, models.Post{UserID: alice, PostType: "general", Content: "public", Visibility: "public"}, utils.MarkdownOptions{})
+	private, _ := models.CreatePost(db, models.Post{UserID: alice, PostType: "general", Content: "private", Visibility: "private"}, utils.MarkdownOptions{})
+
+	shared, err := models.CreateCollection(db, models.Collection{UserID: alice, Name: "shared", Visibility: "public"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := models.CreateCollection(db, models.Collection{UserID: alice, Name: "secret", Visibility: "private"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []models.Collection{shared, secret} {
+		models.AddPostToCollection(db, c.ID, public.ID)
+		models.AddPostToCollection(db, c.ID, private.ID)
+	}
+	models.BlockUser(db, carol, alice)
+
+	h := &handlers.CollectionHandler{DB: db}
+	get := func(c models.Collection, viewerID int) *httptest.ResponseRecorder {
+		id := strconv.Itoa(c.ID)
+		w := httptest.NewRecorder()
+		h.GetCollection(w, AuthedRequest(http.MethodGet, "/collections/"+id, "", viewerID, "id", id))
+		return w
+	}
+
+	if w := get(secret, bob); w.Code != http.StatusNotFound {
+		t.Errorf("private collection for another user: status %d, want 404", w.Code)
+	}
+	if w := get(shared, carol); w.Code != http.StatusNotFound {
+		t.Errorf("public collection across a block: status %d, want 404", w.Code)
+	}
+	if w := get(secret, alice); w.Code != http.StatusOK {
+		t.Errorf("private collection for its owner: status %d, want 200", w.Code)
+	}
+
+	// Other viewers only see the posts a profile would show
+	posts, err := models.GetCollectionPosts(db, shared, bob)
+	if err != nil || len(posts) != 1 || posts[0].ID != public.ID {
+		t.Errorf("bob sees %d posts, %v; want only the public one", len(posts), err)
+	}
+	if posts, _ := models.GetCollectionPosts(db, shared, alice); len(posts) != 2 {
+		t.Errorf("owner sees %d posts, want 2", len(posts))
+	}
+	if c, _ := models.GetCollectionByID(db, shared.ID, bob); c.PostCount != 1 {
+		t.Errorf("post_count for bob = %d, want 1", c.PostCount)
+	}
+
+	collections, err := models.GetCollectionsForUser(db, alice, bob)
+	if err != nil || len(collections) != 1 || collections[0].ID != shared.ID {
+		t.Errorf("bob lists %d of alice's collections, %v; want only the public one", len(collections), err)
+	}
+}
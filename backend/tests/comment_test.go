@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"testing"
+
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+func TestCommentCountMatchesWhatViewerSees(t *testing.T) {
+	db := OpenTestDB(t)
+	author := CreateTestUser(t, db, "author")
+	bob := CreateTestUser(t, db, "bob")
+	carol := CreateTestUser(t, db, "carol")
+	dave := CreateTestUser(t, db, "dave")
+
+	post, err := models.CreatePost(db, models.Post{UserID: author, PostType: "general", Content: "hi", Visibility: "public"}, utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobs, err := models.CreateComment(db, post.ID, bob, nil, "from bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.CreateComment(db, post.ID, carol, &bobs.ID, "reply to bob"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.CreateComment(db, post.ID, carol, nil, "from carol"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.CreateComment(db, post.ID, dave, nil, "from dave"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE users SET suspended_at=NOW() WHERE id=$1`, dave); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.BlockUser(db, author, bob); err != nil {
+		t.Fatal(err)
+	}
+
+	countAndThreads := func(viewer int) (int, int) {
+		count, err := models.CountCommentsForPost(db, post.ID, viewer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		threads, err := models.GetCommentThreadsForPost(db, post.ID, viewer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shown := 0
+		for _, th := range threads {
+			shown += 1 + len(th.Replies)
+		}
+		return count, shown
+	}
+
+	// The author blocked bob: bob's comment and the reply under it are hidden
+	if count, shown := countAndThreads(author); count != 1 || shown != 1 {
+		t.Errorf("author: count %d, shown %d; want 1, 1", count, shown)
+	}
+	// Carol sees everything except the suspended user's comment
+	if count, shown := countAndThreads(carol); count != 3 || shown != 3 {
+		t.Errorf("carol: count %d, shown %d; want 3, 3", count, shown)
+	}
+
+	details, err := models.GetPostWithDetails(db, post.ID, author)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.CommentCount != 1 {
+		t.Errorf("PostWithDetails.CommentCount for author = %d, want 1", details.CommentCount)
+	}
+}
+
+func TestMuteHidesPostsFromFeedOnly(t *testing.T) {
+	db := OpenTestDB(t)
+	viewer := CreateTestUser(t, db, "viewer")
+	muted := CreateTestUser(t, db, "muted")
+
+	post, err := models.CreatePost(db, models.Post{UserID: muted, PostType: "general", Content: "hi", Visibility: "public"}, utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.CreateComment(db, post.ID, muted, nil, "my own comment"); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.MuteUser(db, viewer, muted); err != nil {
+		t.Fatal(err)
+	}
+
+	feed, err := models.GetFeed(db, viewer, 20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feed) != 0 {
+		t.Errorf("feed has %d posts from a muted user, want 0", len(feed))
+	}
+
+	// Muting isn't blocking: the post and its comments stay reachable
+	visible, err := models.CanViewPost(db, post, viewer)
+	if err != nil || !visible {
+		t.Errorf("CanViewPost = %v, %v; want true", visible, err)
+	}
+	if count, _ := models.CountCommentsForPost(db, post.ID, viewer); count != 1 {
+		t.Errorf("comment count = %d, want 1", count)
+	}
+
+	if err := models.UnmuteUser(db, viewer, muted); err != nil {
+		t.Fatal(err)
+	}
+	if feed, _ := models.GetFeed(db, viewer, 20, 0); len(feed) != 1 {
+		t.Errorf("feed after unmute has %d posts, want 1", len(feed))
+	}
+}
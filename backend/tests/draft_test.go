@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tomo/backend/events"
+	"tomo/backend/handlers"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+func TestPostCreatedOnlyForPublishedPosts(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+
+	bus := events.NewBus()
+	created := recordEvents(bus, events.PostCreated)
+	h := &handlers.PostHandler{DB: db, Events: bus}
+
+	publishAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	for _, body := range []string{
+		`{"post_type":"general","content":"still writing","status":"draft"}`,
+		`{"post_type":"general","content":"later","visibility":"public","publish_at":"` + publishAt + `"}`,
+		`{"post_type":"general","content":"done","visibility":"public"}`,
+	} {
+		w := httptest.NewRecorder()
+		h.CreatePost(w, AuthedRequest(http.MethodPost, "/posts", body, alice))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create %s: status %d: %s", body, w.Code, w.Body)
+		}
+	}
+
+	if len(*created) != 1 {
+		t.Fatalf("got %d PostCreated events, want 1 (drafts and scheduled posts wait for PostPublished)", len(*created))
+	}
+	post, _ := models.GetPostByID(db, (*created)[0].PostID)
+	if post.Content != "done" {
+		t.Errorf("PostCreated was for %q, want the published post", post.Content)
+	}
+}
+
+func TestPublishingSetsPublishedAt(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+
+	draft, err := models.CreatePost(db, models.Post{UserID: alice, PostType: "general", Content: "draft", Visibility: "public", Status: "draft"}, utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if draft.PublishedAt != nil {
+		t.Errorf("draft has published_at %v", draft.PublishedAt)
+	}
+
+	if err := models.PublishPost(db, draft.ID); err != nil {
+		t.Fatal(err)
+	}
+	post, _ := models.GetPostByID(db, draft.ID)
+	if post.Status != "published" || post.PublishedAt == nil {
+		t.Errorf("after publishing: status %s, published_at %v", post.Status, post.PublishedAt)
+	}
+	if err := models.PublishPost(db, draft.ID); err == nil {
+		t.Error("publishing twice should fail")
+	}
+
+	// Feeds sort by published_at, so a published post without one is rejected
+	if _, err := db.Exec(`UPDATE posts SET published_at = NULL WHERE id=$1`, draft.ID); err == nil {
+		t.Error("a published post was allowed to have no published_at")
+	}
+}
@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"testing"
+
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+func TestFeedAudience(t *testing.T) {
+	db := OpenTestDB(t)
+	author := CreateTestUser(t, db, "author")
+	reader := CreateTestUser(t, db, "reader")
+	muter := CreateTestUser(t, db, "muter")
+	blocker := CreateTestUser(t, db, "blocker")
+
+	if err := models.MuteUser(db, muter, author); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.BlockUser(db, blocker, author); err != nil {
+		t.Fatal(err)
+	}
+
+	public, err := models.CreatePost(db, models.Post{UserID: author, PostType: "general", Content: "hi", Visibility: "public"}, utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := models.CreatePost(db, models.Post{UserID: author, PostType: "general", Content: "hi", Visibility: "private"}, utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connected := []int{author, reader, muter, blocker}
+	audience, err := models.GetFeedAudience(db, public.ID, connected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(audience) != 1 || audience[0] != reader {
+		t.Errorf("audience of public post = %v, want [reader]", audience)
+	}
+
+	audience, err = models.GetFeedAudience(db, private.ID, connected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(audience) != 0 {
+		t.Errorf("audience of private post = %v, want none", audience)
+	}
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"tomo/backend/handlers"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+func TestFollowAndUnfollow(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+	bob := CreateTestUser(t, db, "bob")
+
+	created, err := models.FollowUser(db, alice, bob)
+	if err != nil || !created {
+		t.Fatalf("FollowUser = %v, %v; want true, nil", created, err)
+	}
+	if created, err := models.FollowUser(db, alice, bob); err != nil || created {
+		t.Fatalf("second FollowUser = %v, %v; want false, nil", created, err)
+	}
+
+	following, err := models.IsFollowing(db, alice, bob)
+	if err != nil || !following {
+		t.Fatalf("IsFollowing(alice, bob) = %v, %v; want true", following, err)
+	}
+	if following, _ := models.IsFollowing(db, bob, alice); following {
+		t.Error("follows must not be mutual")
+	}
+
+	followers, err := models.GetFollowers(db, bob)
+	if err != nil || len(followers) != 1 || followers[0].UserID != alice {
+		t.Errorf("GetFollowers(bob) = %+v, %v; want [alice]", followers, err)
+	}
+
+	if err := models.UnfollowUser(db, alice, bob); err != nil {
+		t.Fatal(err)
+	}
+	if following, _ := models.IsFollowing(db, alice, bob); following {
+		t.Error("still following after UnfollowUser")
+	}
+}
+
+func TestBlockRemovesAndPreventsFollows(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+	bob := CreateTestUser(t, db, "bob")
+
+	models.FollowUser(db, alice, bob)
+	models.FollowUser(db, bob, alice)
+	if err := models.BlockUser(db, bob, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pair := range [][2]int{{alice, bob}, {bob, alice}} {
+		if following, _ := models.IsFollowing(db, pair[0], pair[1]); following {
+			t.Errorf("follow %d -> %d survived the block", pair[0], pair[1])
+		}
+	}
+	if created, err := models.FollowUser(db, alice, bob); err != nil || created {
+		t.Errorf("FollowUser across a block = %v, %v; want false, nil", created, err)
+	}
+}
+
+func TestFollowersOnlyComments(t *testing.T) {
+	db := OpenTestDB(t)
+	author := CreateTestUser(t, db, "author")
+	follower := CreateTestUser(t, db, "follower")
+	stranger := CreateTestUser(t, db, "stranger")
+
+	post, err := models.CreatePost(db, models.Post{
+		UserID:            author,
+		PostType:          "general",
+		Content:           "hello",
+		Visibility:        "public",
+		CommentPermission: "followers",
+	}, utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.FollowUser(db, follower, author); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &handlers.CommentHandler{DB: db}
+	comment := func(userID int) int {
+		w := httptest.NewRecorder()
+		h.CreateComment(w, AuthedRequest(http.MethodPost, "/posts/x/comments", `{"content":"hi"}`, userID,
+			"id", strconv.Itoa(post.ID)))
+		return w.Code
+	}
+
+	if code := comment(follower); code != http.StatusCreated {
+		t.Errorf("follower: status %d, want %d", code, http.StatusCreated)
+	}
+	if code := comment(author); code != http.StatusCreated {
+		t.Errorf("author: status %d, want %d", code, http.StatusCreated)
+	}
+	if code := comment(stranger); code != http.StatusForbidden {
+		t.Errorf("stranger: status %d, want %d", code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,33 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"tomo/backend/models"
+)
+
+func TestCalendarDate(t *testing.T) {
+	// 03:30 UTC is still the previous evening on the US west coast
+	instant := time.Date(2025, 3, 14, 3, 30, 0, 0, time.UTC)
+
+	cases := map[string]string{
+		"UTC":                 "2025-03-14",
+		"America/Los_Angeles": "2025-03-13",
+		"Asia/Tokyo":          "2025-03-14",
+		"Pacific/Kiritimati":  "2025-03-14",
+	}
+	for tz, want := range cases {
+		got, err := models.CalendarDate(instant, tz)
+		if err != nil {
+			t.Fatalf("CalendarDate(%s): %v", tz, err)
+		}
+		if got != want {
+			t.Errorf("CalendarDate(%s) = %s, want %s", tz, got, want)
+		}
+	}
+
+	if _, err := models.CalendarDate(instant, "Mars/Olympus_Mons"); err == nil {
+		t.Error("expected an error for an unknown timezone")
+	}
+}
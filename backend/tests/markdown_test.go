@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"tomo/backend/jobs"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+const testImageOrigin = "https://tomo-media.s3.us-east-1.amazonaws.com/"
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    []string // substrings that must appear
+		notWant []string // substrings that must not appear
+	}{
+		{
+			name: "formatting",
+			in:   "# Focus\n\n**deep** work\nnew line",
+			want: []string{"<h1>Focus</h1>", "<strong>deep</strong>", "work<br>"},
+		},
+		{
+			name:    "raw html is dropped",
+			in:      "hi <script>alert(1)</script> <b onclick=\"x()\">there</b>",
+			notWant: []string{"<script", "onclick", "alert(1)</script>"},
+		},
+		{
+			name:    "javascript links are removed",
+			in:      "[click](javascript:alert(1))",
+			notWant: []string{"javascript:"},
+		},
+		{
+			name:    "javascript links with spacing tricks are removed",
+			in:      "[click]( JaVaScRiPt:alert(1))",
+			notWant: []string{"alert", "href"},
+		},
+		{
+			name:    "relative links are removed",
+			in:      "[x](/admin) [y](../settings) [z](//evil.example.com/x)",
+			notWant: []string{"/admin", "../settings", "evil.example.com"},
+		},
+		{
+			name: "external links are safe",
+			in:   "[docs](https://example.com)",
+			want: []string{`href="https://example.com"`, "nofollow", "noopener", `target="_blank"`},
+		},
+		{
+			name: "images from our storage are kept",
+			in:   "![desk](" + testImageOrigin + "uploads/desk.jpg)",
+			want: []string{`src="` + testImageOrigin + `uploads/desk.jpg"`},
+		},
+		{
+			name:    "images from elsewhere are stripped",
+			in:      "![track](https://evil.example.com/pixel.gif)",
+			notWant: []string{"evil.example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := utils.RenderMarkdown(tt.in, utils.MarkdownOptions{ImageOrigin: testImageOrigin})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		for _, s := range tt.want {
+			if !strings.Contains(got, s) {
+				t.Errorf("%s: output %q missing %q", tt.name, got, s)
+			}
+		}
+		for _, s := range tt.notWant {
+			if strings.Contains(got, s) {
+				t.Errorf("%s: output %q should not contain %q", tt.name, got, s)
+			}
+		}
+	}
+}
+
+func TestRenderMarkdownWithoutStorageDropsImages(t *testing.T) {
+	got, err := utils.RenderMarkdown("![desk]("+testImageOrigin+"uploads/desk.jpg)", utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(got, "src=") {
+		t.Errorf("expected no image source, got %q", got)
+	}
+}
+
+func TestRenderMarkdownLinksMentions(t *testing.T) {
+	opts := utils.MarkdownOptions{ProfileURL: "https://tomo.example/users/"}
+	got, err := utils.RenderMarkdown("thanks @alice. and @bob_2, not `@code` or dave@example.com\n\n```\n@fenced\n```\n[@carol](https://example.com)", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		`href="https://tomo.example/users/alice"`, ">@alice</a>.",
+		`href="https://tomo.example/users/bob_2"`, ">@bob_2</a>",
+		"<code>@code</code>", "dave@example.com", "@fenced",
+		`<a href="https://example.com"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output %q missing %q", got, want)
+		}
+	}
+	for _, notWant := range []string{"users/code", "users/example", "users/fenced", "users/carol"} {
+		if strings.Contains(got, notWant) {
+			t.Errorf("output %q should not contain %q", got, notWant)
+		}
+	}
+
+	// Without a profile URL mentions stay plain text
+	if got, _ := utils.RenderMarkdown("hi @alice", utils.MarkdownOptions{}); strings.Contains(got, "<a") {
+		t.Errorf("got a link without ProfileURL: %q", got)
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		max  int
+		want string
+	}{
+		{"strips tags", "<h1>Morning</h1>\n<p>Slow <em>start</em> today.</p>", 100, "Morning Slow start today."},
+		{"unescapes entities", "<p>tea &amp; toast</p>", 100, "tea & toast"},
+		{"cuts at a word boundary", "<p>one two three four</p>", 10, "one two…"},
+		{"drops trailing punctuation", "<p>first, second third</p>", 12, "first…"},
+		{"counts runes not bytes", "<p>日本語のテキスト</p>", 3, "日本語…"},
+		{"empty", "", 10, ""},
+	}
+
+	for _, tt := range tests {
+		if got := utils.Excerpt(tt.in, tt.max); got != tt.want {
+			t.Errorf("%s: Excerpt(%q, %d) = %q, want %q", tt.name, tt.in, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestRefreshContentHTMLJob(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+
+	post, err := models.CreatePost(db, models.Post{UserID: alice, PostType: "general", Content: "[x](/admin) **hi**", Visibility: "public"}, utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Simulate HTML cached by an older renderer
+	db.Exec(`UPDATE posts SET content_html='<a href="/admin">x</a>', content_html_version=0 WHERE id=$1`, post.ID)
+
+	// Reads serve the cached HTML until the job re-renders it
+	if _, err := models.GetPostWithDetails(db, post.ID, alice); err != nil {
+		t.Fatal(err)
+	}
+	var version int
+	db.QueryRow(`SELECT content_html_version FROM posts WHERE id=$1`, post.ID).Scan(&version)
+	if version != 0 {
+		t.Errorf("a read saved the re-rendered HTML (version %d)", version)
+	}
+
+	if err := jobs.RefreshContentHTML(db, utils.MarkdownOptions{})(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var html string
+	db.QueryRow(`SELECT content_html, content_html_version FROM posts WHERE id=$1`, post.ID).Scan(&html, &version)
+	if version != utils.MarkdownRenderVersion || strings.Contains(html, "/admin") || !strings.Contains(html, "<strong>hi</strong>") {
+		t.Errorf("after the job: version %d, html %q", version, html)
+	}
+}
@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"tomo/backend/handlers"
+	"tomo/backend/models"
+	"tomo/backend/storage"
+	"tomo/backend/utils"
+)
+
+func TestUploadMediaType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		mediaType   string
+		ext         string
+		ok          bool
+	}{
+		{"image/jpeg", "image", ".jpg", true},
+		{"image/webp", "image", ".webp", true},
+		{"video/quicktime", "video", ".mov", true},
+		{"video/webm", "video", ".webm", true},
+		{"image/svg+xml", "", "", false}, // can carry scripts
+		{"application/pdf", "", "", false},
+		{"", "", "", false},
+	}
+
+	for _, tt := range tests {
+		mediaType, ext, ok := models.UploadMediaType(tt.contentType)
+		if mediaType != tt.mediaType || ext != tt.ext || ok != tt.ok {
+			t.Errorf("UploadMediaType(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				tt.contentType, mediaType, ext, ok, tt.mediaType, tt.ext, tt.ok)
+		}
+	}
+}
+
+// completeUpload stores data under key for a new pending media item on postID
+// and completes it through the handler, returning the activated media
+func completeUpload(t *testing.T, db *sql.DB, store storage.Storage, userID, postID int, key, data string) models.PostMedia {
+	t.Helper()
+	fileURL := func(key string) string { return storage.URL(store, key) }
+	pending, err := models.CreatePendingMedia(db, models.PostMedia{
+		PostID: postID, UserID: userID, MediaType: "image", ContentType: "image/jpeg", SizeBytes: int64(len(data)),
+	}, key, time.Now().Add(time.Minute), fileURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(context.Background(), key, strings.NewReader(data), int64(len(data)), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &handlers.MediaHandler{DB: db, Storage: store}
+	w := httptest.NewRecorder()
+	h.CompleteMediaUpload(w, AuthedRequest("POST", "/media/x/complete", "", userID, "id", strconv.Itoa(pending.ID)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("complete: %d %s", w.Code, w.Body.String())
+	}
+	var media models.PostMedia
+	if err := json.NewDecoder(w.Body).Decode(&media); err != nil {
+		t.Fatal(err)
+	}
+	return media
+}
+
+func TestCompleteMediaMovesUpload(t *testing.T) {
+	db := OpenTestDB(t)
+	store := newTestLocalStorage(t)
+	ctx := context.Background()
+	alice := CreateTestUser(t, db, "alice")
+	post, err := models.CreatePost(db, models.Post{UserID: alice, PostType: "general", Content: "hi", Visibility: "public"}, utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The presigned key is dropped; the media lives under a key only the server knows
+	first := completeUpload(t, db, store, alice, post.ID, "uploads/presigned-1.jpg", "same bytes")
+	if first.Status != "active" || first.FileURL == storage.URL(store, "uploads/presigned-1.jpg") {
+		t.Fatalf("completed media = %+v", first)
+	}
+	if _, err := store.Stat(ctx, "uploads/presigned-1.jpg"); err != storage.ErrNotFound {
+		t.Errorf("upload key still stored: %v", err)
+	}
+	key, _ := storage.KeyFromURL(store, first.FileURL)
+	if _, err := store.Stat(ctx, key); err != nil {
+		t.Fatalf("final copy missing: %v", err)
+	}
+
+	// The same contents again share the first object
+	second := completeUpload(t, db, store, alice, post.ID, "uploads/presigned-2.jpg", "same bytes")
+	if second.FileURL != first.FileURL {
+		t.Errorf("duplicate upload got %q, want %q", second.FileURL, first.FileURL)
+	}
+	var refs int
+	db.QueryRow(`SELECT ref_count FROM media_objects WHERE storage_key=$1`, key).Scan(&refs)
+	if refs != 2 {
+		t.Errorf("ref_count = %d, want 2", refs)
+	}
+	if _, err := store.Stat(ctx, "uploads/presigned-2.jpg"); err != storage.ErrNotFound {
+		t.Errorf("second upload key still stored: %v", err)
+	}
+}
+
+func TestDeleteSessionAndUserReturnMediaKeys(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+	fileURL := func(key string) string { return "https://files.example.com/" + key }
+
+	addMedia := func(post models.Post, key, sum string) {
+		t.Helper()
+		obj := models.MediaObject{UserID: alice, StorageKey: key, SHA256: sum, SizeBytes: 1, ContentType: "image/jpeg"}
+		if _, _, err := models.AddMediaToPost(db, models.PostMedia{PostID: post.ID, UserID: alice, MediaType: "image"}, obj, fileURL); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	session, err := models.CreateSession(db, alice, nil, time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionPost, err := models.CreatePost(db, models.Post{UserID: alice, SessionID: &session.ID, PostType: "session", Content: "focus", Visibility: "public"}, utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := models.CreatePost(db, models.Post{UserID: alice, PostType: "general", Content: "hi", Visibility: "public"}, utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addMedia(sessionPost, "uploads/session.jpg", "aaa")
+	addMedia(sessionPost, "uploads/shared.jpg", "bbb")
+	addMedia(other, "uploads/shared-copy.jpg", "bbb") // reuses the shared object
+	addMedia(other, "uploads/other.jpg", "ccc")
+
+	// Files still used by another post are kept
+	unused, err := models.DeleteSession(db, session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unused, []string{"uploads/session.jpg"}) {
+		t.Errorf("DeleteSession freed %v, want [uploads/session.jpg]", unused)
+	}
+
+	unused, err = models.DeleteUser(db, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(unused)
+	if want := []string{"uploads/other.jpg", "uploads/shared.jpg"}; !reflect.DeepEqual(unused, want) {
+		t.Errorf("DeleteUser freed %v, want %v", unused, want)
+	}
+}
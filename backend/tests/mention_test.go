@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"reflect"
+	"testing"
+
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+func TestParseMentions(t *testing.T) {
+	cases := []struct {
+		content string
+		want    []string
+	}{
+		{"thanks @alice and @bob_2!", []string{"alice", "bob_2"}},
+		{"ran with @alice. Then @alice again", []string{"alice"}},
+		{"(cc @carol)", []string{"carol"}},
+		{"mail me at dave@example.com", nil},
+		{"use `@decorator` here", nil},
+		{"```\n@inside code\n```\n@outside", []string{"outside"}},
+		{"just an @ sign", nil},
+	}
+	for _, c := range cases {
+		if got := models.ParseMentions(c.content); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ParseMentions(%q) = %v, want %v", c.content, got, c.want)
+		}
+	}
+}
+
+func TestParseHashtags(t *testing.T) {
+	cases := []struct {
+		content string
+		want    []string
+	}{
+		{"morning run #Running #focus", []string{"running", "focus"}},
+		{"#focus then #FOCUS again", []string{"focus"}},
+		{"day #1 of #deep-work-", []string{"deep-work"}},
+		{"see [notes](#section) and page#anchor", nil},
+		{"`#not-a-tag` but #tag", []string{"tag"}},
+	}
+	for _, c := range cases {
+		if got := models.ParseHashtags(c.content); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ParseHashtags(%q) = %v, want %v", c.content, got, c.want)
+		}
+	}
+}
+
+func TestMentionsHiddenAcrossBlocks(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+	bob := CreateTestUser(t, db, "bob")
+	carol := CreateTestUser(t, db, "carol")
+	dave := CreateTestUser(t, db, "dave")
+
+	post, err := models.CreatePost(db, models.Post{UserID: alice, PostType: "general", Content: "with @bob and @carol", Visibility: "public"}, utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.SetPostMentions(db, post.ID, alice, models.ParseMentions(post.Content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.BlockUser(db, carol, dave); err != nil {
+		t.Fatal(err)
+	}
+
+	usernames := func(viewerID int) []string {
+		details, err := models.GetPostWithDetails(db, post.ID, viewerID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, m := range details.Mentions {
+			names = append(names, m.Username)
+		}
+		return names
+	}
+
+	if got := usernames(alice); !reflect.DeepEqual(got, []string{"bob", "carol"}) {
+		t.Errorf("author sees mentions %v, want [bob carol]", got)
+	}
+	if got := usernames(dave); !reflect.DeepEqual(got, []string{"bob"}) {
+		t.Errorf("dave sees mentions %v, want [bob] (carol blocked dave)", got)
+	}
+	if got := usernames(bob); !reflect.DeepEqual(got, []string{"bob", "carol"}) {
+		t.Errorf("bob sees mentions %v, want [bob carol]", got)
+	}
+}
+
+func TestMentionsForPostsFanOut(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+	CreateTestUser(t, db, "bob")
+	CreateTestUser(t, db, "carol")
+
+	var postIDs []int
+	for _, content := range []string{"hi @bob", "hi @carol and @bob", "no one"} {
+		post, err := models.CreatePost(db, models.Post{UserID: alice, PostType: "general", Content: content, Visibility: "public"}, utils.MarkdownOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := models.SetPostMentions(db, post.ID, alice, models.ParseMentions(post.Content)); err != nil {
+			t.Fatal(err)
+		}
+		postIDs = append(postIDs, post.ID)
+	}
+
+	mentions, err := models.GetMentionsForPosts(db, postIDs, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int][]string{postIDs[0]: {"bob"}, postIDs[1]: {"bob", "carol"}}
+	got := map[int][]string{}
+	for postID, ms := range mentions {
+		for _, m := range ms {
+			got[postID] = append(got[postID], m.Username)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mentions by post = %v, want %v", got, want)
+	}
+}
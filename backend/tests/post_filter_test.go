@@ -0,0 +1,29 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"tomo/backend/models"
+)
+
+func TestPostCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2025, 3, 14, 9, 26, 53, 589793000, time.UTC)
+	cursor := models.EncodePostCursor(createdAt, 42)
+
+	gotTime, gotID, err := models.DecodePostCursor(cursor)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !gotTime.Equal(createdAt) || gotID != 42 {
+		t.Errorf("got (%v, %d), want (%v, 42)", gotTime, gotID, createdAt)
+	}
+}
+
+func TestDecodePostCursorRejectsGarbage(t *testing.T) {
+	for _, c := range []string{"not base64!", "bm9jb2xvbg", "YWJjOjEy"} {
+		if _, _, err := models.DecodePostCursor(c); err != models.ErrInvalidCursor {
+			t.Errorf("DecodePostCursor(%q): got %v, want ErrInvalidCursor", c, err)
+		}
+	}
+}
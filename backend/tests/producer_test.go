@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"tomo/backend/events"
+	"tomo/backend/handlers"
+	"tomo/backend/models"
+	"tomo/backend/notifications"
+	"tomo/backend/utils"
+)
+
+// recordEvents subscribes to t on bus and returns the events seen so far
+func recordEvents(bus *events.Bus, t events.Type) *[]events.Event {
+	var seen []events.Event
+	bus.Subscribe(t, func(e events.Event) { seen = append(seen, e) })
+	return &seen
+}
+
+func TestFollowPublishesUserFollowed(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+	bob := CreateTestUser(t, db, "bob")
+
+	bus := events.NewBus()
+	notifications.Register(bus, db)
+	seen := recordEvents(bus, events.UserFollowed)
+
+	h := &handlers.UserHandler{DB: db, Events: bus}
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		h.FollowUser(w, AuthedRequest(http.MethodPost, "/users/bob/follow", "", alice, "username", "bob"))
+		if w.Code != http.StatusOK {
+			t.Fatalf("follow: status %d: %s", w.Code, w.Body)
+		}
+	}
+
+	if len(*seen) != 1 {
+		t.Fatalf("got %d UserFollowed events, want 1 (re-following is a no-op)", len(*seen))
+	}
+	if e := (*seen)[0]; e.ActorID != alice || e.UserID != bob {
+		t.Errorf("event = %+v, want actor alice and user bob", e)
+	}
+
+	groups, err := models.GetNotificationGroups(db, bob, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].Type != "follow" {
+		t.Errorf("bob's notifications = %+v, want one follow", groups)
+	}
+}
+
+func TestReactionPublishesPostReacted(t *testing.T) {
+	db := OpenTestDB(t)
+	author := CreateTestUser(t, db, "author")
+	reader := CreateTestUser(t, db, "reader")
+
+	post, err := models.CreatePost(db, models.Post{UserID: author, PostType: "general", Content: "hello", Visibility: "public"}, utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus := events.NewBus()
+	notifications.Register(bus, db)
+	seen := recordEvents(bus, events.PostReacted)
+
+	h := &handlers.PostHandler{DB: db, Events: bus}
+	react := func(kind string) int {
+		w := httptest.NewRecorder()
+		h.SetReaction(w, AuthedRequest(http.MethodPut, "/posts/x/reaction", `{"kind":"`+kind+`"}`, reader,
+			"id", strconv.Itoa(post.ID)))
+		return w.Code
+	}
+
+	if code := react("heart"); code != http.StatusOK {
+		t.Fatalf("react: status %d", code)
+	}
+	if code := react("clap"); code != http.StatusOK {
+		t.Fatalf("change reaction: status %d", code)
+	}
+	if code := react("nope"); code != http.StatusBadRequest {
+		t.Errorf("unknown kind: status %d, want %d", code, http.StatusBadRequest)
+	}
+
+	if len(*seen) != 1 || (*seen)[0].PostID != post.ID || (*seen)[0].ActorID != reader {
+		t.Fatalf("PostReacted events = %+v, want one for the post by reader", *seen)
+	}
+
+	reactions, err := models.GetPostReactions(db, post.ID, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reactions.Mine != "clap" || reactions.Counts["clap"] != 1 || reactions.Counts["heart"] != 0 {
+		t.Errorf("reactions = %+v, want a single clap", reactions)
+	}
+
+	groups, err := models.GetNotificationGroups(db, author, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0].Type != "reaction" {
+		t.Errorf("author's notifications = %+v, want one reaction", groups)
+	}
+}
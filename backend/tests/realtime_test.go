@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tomo/backend/middleware"
+	"tomo/backend/realtime"
+)
+
+func TestHubDeliversToAllClientsOfUser(t *testing.T) {
+	hub := realtime.NewHub()
+
+	phone := hub.Subscribe(1, 0)
+	defer phone.Close()
+	laptop := hub.Subscribe(1, 0)
+	defer laptop.Close()
+	other := hub.Subscribe(2, 0)
+	defer other.Close()
+
+	if err := hub.Publish(1, "session.created", map[string]int{"id": 7}); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+
+	for name, sub := range map[string]*realtime.Subscription{"phone": phone, "laptop": laptop} {
+		select {
+		case msg := <-sub.C:
+			if msg.Event != "session.created" || string(msg.Data) != `{"id":7}` {
+				t.Errorf("%s got unexpected message %+v", name, msg)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%s did not receive the message", name)
+		}
+	}
+
+	select {
+	case msg := <-other.C:
+		t.Errorf("other user received %+v", msg)
+	default:
+	}
+}
+
+func TestHubReplaysAfterLastEventID(t *testing.T) {
+	hub := realtime.NewHub()
+
+	first := hub.Subscribe(1, 0)
+	for i := 0; i < 3; i++ {
+		if err := hub.Publish(1, "notification", i); err != nil {
+			t.Fatalf("failed to publish: %v", err)
+		}
+	}
+	seen := <-first.C
+	first.Close()
+	first.Close() // closing twice must be safe
+
+	resumed := hub.Subscribe(1, seen.ID)
+	defer resumed.Close()
+
+	if resumed.Gap {
+		t.Error("expected no gap when resuming from a buffered event")
+	}
+	if len(resumed.Replay) != 2 {
+		t.Fatalf("expected 2 replayed messages, got %d", len(resumed.Replay))
+	}
+	if resumed.Replay[0].ID <= seen.ID || resumed.Replay[1].ID <= resumed.Replay[0].ID {
+		t.Errorf("replayed IDs out of order: %d, %d after %d", resumed.Replay[0].ID, resumed.Replay[1].ID, seen.ID)
+	}
+
+	stale := hub.Subscribe(1, 1)
+	defer stale.Close()
+	if !stale.Gap {
+		t.Error("expected a gap when resuming from an ID older than this process")
+	}
+}
+
+func TestHubExpiresOldHistory(t *testing.T) {
+	hub := realtime.NewHub()
+	hub.HistoryMaxAge = 20 * time.Millisecond
+
+	first := hub.Subscribe(1, 0)
+	if err := hub.Publish(1, "notification", 1); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	seen := <-first.C
+	first.Close()
+	if err := hub.Publish(1, "notification", 2); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	// Publishing for someone else sweeps expired history
+	if err := hub.Publish(2, "notification", 3); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+
+	resumed := hub.Subscribe(1, seen.ID)
+	defer resumed.Close()
+	if len(resumed.Replay) != 0 {
+		t.Errorf("expected expired messages not to be replayed, got %d", len(resumed.Replay))
+	}
+	if !resumed.Gap {
+		t.Error("expected a gap after unseen messages expired")
+	}
+}
+
+func TestHubUsers(t *testing.T) {
+	hub := realtime.NewHub()
+
+	a := hub.Subscribe(1, 0)
+	b := hub.Subscribe(1, 0)
+	c := hub.Subscribe(2, 0)
+	c.Close()
+
+	users := hub.Users()
+	if len(users) != 1 || users[0] != 1 {
+		t.Errorf("Users() = %v, want [1]", users)
+	}
+
+	a.Close()
+	b.Close()
+	if users := hub.Users(); len(users) != 0 {
+		t.Errorf("Users() after all clients left = %v, want []", users)
+	}
+}
+
+func TestStreamTicketsAreSingleUse(t *testing.T) {
+	db := OpenTestDB(t)
+	middleware.UseDB(db)
+	userID := CreateTestUser(t, db, "streamer")
+
+	ticket, err := middleware.IssueStreamTicket(middleware.UserClaims{UserID: userID, Role: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotUser int
+	handler := middleware.StreamAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUser = r.Context().Value(middleware.UserContextKey).(middleware.UserClaims).UserID
+	}))
+	open := func(query string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events"+query, nil))
+		return w.Code
+	}
+
+	if code := open("?ticket=" + ticket); code != http.StatusOK || gotUser != userID {
+		t.Fatalf("first use: status %d, user %d; want 200 as user %d", code, gotUser, userID)
+	}
+	if code := open("?ticket=" + ticket); code != http.StatusUnauthorized {
+		t.Errorf("second use: status %d, want 401", code)
+	}
+	if code := open("?ticket=made-up"); code != http.StatusUnauthorized {
+		t.Errorf("unknown ticket: status %d, want 401", code)
+	}
+	if code := open("?access_token=whatever"); code != http.StatusUnauthorized {
+		t.Errorf("access_token: status %d, want 401", code)
+	}
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"tomo/backend/handlers"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+func TestRevisionsAndEditedFlag(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+
+	draft := createTaggedPost(t, db, alice, "draft")
+	draft.Content = "second draft"
+	if err := models.UpdatePost(db, draft, utils.MarkdownOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if revisions, _ := models.GetRevisionsForPost(db, draft.ID); len(revisions) != 0 {
+		t.Errorf("draft edits saved %d revisions, want none", len(revisions))
+	}
+
+	post := createTaggedPost(t, db, alice, "published")
+	if post.Edited {
+		t.Error("a new post must not be marked edited")
+	}
+
+	// Settings-only changes are not edits
+	post.Visibility = "unlisted"
+	if err := models.UpdatePost(db, post, utils.MarkdownOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := models.GetPostByID(db, post.ID)
+	if stored.Edited {
+		t.Error("changing visibility marked the post edited")
+	}
+	if revisions, _ := models.GetRevisionsForPost(db, post.ID); len(revisions) != 0 {
+		t.Errorf("visibility change saved %d revisions, want none", len(revisions))
+	}
+
+	post.Content = "second version"
+	if err := models.UpdatePost(db, post, utils.MarkdownOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ = models.GetPostByID(db, post.ID)
+	if !stored.Edited {
+		t.Error("content change did not mark the post edited")
+	}
+	revisions, err := models.GetRevisionsForPost(db, post.ID)
+	if err != nil || len(revisions) != 1 || revisions[0].Content != "first version" {
+		t.Fatalf("revisions = %+v, %v; want the first version", revisions, err)
+	}
+}
+
+func TestRestorePostRevision(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+	bob := CreateTestUser(t, db, "bob")
+	post := createTaggedPost(t, db, alice, "published")
+
+	post.Content = "second version #later"
+	if err := models.UpdatePost(db, post, utils.MarkdownOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	revisions, _ := models.GetRevisionsForPost(db, post.ID)
+	if len(revisions) != 1 {
+		t.Fatalf("got %d revisions, want 1", len(revisions))
+	}
+
+	h := &handlers.PostHandler{DB: db}
+	id, revID := strconv.Itoa(post.ID), strconv.Itoa(revisions[0].ID)
+	target := "/posts/" + id + "/revisions/" + revID + "/restore"
+
+	w := httptest.NewRecorder()
+	h.RestorePostRevision(w, AuthedRequest("POST", target, "", bob, "id", id, "revisionId", revID))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("restore by another user: status %d, want 403", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.RestorePostRevision(w, AuthedRequest("POST", target, "", alice, "id", id, "revisionId", revID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("restore: status %d: %s", w.Code, w.Body.String())
+	}
+
+	stored, _ := models.GetPostByID(db, post.ID)
+	if stored.Content != "first version" || !stored.Edited {
+		t.Errorf("after restore: content %q, edited %v; want the first version, edited", stored.Content, stored.Edited)
+	}
+
+	// The replaced version is kept, so a restore can itself be undone
+	revisions, _ = models.GetRevisionsForPost(db, post.ID)
+	if len(revisions) != 2 || revisions[0].Content != "second version #later" {
+		t.Errorf("revisions after restore = %+v, want the replaced version first", revisions)
+	}
+}
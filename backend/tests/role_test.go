@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+func TestRequireRole(t *testing.T) {
+	db := OpenTestDB(t)
+	middleware.UseDB(db)
+	admin := CreateTestUser(t, db, "admin")
+	user := CreateTestUser(t, db, "user")
+	if _, err := db.Exec(`UPDATE users SET role='admin' WHERE id=$1`, admin); err != nil {
+		t.Fatal(err)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := middleware.AuthMiddleware(middleware.RequireRole("moderator", "admin")(ok))
+
+	adminToken, err := utils.CreateToken(admin, "admin@example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	userToken, err := utils.CreateToken(user, "user@example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"admin", adminToken, http.StatusOK},
+		{"user", userToken, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/admin/reports", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: got status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestRoleComesFromDatabaseNotToken(t *testing.T) {
+	db := OpenTestDB(t)
+	middleware.UseDB(db)
+	userID := CreateTestUser(t, db, "climber")
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := middleware.AuthMiddleware(middleware.RequireRole("admin")(ok))
+	call := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/admin/stats", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// A validly signed token claiming admin grants nothing while the stored
+	// role is 'user'
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   userID,
+		"email": "climber@example.com",
+		"role":  "admin",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(os.Getenv("JWT_SECRET")))
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	if code := call(forged); code != http.StatusForbidden {
+		t.Errorf("admin claim, user in database: status %d, want 403", code)
+	}
+
+	// Promotion and demotion apply to a token issued before the change
+	token, err := utils.CreateToken(userID, "climber@example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	if err := models.SetUserRole(db, userID, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if code := call(token); code != http.StatusOK {
+		t.Errorf("after promotion: status %d, want 200", code)
+	}
+	if err := models.SetUserRole(db, userID, "user"); err != nil {
+		t.Fatal(err)
+	}
+	if code := call(token); code != http.StatusForbidden {
+		t.Errorf("after demotion: status %d, want 403", code)
+	}
+}
@@ -0,0 +1,248 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"tomo/backend/events"
+	"tomo/backend/handlers"
+	"tomo/backend/middleware"
+	"tomo/backend/models"
+	"tomo/backend/rooms"
+)
+
+// serveRoom runs a room over a test WebSocket server; clients pick their user with ?user=
+func serveRoom(t *testing.T, m *rooms.Manager, room models.FocusRoom) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		userID, _ := strconv.Atoi(ws.Request().URL.Query().Get("user"))
+		m.Serve(ws, room, models.User{ID: userID, Username: "user" + strconv.Itoa(userID)})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dialRoom(t *testing.T, srv *httptest.Server, userID int) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/?user=" + strconv.Itoa(userID)
+	ws, err := websocket.Dial(url, "", srv.URL)
+	if err != nil {
+		t.Fatalf("failed to connect user %d: %v", userID, err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+// readUntil reads messages until one of type typ arrives
+func readUntil(t *testing.T, ws *websocket.Conn, typ string) map[string]interface{} {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var msg map[string]interface{}
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			t.Fatalf("waiting for %q: %v", typ, err)
+		}
+		if msg["type"] == typ {
+			return msg
+		}
+	}
+}
+
+func TestRoomChatReachesEveryone(t *testing.T) {
+	m := rooms.NewManager(nil, events.NewBus())
+	srv := serveRoom(t, m, models.FocusRoom{ID: 1, OwnerID: 1})
+
+	owner := dialRoom(t, srv, 1)
+	readUntil(t, owner, "state")
+	guest := dialRoom(t, srv, 2)
+	readUntil(t, guest, "state")
+
+	websocket.JSON.Send(guest, map[string]string{"type": "chat", "text": "  hello  "})
+	for _, ws := range []*websocket.Conn{owner, guest} {
+		msg := readUntil(t, ws, "chat")
+		if text := msg["message"].(map[string]interface{})["text"]; text != "hello" {
+			t.Errorf("chat text = %q, want %q", text, "hello")
+		}
+	}
+
+	if n := len(m.Participants(1)); n != 2 {
+		t.Errorf("Participants = %d, want 2", n)
+	}
+}
+
+func TestRoomOnlyOwnerControlsTimer(t *testing.T) {
+	m := rooms.NewManager(nil, events.NewBus())
+	srv := serveRoom(t, m, models.FocusRoom{ID: 1, OwnerID: 1})
+
+	guest := dialRoom(t, srv, 2)
+	readUntil(t, guest, "state")
+	websocket.JSON.Send(guest, map[string]interface{}{"type": "timer.start", "duration_minutes": 25})
+	if msg := readUntil(t, guest, "error"); !strings.Contains(msg["message"].(string), "owner") {
+		t.Errorf("unexpected error %v", msg["message"])
+	}
+}
+
+func TestRoomKickAndCloseDisconnect(t *testing.T) {
+	m := rooms.NewManager(nil, events.NewBus())
+	srv := serveRoom(t, m, models.FocusRoom{ID: 1, OwnerID: 1})
+
+	owner := dialRoom(t, srv, 1)
+	readUntil(t, owner, "state")
+	guest := dialRoom(t, srv, 2)
+	readUntil(t, guest, "state")
+
+	m.Kick(1, 2)
+	// The guest's messages after the kick must be ignored, not sent to a closed client
+	websocket.JSON.Send(guest, map[string]string{"type": "chat", "text": "still here?"})
+	websocket.JSON.Send(guest, map[string]string{"type": "bogus"})
+	guest.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var msg map[string]interface{}
+		if err := websocket.JSON.Receive(guest, &msg); err != nil {
+			break
+		}
+		if msg["type"] == "chat" || msg["type"] == "error" {
+			t.Fatalf("kicked client received %v", msg)
+		}
+	}
+	if n := len(m.Participants(1)); n != 1 {
+		t.Errorf("Participants after kick = %d, want 1", n)
+	}
+
+	m.CloseRoom(1)
+	websocket.JSON.Send(owner, map[string]interface{}{"type": "timer.start", "duration_minutes": 25})
+	owner.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var msg map[string]interface{}
+		if err := websocket.JSON.Receive(owner, &msg); err != nil {
+			break
+		}
+		if msg["type"] == "timer" || msg["type"] == "error" {
+			t.Fatalf("closed room answered %v", msg)
+		}
+	}
+	if n := len(m.Participants(1)); n != 0 {
+		t.Errorf("Participants after close = %d, want 0", n)
+	}
+}
+
+func TestRoomTimerIsSavedAndCleared(t *testing.T) {
+	db := OpenTestDB(t)
+	ownerID := CreateTestUser(t, db, "owner")
+	room, err := models.CreateRoom(db, ownerID, "study", "ROOMCODE")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus := events.NewBus()
+	var changes int
+	bus.Subscribe(events.RoomTimerChanged, func(events.Event) { changes++ })
+	m := rooms.NewManager(db, bus)
+	srv := serveRoom(t, m, room)
+
+	owner := dialRoom(t, srv, ownerID)
+	readUntil(t, owner, "state")
+
+	websocket.JSON.Send(owner, map[string]interface{}{"type": "timer.start", "duration_minutes": 25})
+	if msg := readUntil(t, owner, "timer"); msg["timer"] == nil {
+		t.Fatal("expected a running timer")
+	}
+	saved, err := models.GetRoomByID(db, room.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.TimerStartedAt == nil || saved.TimerDurationMinutes == nil || *saved.TimerDurationMinutes != 25 {
+		t.Errorf("saved timer = %v, %v; want 25 minutes", saved.TimerStartedAt, saved.TimerDurationMinutes)
+	}
+
+	websocket.JSON.Send(owner, map[string]string{"type": "timer.stop"})
+	if msg := readUntil(t, owner, "timer"); msg["timer"] != nil {
+		t.Fatalf("expected the timer to stop, got %v", msg["timer"])
+	}
+	// The database write happens after the broadcast
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		saved, err = models.GetRoomByID(db, room.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if saved.TimerStartedAt == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if saved.TimerStartedAt != nil {
+		t.Error("timer still saved after stopping")
+	}
+	if changes != 2 {
+		t.Errorf("RoomTimerChanged published %d times, want 2", changes)
+	}
+}
+
+func TestJoinRoomRequiresInviteCode(t *testing.T) {
+	db := OpenTestDB(t)
+	ownerID := CreateTestUser(t, db, "owner")
+	guestID := CreateTestUser(t, db, "guest")
+	room, err := models.CreateRoom(db, ownerID, "study", "ROOMCODE")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &handlers.RoomHandler{DB: db, Rooms: rooms.NewManager(db, events.NewBus())}
+	join := func(body string) int {
+		w := httptest.NewRecorder()
+		h.JoinRoom(w, AuthedRequest(http.MethodPost, "/rooms/join", body, guestID))
+		return w.Code
+	}
+
+	if code := join(`{}`); code != http.StatusBadRequest {
+		t.Errorf("no code: status %d, want 400", code)
+	}
+	if code := join(`{"invite_code":"WRONG"}`); code != http.StatusNotFound {
+		t.Errorf("wrong code: status %d, want 404", code)
+	}
+	if member, _ := models.IsRoomMember(db, room.ID, guestID); member {
+		t.Fatal("joined without a valid invite code")
+	}
+	if code := join(`{"invite_code":"roomcode"}`); code != http.StatusOK {
+		t.Errorf("valid code: status %d, want 200", code)
+	}
+	if member, _ := models.IsRoomMember(db, room.ID, guestID); !member {
+		t.Error("not a member after joining with the invite code")
+	}
+}
+
+func TestRoomWebSocketChecksOrigin(t *testing.T) {
+	db := OpenTestDB(t)
+	ownerID := CreateTestUser(t, db, "owner")
+	room, err := models.CreateRoom(db, ownerID, "study", "ROOMCODE")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &handlers.RoomHandler{DB: db, Rooms: rooms.NewManager(db, events.NewBus())}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.SetPathValue("id", strconv.Itoa(room.ID))
+		claims := middleware.UserClaims{UserID: ownerID, Role: "user"}
+		h.Connect(w, r.WithContext(context.WithValue(r.Context(), middleware.UserContextKey, claims)))
+	}))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/"
+
+	if ws, err := websocket.Dial(url, "", "https://evil.example"); err == nil {
+		ws.Close()
+		t.Error("connection from another site was accepted")
+	}
+	ws, err := websocket.Dial(url, "", srv.URL)
+	if err != nil {
+		t.Fatalf("same-origin connection rejected: %v", err)
+	}
+	ws.Close()
+}
@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"tomo/backend/events"
+	"tomo/backend/handlers"
+	"tomo/backend/jobs"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+func TestScheduleAndUnschedule(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+	bob := CreateTestUser(t, db, "bob")
+
+	draft, err := models.CreatePost(db, models.Post{UserID: alice, PostType: "general", Content: "soon", Visibility: "public", Status: "draft"}, utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.Itoa(draft.ID)
+	h := &handlers.PostHandler{DB: db}
+
+	schedule := func(userID int, publishAt time.Time) int {
+		w := httptest.NewRecorder()
+		body := `{"publish_at":"` + publishAt.UTC().Format(time.RFC3339) + `"}`
+		h.SchedulePost(w, AuthedRequest(http.MethodPut, "/posts/"+id+"/schedule", body, userID, "id", id))
+		return w.Code
+	}
+	unschedule := func() int {
+		w := httptest.NewRecorder()
+		h.UnschedulePost(w, AuthedRequest(http.MethodDelete, "/posts/"+id+"/schedule", "", alice, "id", id))
+		return w.Code
+	}
+
+	if code := schedule(bob, time.Now().Add(time.Hour)); code != http.StatusForbidden {
+		t.Errorf("schedule by another user: status %d, want 403", code)
+	}
+	if code := schedule(alice, time.Now().Add(-time.Minute)); code != http.StatusBadRequest {
+		t.Errorf("schedule in the past: status %d, want 400", code)
+	}
+	if code := schedule(alice, time.Now().Add(2*handlers.MaxScheduleAhead)); code != http.StatusBadRequest {
+		t.Errorf("schedule too far ahead: status %d, want 400", code)
+	}
+
+	publishAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	if code := schedule(alice, publishAt); code != http.StatusOK {
+		t.Fatalf("schedule: status %d", code)
+	}
+	post, _ := models.GetPostByID(db, draft.ID)
+	if post.Status != "scheduled" || post.PublishAt == nil || !post.PublishAt.Equal(publishAt) {
+		t.Errorf("after scheduling: status %s, publish_at %v; want scheduled at %v", post.Status, post.PublishAt, publishAt)
+	}
+	if visible, _ := models.CanViewPost(db, post, bob); visible {
+		t.Error("a scheduled post is visible to other users")
+	}
+
+	if code := unschedule(); code != http.StatusOK {
+		t.Fatalf("unschedule: status %d", code)
+	}
+	post, _ = models.GetPostByID(db, draft.ID)
+	if post.Status != "draft" || post.PublishAt != nil {
+		t.Errorf("after unscheduling: status %s, publish_at %v; want a draft", post.Status, post.PublishAt)
+	}
+	if code := unschedule(); code != http.StatusConflict {
+		t.Errorf("unschedule a draft: status %d, want 409", code)
+	}
+
+	models.PublishPost(db, draft.ID)
+	if code := schedule(alice, publishAt); code != http.StatusConflict {
+		t.Errorf("schedule a published post: status %d, want 409", code)
+	}
+}
+
+func TestScheduledPostCannotBeEmptied(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+
+	publishAt := time.Now().Add(time.Hour)
+	post, err := models.CreatePost(db, models.Post{UserID: alice, PostType: "general", Content: "soon", Visibility: "public", Status: "scheduled", PublishAt: &publishAt}, utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.Itoa(post.ID)
+
+	h := &handlers.PostHandler{DB: db}
+	w := httptest.NewRecorder()
+	h.UpdatePost(w, AuthedRequest(http.MethodPatch, "/posts/"+id, `{"content":"   "}`, alice, "id", id))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("emptying a scheduled post: status %d, want 400", w.Code)
+	}
+	if stored, _ := models.GetPostByID(db, post.ID); stored.Content != "soon" {
+		t.Errorf("content = %q, want it unchanged", stored.Content)
+	}
+}
+
+func TestCannotScheduleEmptyPost(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+
+	h := &handlers.PostHandler{DB: db}
+	publishAt := time.Now().Add(time.Hour).Format(time.RFC3339)
+	w := httptest.NewRecorder()
+	body := `{"post_type":"general","visibility":"public","content":"  ","publish_at":"` + publishAt + `"}`
+	h.CreatePost(w, AuthedRequest(http.MethodPost, "/posts", body, alice))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("scheduling an empty post: status %d, want 400", w.Code)
+	}
+}
+
+func TestPublishScheduledPostsJob(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+
+	schedule := func(content string, publishAt time.Time) models.Post {
+		t.Helper()
+		post, err := models.CreatePost(db, models.Post{UserID: alice, PostType: "general", Content: content, Visibility: "public", Status: "scheduled", PublishAt: &publishAt}, utils.MarkdownOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return post
+	}
+	due := schedule("ready", time.Now().Add(time.Hour))
+	emptied := schedule("gone", time.Now().Add(time.Hour))
+	later := schedule("later", time.Now().Add(time.Hour))
+
+	// Make the first two due, and empty one of them behind the API's back
+	db.Exec(`UPDATE posts SET publish_at = NOW() - INTERVAL '1 minute' WHERE id IN ($1, $2)`, due.ID, emptied.ID)
+	db.Exec(`UPDATE posts SET content = ' ' WHERE id=$1`, emptied.ID)
+
+	bus := events.NewBus()
+	published := recordEvents(bus, events.PostPublished)
+	updated := recordEvents(bus, events.PostUpdated)
+	if err := jobs.PublishScheduledPosts(db, bus)(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := map[int]string{}
+	for _, p := range []models.Post{due, emptied, later} {
+		stored, _ := models.GetPostByID(db, p.ID)
+		statuses[p.ID] = stored.Status
+		if p.ID == due.ID && stored.PublishedAt == nil {
+			t.Error("published post has no published_at")
+		}
+	}
+	if statuses[due.ID] != "published" || statuses[emptied.ID] != "draft" || statuses[later.ID] != "scheduled" {
+		t.Errorf("statuses = due %s, emptied %s, later %s; want published, draft, scheduled",
+			statuses[due.ID], statuses[emptied.ID], statuses[later.ID])
+	}
+	if len(*published) != 1 || (*published)[0].PostID != due.ID {
+		t.Errorf("PostPublished events = %+v, want one for the due post", *published)
+	}
+	if len(*updated) != 1 || (*updated)[0].PostID != emptied.ID {
+		t.Errorf("PostUpdated events = %+v, want one for the emptied post", *updated)
+	}
+
+	// Running again finds nothing new
+	if err := jobs.PublishScheduledPosts(db, bus)(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(*published) != 1 {
+		t.Errorf("second run published %d more posts", len(*published)-1)
+	}
+}
@@ -0,0 +1,26 @@
+package tests
+
+import (
+	"testing"
+
+	"tomo/backend/models"
+)
+
+func TestHighlightSnippet(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"felt \uE000burnout\uE001 today", "felt <mark>burnout</mark> today"},
+		{"<b>bold</b> & \uE000burnout\uE001", "&lt;b&gt;bold&lt;/b&gt; &amp; <mark>burnout</mark>"},
+		// Literal <mark> tags written by the author are escaped like any other HTML
+		{"<mark>fake</mark> \uE000real\uE001", "&lt;mark&gt;fake&lt;/mark&gt; <mark>real</mark>"},
+		{"<script>alert(1)</script>", "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := models.HighlightSnippet(tt.in); got != tt.want {
+			t.Errorf("HighlightSnippet(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -1,16 +1,22 @@
 package tests
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+
+	"tomo/backend/middleware"
 )
 
 func SetupTestDB(t *testing.T) *sql.DB {
@@ -21,6 +27,57 @@ func SetupTestDB(t *testing.T) *sql.DB {
 	return db
 }
 
+var (
+	testDBOnce sync.Once
+	testDB     *sql.DB
+)
+
+// OpenTestDB returns a connection to a freshly truncated test database,
+// skipping the test when no test database is configured. The schema is
+// rebuilt from scratch once per test binary.
+func OpenTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	LoadTestEnv()
+	if os.Getenv("TEST_DB_HOST") == "" {
+		t.Skip("TEST_DB_HOST not set; skipping database test")
+	}
+	testDBOnce.Do(func() {
+		db := ConnectTestDB()
+		if _, err := db.Exec(`DROP SCHEMA public CASCADE; CREATE SCHEMA public;`); err != nil {
+			log.Fatalf("failed to reset test schema: %v", err)
+		}
+		RunSchema(db, "../db/schema.sql")
+		testDB = db
+	})
+	TruncateAll(testDB)
+	return testDB
+}
+
+// CreateTestUser inserts a user with the given username and returns its id
+func CreateTestUser(t *testing.T, db *sql.DB, username string) int {
+	t.Helper()
+	var id int
+	err := db.QueryRow(
+		`INSERT INTO users (email, username, google_id, display_name) VALUES ($1, $2, $2, $2) RETURNING id`,
+		username+"@example.com", username,
+	).Scan(&id)
+	if err != nil {
+		t.Fatalf("failed to create user %s: %v", username, err)
+	}
+	return id
+}
+
+// AuthedRequest builds a request as if AuthMiddleware had authenticated userID.
+// pathValues are name/value pairs for the route's {wildcards}.
+func AuthedRequest(method, target, body string, userID int, pathValues ...string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(pathValues); i += 2 {
+		r.SetPathValue(pathValues[i], pathValues[i+1])
+	}
+	claims := middleware.UserClaims{UserID: userID, Email: "test@example.com", Role: "user"}
+	return r.WithContext(context.WithValue(r.Context(), middleware.UserContextKey, claims))
+}
+
 func LoadTestEnv() {
 	if err := godotenv.Load(".env.test"); err != nil {
 		log.Println("No .env.test file found (skipping)")
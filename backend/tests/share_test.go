@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tomo/backend/handlers"
+	"tomo/backend/models"
+	"tomo/backend/storage"
+	"tomo/backend/utils"
+)
+
+func TestSharePageVisibility(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+	bob := CreateTestUser(t, db, "bob")
+
+	store, err := storage.NewLocal(t.TempDir(), "http://localhost:8080/files/", []byte("test-signing-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &handlers.PostHandler{DB: db, Storage: store}
+
+	share := func(post models.Post) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/p/"+post.ShareSlug, nil)
+		r.SetPathValue("slug", post.ShareSlug)
+		w := httptest.NewRecorder()
+		h.GetSharePage(w, r)
+		return w
+	}
+
+	unlisted, _ := models.CreatePost(db, models.Post{UserID: alice, PostType: "general", Content: "by link only", Visibility: "unlisted"}, utils.MarkdownOptions{})
+	private, _ := models.CreatePost(db, models.Post{UserID: alice, PostType: "general", Content: "mine", Visibility: "private"}, utils.MarkdownOptions{})
+
+	w := share(unlisted)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unlisted share page: status %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `content="noindex"`) {
+		t.Error("unlisted share page should not be indexed")
+	}
+	if cc := w.Header().Get("Cache-Control"); strings.Contains(cc, "public") {
+		t.Errorf("Cache-Control = %q; share pages must not be stored by shared caches", cc)
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "img-src 'self' http://localhost:8080;") {
+		t.Errorf("CSP = %q, want images allowed from the storage origin", csp)
+	}
+
+	if w := share(private); w.Code != http.StatusNotFound {
+		t.Errorf("private share page: status %d, want 404", w.Code)
+	}
+
+	// Unlisted posts can't be reached by ID, except by their owner
+	if visible, _ := models.CanViewPost(db, unlisted, bob); visible {
+		t.Error("unlisted post is visible by ID to another user")
+	}
+	if visible, _ := models.CanViewPost(db, unlisted, alice); !visible {
+		t.Error("unlisted post is hidden from its owner")
+	}
+	if visible, _ := models.CanViewSharedPost(db, unlisted, bob); !visible {
+		t.Error("unlisted post is hidden from a viewer with the link")
+	}
+}
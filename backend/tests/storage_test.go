@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tomo/backend/storage"
+)
+
+func TestValidKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want error
+	}{
+		{"uploads/12/abc.jpg", nil},
+		{"abc.jpg", nil},
+		{"", storage.ErrInvalidKey},
+		{"/uploads/abc.jpg", storage.ErrInvalidKey},
+		{"uploads//abc.jpg", storage.ErrInvalidKey},
+		{"uploads/../../etc/passwd", storage.ErrInvalidKey},
+		{".meta/uploads/abc.jpg.json", storage.ErrInvalidKey},
+		{"uploads\\abc.jpg", storage.ErrInvalidKey},
+	}
+	for _, tt := range tests {
+		if got := storage.ValidKey(tt.key); got != tt.want {
+			t.Errorf("ValidKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func newTestLocalStorage(t *testing.T) *storage.Local {
+	t.Helper()
+	store, err := storage.NewLocal(t.TempDir(), "http://localhost:8080/files", []byte("test-signing-key"))
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	return store
+}
+
+func TestKeyFromURL(t *testing.T) {
+	store := newTestLocalStorage(t)
+
+	if got := storage.URL(store, "uploads/1/a.jpg"); got != "http://localhost:8080/files/uploads/1/a.jpg" {
+		t.Errorf("URL = %q", got)
+	}
+	if key, ok := storage.KeyFromURL(store, "http://localhost:8080/files/uploads/1/a.jpg"); !ok || key != "uploads/1/a.jpg" {
+		t.Errorf("KeyFromURL = %q, %v; want uploads/1/a.jpg, true", key, ok)
+	}
+	for _, u := range []string{"https://example.com/uploads/1/a.jpg", "http://localhost:8080/files/../secret", "http://localhost:8080/files/"} {
+		if key, ok := storage.KeyFromURL(store, u); ok {
+			t.Errorf("KeyFromURL(%q) = %q, want no key", u, key)
+		}
+	}
+}
+
+func TestLocalStorageRoundTrip(t *testing.T) {
+	store := newTestLocalStorage(t)
+	ctx := context.Background()
+	key := "uploads/1/note.txt"
+
+	if _, err := store.Stat(ctx, key); err != storage.ErrNotFound {
+		t.Fatalf("Stat before Put = %v, want ErrNotFound", err)
+	}
+	if err := store.Put(ctx, key, strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := store.Put(ctx, "uploads/1/short.txt", strings.NewReader("hi"), 5, "text/plain"); err == nil {
+		t.Error("Put with a short body should fail")
+	}
+
+	info, err := store.Stat(ctx, key)
+	if err != nil || info.Size != 5 || info.ContentType != "text/plain" {
+		t.Fatalf("Stat = %+v, %v", info, err)
+	}
+
+	body, _, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "hello" {
+		t.Errorf("Get = %q, want hello", data)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Stat(ctx, key); err != storage.ErrNotFound {
+		t.Errorf("Stat after Delete = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("deleting a missing key: %v", err)
+	}
+}
+
+func TestLocalStoragePresignedUpload(t *testing.T) {
+	store := newTestLocalStorage(t)
+	server := httptest.NewServer(http.StripPrefix(storage.LocalRoutePrefix, store))
+	defer server.Close()
+
+	upload, err := store.Presign(context.Background(), "uploads/1/a.png", "image/png", 4, time.Minute)
+	if err != nil {
+		t.Fatalf("Presign: %v", err)
+	}
+	// Point the signed URL at the test server
+	uploadURL := server.URL + strings.TrimPrefix(upload.URL, "http://localhost:8080")
+
+	put := func(url, contentType, body string) int {
+		req, _ := http.NewRequest(upload.Method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("PUT: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := put(uploadURL, "image/png", "12345"); code != http.StatusBadRequest {
+		t.Errorf("wrong size: status %d, want 400", code)
+	}
+	if code := put(uploadURL, "image/svg+xml", "1234"); code != http.StatusForbidden {
+		t.Errorf("wrong content type: status %d, want 403", code)
+	}
+	if code := put(strings.Replace(uploadURL, "a.png", "b.png", 1), "image/png", "1234"); code != http.StatusForbidden {
+		t.Errorf("other key: status %d, want 403", code)
+	}
+	if code := put(uploadURL, upload.Headers["Content-Type"], "1234"); code != http.StatusOK {
+		t.Fatalf("valid upload: status %d, want 200", code)
+	}
+
+	resp, err := http.Get(server.URL + "/files/uploads/1/a.png")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(data) != "1234" || resp.Header.Get("Content-Type") != "image/png" {
+		t.Errorf("GET = %d %q (%s)", resp.StatusCode, data, resp.Header.Get("Content-Type"))
+	}
+}
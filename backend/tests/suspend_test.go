@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"tomo/backend/handlers"
+	"tomo/backend/middleware"
+	"tomo/backend/utils"
+)
+
+func TestSuspensionRevokesIssuedTokens(t *testing.T) {
+	db := OpenTestDB(t)
+	middleware.UseDB(db)
+	admin := CreateTestUser(t, db, "admin")
+	member := CreateTestUser(t, db, "member")
+
+	token, err := utils.CreateToken(member, "member@example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create token: %v", err)
+	}
+	ticket, err := middleware.IssueStreamTicket(middleware.UserClaims{UserID: member, Role: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	call := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		middleware.AuthMiddleware(ok).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := call(); code != http.StatusOK {
+		t.Fatalf("before suspension: status %d, want 200", code)
+	}
+
+	h := &handlers.AdminHandler{DB: db}
+	id := strconv.Itoa(member)
+	rec := httptest.NewRecorder()
+	h.SuspendUser(rec, AuthedRequest(http.MethodPost, "/admin/users/"+id+"/suspend", "", admin, "id", id))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("suspend: status %d", rec.Code)
+	}
+
+	if code := call(); code != http.StatusForbidden {
+		t.Errorf("while suspended: status %d, want 403", code)
+	}
+	rec = httptest.NewRecorder()
+	middleware.StreamAuthMiddleware(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events?ticket="+ticket, nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("stream ticket while suspended: status %d, want 403", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.UnsuspendUser(rec, AuthedRequest(http.MethodPost, "/admin/users/"+id+"/unsuspend", "", admin, "id", id))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unsuspend: status %d", rec.Code)
+	}
+	if code := call(); code != http.StatusOK {
+		t.Errorf("after unsuspending: status %d, want 200", code)
+	}
+}
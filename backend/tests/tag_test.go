@@ -0,0 +1,230 @@
+package tests
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"tomo/backend/handlers"
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{"deepwork", "deepwork", nil},
+		{"  DeepWork ", "deepwork", nil},
+		{"#Deep Work", "deep-work", nil},
+		{"deep   work\tsession", "deep-work-session", nil},
+		{"naïve_Ünïcode", "naïve_ünïcode", nil},
+		{"   ", "", models.ErrEmptyTag},
+		{"#", "", models.ErrEmptyTag},
+		{strings.Repeat("a", models.MaxTagLength), strings.Repeat("a", models.MaxTagLength), nil},
+		{strings.Repeat("a", models.MaxTagLength+1), "", models.ErrTagTooLong},
+		{"c++", "", models.ErrTagCharacters},
+	}
+	for _, tt := range tests {
+		got, err := models.NormalizeTag(tt.in)
+		if got != tt.want || err != tt.err {
+			t.Errorf("NormalizeTag(%q) = %q, %v; want %q, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestNormalizeTagsDropsDuplicates(t *testing.T) {
+	got, err := models.NormalizeTags([]string{"Focus", "focus", "#focus", "Reading"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0] != "focus" || got[1] != "reading" {
+		t.Errorf("got %v, want [focus reading]", got)
+	}
+}
+
+func TestRenameTagOntoExistingNameConflicts(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+
+	var focusID, readingID int
+	db.QueryRow(`INSERT INTO tags (user_id, name) VALUES ($1, 'focus') RETURNING id`, alice).Scan(&focusID)
+	db.QueryRow(`INSERT INTO tags (user_id, name) VALUES ($1, 'reading') RETURNING id`, alice).Scan(&readingID)
+
+	if err := models.RenameTag(db, readingID, "focus"); err != models.ErrTagExists {
+		t.Fatalf("RenameTag onto existing name = %v, want ErrTagExists", err)
+	}
+
+	h := &handlers.TagHandler{DB: db}
+	w := httptest.NewRecorder()
+	h.RenameTag(w, AuthedRequest("PATCH", "/tags/"+strconv.Itoa(readingID), `{"name":"Focus"}`, alice, "id", strconv.Itoa(readingID)))
+	if w.Code != http.StatusConflict {
+		t.Errorf("rename onto existing tag: status %d, want 409", w.Code)
+	}
+}
+
+func TestNormalizeExistingTags(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+	bob := CreateTestUser(t, db, "bob")
+
+	post, err := models.CreatePost(db, models.Post{UserID: alice, PostType: "general", Content: "hi", Visibility: "public"}, utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Legacy rows written before names were normalized
+	tagIDs := map[string]int{}
+	for _, row := range []struct {
+		user int
+		name string
+	}{{alice, "Deep Work"}, {alice, "deep-work"}, {alice, "#Reading"}, {alice, "c++"}, {bob, "Deep Work"}} {
+		var id int
+		if err := db.QueryRow(`INSERT INTO tags (user_id, name) VALUES ($1, $2) RETURNING id`, row.user, row.name).Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		tagIDs[strconv.Itoa(row.user)+row.name] = id
+	}
+	a := strconv.Itoa(alice)
+	db.Exec(`INSERT INTO post_tags (post_id, tag_id) VALUES ($1, $2), ($1, $3)`, post.ID, tagIDs[a+"Deep Work"], tagIDs[a+"deep-work"])
+
+	result, err := models.NormalizeExistingTags(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Merged != 1 || result.Renamed != 2 || len(result.Invalid) != 1 || result.Invalid[0] != "c++" {
+		t.Errorf("result = %+v, want 1 merged, 2 renamed, [c++] invalid", result)
+	}
+
+	tags, err := models.GetTagsForUser(db, alice, "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+		if tag.Name == "deep-work" && (tag.ID != tagIDs[a+"deep-work"] || tag.PostCount != 1) {
+			t.Errorf("deep-work = %+v, want the existing tag with the post linked once", tag)
+		}
+	}
+	if strings.Join(names, ",") != "c++,deep-work,reading" {
+		t.Errorf("alice's tags = %v, want [c++ deep-work reading]", names)
+	}
+
+	if _, err := models.GetTagByName(db, bob, "deep-work"); err != nil {
+		t.Errorf("bob's tag was not normalized: %v", err)
+	}
+}
+
+// sortedTags returns a post's tag names in alphabetical order
+func sortedTags(t *testing.T, db *sql.DB, postID int) string {
+	t.Helper()
+	rows, err := db.Query(
+		`SELECT t.name FROM tags t JOIN post_tags pt ON pt.tag_id = t.id WHERE pt.post_id=$1`,
+		postID,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// createTaggedPost creates a post with the given status and tags
+func createTaggedPost(t *testing.T, db *sql.DB, userID int, status string, tags ...string) models.Post {
+	t.Helper()
+	post, err := models.CreatePost(db, models.Post{
+		UserID:     userID,
+		PostType:   "general",
+		Title:      "first",
+		Content:    "first version",
+		Visibility: "public",
+		Status:     status,
+	}, utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.AddTagsToPost(db, userID, post.ID, tags); err != nil {
+		t.Fatal(err)
+	}
+	return post
+}
+
+func TestUpdatePostWithTagsEdits(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+	post := createTaggedPost(t, db, alice, "published", "focus", "reading")
+
+	// Remove runs before Add, and names are normalized on both sides
+	edit := models.TagEdit{Add: []string{"#Deep Work"}, Remove: []string{"Reading"}}
+	if err := models.UpdatePostWithTags(db, post, edit, nil, utils.MarkdownOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := sortedTags(t, db, post.ID); got != "deep-work,focus" {
+		t.Errorf("after add/remove: tags = %s, want deep-work,focus", got)
+	}
+
+	replace := []string{"writing"}
+	if err := models.UpdatePostWithTags(db, post, models.TagEdit{Replace: &replace, Add: []string{"notes"}}, nil, utils.MarkdownOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := sortedTags(t, db, post.ID); got != "notes,writing" {
+		t.Errorf("after replace: tags = %s, want notes,writing", got)
+	}
+
+	// Without Prune the detached tags stay in the user's list
+	if _, err := models.GetTagByName(db, alice, "focus"); err != nil {
+		t.Errorf("focus was deleted without Prune: %v", err)
+	}
+}
+
+func TestUpdatePostWithTagsPrune(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+	post := createTaggedPost(t, db, alice, "published", "focus", "reading")
+	createTaggedPost(t, db, alice, "published", "reading")
+
+	edit := models.TagEdit{Remove: []string{"focus", "reading"}, Prune: true}
+	if err := models.UpdatePostWithTags(db, post, edit, nil, utils.MarkdownOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.GetTagByName(db, alice, "focus"); err == nil {
+		t.Error("unused tag focus was not pruned")
+	}
+	if _, err := models.GetTagByName(db, alice, "reading"); err != nil {
+		t.Errorf("reading is still used by another post but was pruned: %v", err)
+	}
+}
+
+func TestUpdatePostWithTagsRollsBackOnInvalidTag(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+	post := createTaggedPost(t, db, alice, "published", "focus")
+
+	post.Content = "changed"
+	err := models.UpdatePostWithTags(db, post, models.TagEdit{Add: []string{"c++"}}, nil, utils.MarkdownOptions{})
+	if err != models.ErrTagCharacters {
+		t.Fatalf("err = %v, want ErrTagCharacters", err)
+	}
+
+	stored, _ := models.GetPostByID(db, post.ID)
+	if stored.Content != "first version" {
+		t.Errorf("content = %q; the failed edit should not have been saved", stored.Content)
+	}
+	if revisions, _ := models.GetRevisionsForPost(db, post.ID); len(revisions) != 0 {
+		t.Errorf("got %d revisions from a rolled back edit", len(revisions))
+	}
+}
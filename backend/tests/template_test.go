@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"tomo/backend/models"
+	"tomo/backend/utils"
+)
+
+func TestValidateAnswers(t *testing.T) {
+	template := models.ReflectionTemplate{
+		Questions: []models.TemplateQuestion{
+			{ID: 1, Prompt: "What went well?", Type: "text"},
+			{ID: 2, Prompt: "How focused did you feel?", Type: "scale"},
+			{ID: 3, Prompt: "Did you finish?", Type: "yes_no"},
+		},
+	}
+	id := func(n int) *int { return &n }
+	text := func(s string) *string { return &s }
+	yes := true
+
+	answers, err := models.ValidateAnswers(template, []models.PostAnswer{
+		{QuestionID: id(1), Text: text("  deep work before lunch ")},
+		{QuestionID: id(2), Scale: id(4)},
+		{QuestionID: id(3), YesNo: &yes},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(answers) != 3 {
+		t.Fatalf("got %d answers, want 3", len(answers))
+	}
+	if *answers[0].Text != "deep work before lunch" || answers[0].Prompt != "What went well?" || answers[0].Type != "text" {
+		t.Errorf("text answer not normalized: %+v", answers[0])
+	}
+
+	// A blank text answer counts as skipping the question
+	answers, err = models.ValidateAnswers(template, []models.PostAnswer{{QuestionID: id(1), Text: text("   ")}})
+	if err != nil || len(answers) != 0 {
+		t.Errorf("blank text answer: got %v, %v; want no answers", answers, err)
+	}
+
+	invalid := map[string][]models.PostAnswer{
+		"unknown question": {{QuestionID: id(9), Text: text("x")}},
+		"missing question": {{Text: text("x")}},
+		"wrong type":       {{QuestionID: id(2), Text: text("x")}},
+		"scale too high":   {{QuestionID: id(2), Scale: id(6)}},
+		"two values":       {{QuestionID: id(3), YesNo: &yes, Scale: id(1)}},
+		"answered twice":   {{QuestionID: id(2), Scale: id(1)}, {QuestionID: id(2), Scale: id(2)}},
+	}
+	for name, in := range invalid {
+		if _, err := models.ValidateAnswers(template, in); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestBuiltinTemplateSeedsRunOnce(t *testing.T) {
+	db := OpenTestDB(t)
+
+	schema, err := os.ReadFile("../db/schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := strings.Index(string(schema), "-- Built-in templates are named uniquely")
+	end := strings.Index(string(schema)[start:], "\n---\n")
+	if start < 0 || end < 0 {
+		t.Fatal("built-in template seeds not found in schema.sql")
+	}
+	seeds := string(schema)[start : start+end]
+
+	for i := 0; i < 2; i++ {
+		if _, err := db.Exec(seeds); err != nil {
+			t.Fatalf("run %d: %v", i+1, err)
+		}
+	}
+
+	var templates, questions int
+	db.QueryRow(`SELECT COUNT(*) FROM reflection_templates WHERE user_id IS NULL`).Scan(&templates)
+	db.QueryRow(`SELECT COUNT(*) FROM template_questions`).Scan(&questions)
+	if templates != 2 || questions != 8 {
+		t.Errorf("after seeding twice: %d templates, %d questions; want 2, 8", templates, questions)
+	}
+}
+
+func TestAnswersSavedWithPost(t *testing.T) {
+	db := OpenTestDB(t)
+	alice := CreateTestUser(t, db, "alice")
+
+	template, err := models.CreateTemplate(db, models.ReflectionTemplate{
+		UserID:    &alice,
+		Name:      "Evening",
+		Questions: []models.TemplateQuestion{{Prompt: "What went well?", Type: "text"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	questionID := template.Questions[0].ID
+	answer := func(s string) []models.PostAnswer {
+		return []models.PostAnswer{{QuestionID: &questionID, Prompt: "What went well?", Type: "text", Text: &s}}
+	}
+	newPost := models.Post{UserID: alice, PostType: "general", Content: "day", Visibility: "private", TemplateID: &template.ID}
+
+	// A bad tag rolls back the whole post, answers included
+	if _, err := models.CreatePostWithTags(db, newPost, []string{"c++"}, answer("nothing"), utils.MarkdownOptions{}); err == nil {
+		t.Fatal("expected an error for an invalid tag")
+	}
+	var posts int
+	db.QueryRow(`SELECT COUNT(*) FROM posts`).Scan(&posts)
+	if posts != 0 {
+		t.Fatalf("%d posts left behind by a failed create", posts)
+	}
+
+	post, err := models.CreatePostWithTags(db, newPost, []string{"evening"}, answer("shipped it"), utils.MarkdownOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Likewise a failed update keeps the old answers
+	replaced := answer("replaced")
+	if err := models.UpdatePostWithTags(db, post, models.TagEdit{Add: []string{"c++"}}, &replaced, utils.MarkdownOptions{}); err == nil {
+		t.Fatal("expected an error for an invalid tag")
+	}
+	answers, err := models.GetAnswersForPost(db, post.ID)
+	if err != nil || len(answers) != 1 || *answers[0].Text != "shipped it" {
+		t.Fatalf("answers after failed update = %+v, %v; want the original answer", answers, err)
+	}
+
+	if err := models.UpdatePostWithTags(db, post, models.TagEdit{}, &replaced, utils.MarkdownOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	answers, _ = models.GetAnswersForPost(db, post.ID)
+	if len(answers) != 1 || *answers[0].Text != "replaced" {
+		t.Errorf("answers after update = %+v, want the replaced answer", answers)
+	}
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"bytes"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// MarkdownRenderVersion identifies the current Markdown renderer and sanitizer
+// policy. Bump it whenever either changes so cached HTML gets re-rendered.
+const MarkdownRenderVersion = 4
+
+// GitHub-flavored Markdown. Raw HTML in the source is omitted (goldmark's
+// default), and single newlines become line breaks like in a plain-text journal.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(gmhtml.WithHardWraps()),
+)
+
+// Sanitizer policies by allowed image origin
+var markdownPolicies sync.Map
+
+var (
+	// CodePattern matches fenced code blocks and inline code spans, which are
+	// never parsed for mentions or hashtags
+	CodePattern = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`")
+
+	// MentionPattern matches @username (the first group) at the start of the
+	// text or after whitespace or an opening bracket/quote, so emails and URLs
+	// don't match
+	MentionPattern = regexp.MustCompile(`(?:^|[\s(\[{"'])@([A-Za-z0-9_.]+)`)
+)
+
+// MarkdownOptions configure RenderMarkdown
+type MarkdownOptions struct {
+	// Images are only kept when their src starts with ImageOrigin (e.g. the
+	// media storage base URL); none are kept if it is empty
+	ImageOrigin string
+	// @mentions link to ProfileURL + username; they stay plain text if it is empty
+	ProfileURL string
+}
+
+// PostMarkdownOptions are the options post content is rendered with: images
+// from imageOrigin (the media storage base URL) and @mentions linking to
+// profiles under PUBLIC_BASE_URL
+func PostMarkdownOptions(imageOrigin string) MarkdownOptions {
+	opts := MarkdownOptions{ImageOrigin: imageOrigin}
+	if base := strings.TrimRight(strings.TrimSpace(os.Getenv("PUBLIC_BASE_URL")), "/"); base != "" {
+		opts.ProfileURL = base + "/users/"
+	}
+	return opts
+}
+
+// markdownPolicy allows the elements Markdown produces and nothing else. Links
+// must be absolute http(s) or mailto URLs and get rel="nofollow noopener";
+// relative links are dropped since they would resolve against whichever site
+// embeds the HTML (e.g. [x](/admin)). Images are only
+// kept when their src starts with imageOrigin (none are kept if it is empty).
+func markdownPolicy(imageOrigin string) *bluemonday.Policy {
+	if p, ok := markdownPolicies.Load(imageOrigin); ok {
+		return p.(*bluemonday.Policy)
+	}
+
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "hr", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote", "pre", "code",
+		"em", "strong", "del", "ul", "ol", "li", "table", "thead", "tbody", "tr", "th", "td")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+
+	// Task list checkboxes
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^$`)).OnElements("input")
+
+	p.AllowStandardURLs()
+	p.AllowRelativeURLs(false)
+	p.AllowAttrs("href").OnElements("a")
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+
+	if imageOrigin != "" {
+		p.AllowAttrs("src").Matching(regexp.MustCompile("^" + regexp.QuoteMeta(imageOrigin))).OnElements("img")
+		p.AllowAttrs("alt", "title").OnElements("img")
+	}
+
+	actual, _ := markdownPolicies.LoadOrStore(imageOrigin, p)
+	return actual.(*bluemonday.Policy)
+}
+
+// RenderMarkdown converts Markdown to sanitized HTML that is safe to embed in a
+// page, linking @mentions and keeping only images from opts.ImageOrigin
+func RenderMarkdown(src string, opts MarkdownOptions) (string, error) {
+	if opts.ProfileURL != "" {
+		src = linkMentions(src, opts.ProfileURL)
+	}
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(src), &buf); err != nil {
+		return "", err
+	}
+	return markdownPolicy(opts.ImageOrigin).Sanitize(buf.String()), nil
+}
+
+// linkMentions rewrites each @username outside code as a Markdown link to
+// profileURL + username. Mentions that already are link text ("[@alice](...)")
+// are left alone.
+func linkMentions(src, profileURL string) string {
+	var out strings.Builder
+	last := 0
+	rewrite := func(text string) {
+		prev := 0
+		for _, m := range MentionPattern.FindAllStringSubmatchIndex(text, -1) {
+			at := m[2] - 1
+			// "@alice." at the end of a sentence mentions alice
+			name := strings.TrimRight(text[m[2]:m[3]], ".")
+			if name == "" || (at > 0 && text[at-1] == '[') {
+				continue
+			}
+			out.WriteString(text[prev:at])
+			out.WriteString("[@" + name + "](<" + profileURL + name + ">)")
+			prev = at + 1 + len(name)
+		}
+		out.WriteString(text[prev:])
+	}
+	for _, code := range CodePattern.FindAllStringIndex(src, -1) {
+		rewrite(src[last:code[0]])
+		out.WriteString(src[code[0]:code[1]])
+		last = code[1]
+	}
+	rewrite(src[last:])
+	return out.String()
+}
+
+// Matches any tag in sanitized HTML
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// Excerpt returns the plain text of sanitized HTML (e.g. RenderMarkdown output),
+// shortened to at most maxRunes at a word boundary with an ellipsis. Used for
+// link previews.
+func Excerpt(sanitizedHTML string, maxRunes int) string {
+	text := html.UnescapeString(htmlTagPattern.ReplaceAllString(sanitizedHTML, " "))
+	text = strings.Join(strings.Fields(text), " ")
+
+	runes := []rune(text)
+	if len(runes) <= maxRunes {
+		return text
+	}
+	cut := string(runes[:maxRunes])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " .,;:-") + "…"
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// codeAlphabet omits characters that are easy to confuse when typed (0/O, 1/I/L)
+const codeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// RandomCode returns a random, human-friendly code of length n (e.g. an invite code)
+func RandomCode(n int) (string, error) {
+	return randomString(codeAlphabet, n)
+}
+
+// slugAlphabet is URL-safe and case-sensitive, for short identifiers nobody types
+const slugAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// RandomSlug returns a random URL-safe string of length n (e.g. a share link slug)
+func RandomSlug(n int) (string, error) {
+	return randomString(slugAlphabet, n)
+}
+
+func randomString(alphabet string, n int) (string, error) {
+	max := big.NewInt(int64(len(alphabet)))
+	b := make([]byte, n)
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = alphabet[idx.Int64()]
+	}
+	return string(b), nil
+}